@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Socket activation follows the systemd protocol (sd_listen_fds(3)): the
+// child inherits listening sockets starting at fd 3, with LISTEN_FDS giving
+// the count and LISTEN_PID the PID they are meant for.
+
+// activationShim sets LISTEN_PID to the child's own PID, which isn't known
+// until after fork, then execs the real command in place.
+const activationShim = `LISTEN_PID=$$; export LISTEN_PID; exec "$@"`
+
+// bindActivationSocket binds port on loopback and returns the listening
+// socket as a file ready to pass to a child.
+func bindActivationSocket(port int) (*os.File, error) {
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		return nil, fmt.Errorf("bind port %d for activation: %w", port, err)
+	}
+	defer ln.Close()
+	// File returns a dup; the original listener can go.
+	return ln.File()
+}
+
+// activationCommand runs argv with socket passed as fd 3 and the
+// LISTEN_* variables set.
+func activationCommand(argv []string, env []string, socket *os.File) *exec.Cmd {
+	shimArgs := append([]string{"-c", activationShim, "sh"}, argv...)
+	c := exec.Command("/bin/sh", shimArgs...)
+	c.ExtraFiles = []*os.File{socket}
+
+	// Drop anything inherited from our own activation.
+	filtered := make([]string, 0, len(env)+2)
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "LISTEN_PID=") || strings.HasPrefix(kv, "LISTEN_FDS=") || strings.HasPrefix(kv, "LISTEN_FDNAMES=") {
+			continue
+		}
+		filtered = append(filtered, kv)
+	}
+	c.Env = append(filtered, "LISTEN_FDS=1", "LISTEN_FDNAMES=fp")
+	return c
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
+)
+
+// journalSocket is where systemd-journald accepts native protocol datagrams.
+const journalSocket = "/run/systemd/journal/socket"
+
+type auditRecord struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Port    int       `json:"port"`
+	PID     int       `json:"pid"`
+	Command string    `json:"command,omitempty"`
+	Owner   string    `json:"owner,omitempty"`
+	Signal  string    `json:"signal"`
+	Result  string    `json:"result"`
+}
+
+// auditLog appends kill records to a JSON-lines file or to journald. It is
+// best-effort: if the sink can't be opened or written, fp warns once and
+// carries on with the kill.
+type auditLog struct {
+	target string
+	file   *os.File
+	conn   net.Conn
+	failed bool
+	now    func() time.Time
+}
+
+func openAuditLog(target string) *auditLog {
+	if target == "" {
+		return nil
+	}
+	a := &auditLog{target: target, now: time.Now}
+	var err error
+	if target == "journald" {
+		a.conn, err = net.Dial("unixgram", journalSocket)
+	} else {
+		a.file, err = os.OpenFile(target, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	}
+	if err != nil {
+		a.warn(err)
+	}
+	return a
+}
+
+func (a *auditLog) Record(port int, sig syscall.Signal, t scan.Listener, result string) {
+	if a == nil || a.failed {
+		return
+	}
+	rec := auditRecord{
+		Time:    a.now().UTC(),
+		User:    currentUsername(),
+		Port:    port,
+		PID:     t.PID,
+		Command: t.Command,
+		Owner:   t.User,
+		Signal:  signalName(sig),
+		Result:  result,
+	}
+
+	var err error
+	if a.conn != nil {
+		_, err = a.conn.Write(journalEntry(rec))
+	} else {
+		var data []byte
+		data, err = json.Marshal(rec)
+		if err == nil {
+			_, err = a.file.Write(append(data, '\n'))
+		}
+	}
+	if err != nil {
+		a.warn(err)
+	}
+}
+
+func (a *auditLog) Close() {
+	if a == nil {
+		return
+	}
+	if a.file != nil {
+		_ = a.file.Close()
+	}
+	if a.conn != nil {
+		_ = a.conn.Close()
+	}
+}
+
+func (a *auditLog) warn(err error) {
+	a.failed = true
+	fmt.Fprintf(ui.Stderr(), "%s audit log %s unavailable: %v\n", ui.LabelWarn(ui.Stderr()), a.target, err)
+}
+
+// journalEntry encodes rec in journald's native KEY=value format.
+func journalEntry(rec auditRecord) []byte {
+	field := func(k, v string) string {
+		return k + "=" + strings.ReplaceAll(v, "\n", " ") + "\n"
+	}
+	var b strings.Builder
+	b.WriteString(field("MESSAGE", fmt.Sprintf("fp kill: %s pid %d (%s) on port %d: %s", rec.Signal, rec.PID, rec.Command, rec.Port, rec.Result)))
+	b.WriteString(field("SYSLOG_IDENTIFIER", "fp"))
+	b.WriteString(field("FP_USER", rec.User))
+	b.WriteString(field("FP_PORT", fmt.Sprint(rec.Port)))
+	b.WriteString(field("FP_TARGET_PID", fmt.Sprint(rec.PID)))
+	b.WriteString(field("FP_COMMAND", rec.Command))
+	b.WriteString(field("FP_SIGNAL", rec.Signal))
+	b.WriteString(field("FP_RESULT", rec.Result))
+	return []byte(b.String())
+}
+
+func currentUsername() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+func TestAuditLogRecordsKill(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a := openAuditLog(path)
+	fixed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	a.now = func() time.Time { return fixed }
+
+	target := scan.Listener{Port: 3000, PID: 4242, User: "alice", Command: "node"}
+	a.Record(3000, syscall.SIGTERM, target, "signaled")
+	a.Record(3000, syscall.SIGKILL, target, "gone")
+	a.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %d: %q", len(lines), data)
+	}
+
+	var rec auditRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+	if !rec.Time.Equal(fixed) || rec.Port != 3000 || rec.PID != 4242 || rec.Command != "node" ||
+		rec.Owner != "alice" || rec.Signal != "SIGTERM" || rec.Result != "signaled" {
+		t.Fatalf("unexpected record %+v", rec)
+	}
+	if rec.User != currentUsername() {
+		t.Fatalf("expected user %q, got %q", currentUsername(), rec.User)
+	}
+}
+
+func TestAuditLogUnwritableSinkIsNonFatal(t *testing.T) {
+	a := openAuditLog(filepath.Join(t.TempDir(), "missing", "audit.jsonl"))
+	if !a.failed {
+		t.Fatalf("expected sink to be marked failed")
+	}
+	a.Record(3000, syscall.SIGTERM, scan.Listener{PID: 1}, "signaled")
+	a.Close()
+}
+
+func TestJournalEntryFields(t *testing.T) {
+	entry := string(journalEntry(auditRecord{Port: 80, PID: 7, Command: "nginx\nx", Signal: "SIGHUP", Result: "signaled"}))
+	for _, want := range []string{"SYSLOG_IDENTIFIER=fp\n", "FP_TARGET_PID=7\n", "FP_COMMAND=nginx x\n", "FP_PORT=80\n", "FP_SIGNAL=SIGHUP\n"} {
+		if !strings.Contains(entry, want) {
+			t.Fatalf("expected %q in journal entry %q", want, entry)
+		}
+	}
+}
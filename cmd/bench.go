@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchRange      string
+	benchIterations int
+)
+
+var benchCmd = &cobra.Command{
+	Use:    "bench",
+	Short:  "Time port selection and scanning on this machine",
+	Hidden: true,
+	Long: `Time the core paths on this machine: pick (PickTCPPort over --range),
+probe (binding every port in --range), and scan (the listener backend).
+
+A diagnostic for "why is pick slow here", not a Go benchmark.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := ports.ParseRange(benchRange)
+		if err != nil {
+			return err
+		}
+		if benchIterations < 1 {
+			return fmt.Errorf("--iterations must be at least 1")
+		}
+		size := r.End - r.Start + 1
+
+		steps := []struct {
+			name  string
+			ports int
+			fn    func() error
+		}{
+			{"pick", 0, func() error {
+				_, err := ports.PickTCPPort(nil, r, ports.DefaultProbeOptions())
+				return err
+			}},
+			{"probe", size, func() error {
+				_, err := ports.BusyPorts(r, ports.DefaultProbeOptions())
+				return err
+			}},
+			{"scan", 0, func() error {
+				_, err := listTCPListeners(context.Background())
+				return err
+			}},
+		}
+
+		var results []benchResult
+		for _, s := range steps {
+			res, err := runBench(s.name, benchIterations, s.ports, s.fn)
+			if err != nil {
+				return fmt.Errorf("%s: %w", s.name, err)
+			}
+			results = append(results, res)
+		}
+
+		if jsonOutput {
+			return writeJSON(os.Stdout, results)
+		}
+		out := ui.Stdout()
+		fmt.Fprintf(out, "%s\n", ui.Header(out, "STEP\tRUNS\tMEAN\tMIN\tMAX\tPORTS/S"))
+		for _, res := range results {
+			rate := "-"
+			if res.PortsPerSec > 0 {
+				rate = fmt.Sprintf("%.0f", res.PortsPerSec)
+			}
+			fmt.Fprintf(out, "%s\t%d\t%.2fms\t%.2fms\t%.2fms\t%s\n", res.Name, res.Iterations, res.MeanMS, res.MinMS, res.MaxMS, rate)
+		}
+		return nil
+	},
+}
+
+type benchResult struct {
+	Name        string  `json:"name"`
+	Iterations  int     `json:"iterations"`
+	MeanMS      float64 `json:"mean_ms"`
+	MinMS       float64 `json:"min_ms"`
+	MaxMS       float64 `json:"max_ms"`
+	PortsPerSec float64 `json:"ports_per_sec,omitempty"`
+}
+
+// runBench times fn over iterations. ports is how many ports one call
+// covers; when non-zero the result includes a throughput figure.
+func runBench(name string, iterations, ports int, fn func() error) (benchResult, error) {
+	var total, lo, hi time.Duration
+	for i := range iterations {
+		start := time.Now()
+		if err := fn(); err != nil {
+			return benchResult{}, err
+		}
+		d := time.Since(start)
+		total += d
+		if i == 0 || d < lo {
+			lo = d
+		}
+		hi = max(hi, d)
+	}
+
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	mean := total / time.Duration(iterations)
+	res := benchResult{
+		Name:       name,
+		Iterations: iterations,
+		MeanMS:     ms(mean),
+		MinMS:      ms(lo),
+		MaxMS:      ms(hi),
+	}
+	if ports > 0 && mean > 0 {
+		res.PortsPerSec = float64(ports) / mean.Seconds()
+	}
+	return res, nil
+}
+
+func init() {
+	benchCmd.Flags().StringVar(&benchRange, "range", "3000-3999", "Port range for pick and probe")
+	benchCmd.Flags().IntVar(&benchIterations, "iterations", 5, "Runs per step")
+	rootCmd.AddCommand(benchCmd)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunBenchReport(t *testing.T) {
+	calls := 0
+	res, err := runBench("probe", 3, 1000, func() error {
+		calls++
+		time.Sleep(time.Duration(calls) * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runBench: %v", err)
+	}
+	if calls != 3 || res.Iterations != 3 || res.Name != "probe" {
+		t.Fatalf("unexpected run count/metadata: calls=%d %+v", calls, res)
+	}
+	if !(res.MinMS > 0 && res.MinMS <= res.MeanMS && res.MeanMS <= res.MaxMS) {
+		t.Fatalf("expected 0 < min <= mean <= max, got %+v", res)
+	}
+	if res.MinMS < 1 || res.MaxMS < 3 {
+		t.Fatalf("timings shorter than the sleeps: %+v", res)
+	}
+	if want := 1000 / (res.MeanMS / 1000); res.PortsPerSec < want*0.99 || res.PortsPerSec > want*1.01 {
+		t.Fatalf("ports/s = %.1f, want about %.1f", res.PortsPerSec, want)
+	}
+}
+
+func TestRunBenchStopsOnError(t *testing.T) {
+	if _, err := runBench("scan", 3, 0, func() error { return errors.New("boom") }); err == nil {
+		t.Fatalf("expected error")
+	}
+}
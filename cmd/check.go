@@ -4,31 +4,89 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strconv"
 	"time"
 
-	"fp/internal/scan"
-	"fp/internal/ui"
+	"github.com/mtreilly/freeport/internal/config"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
 	"github.com/spf13/cobra"
 )
 
-var checkWait time.Duration
+var (
+	checkWait  time.Duration
+	checkProbe bool
+	checkFast  bool
+
+	checkConnect bool
+	checkHost    string
+
+	checkAssumeFree  bool
+	checkAssumeInUse bool
+
+	checkRegistryFile bool
+)
 
 var checkCmd = &cobra.Command{
 	Use:   "check <port>",
 	Short: "Check if a TCP port is free (exit 0 if free, 1 if in-use, 2 on error)",
-	Args:  cobra.ExactArgs(1),
+	Long: `Check if a TCP port is free (exit 0 if free, 1 if in-use, 2 on error).
+
+By default the port is in use if a listener scan (lsof/ss) finds it. The
+scan asks about this port only, falling back to listing every socket if
+that query fails; --fast never falls back.
+
+With --probe, fp also tries to bind the port and reports in-use if either
+check says so. Neither check is enough alone: a bind can fail for ports the
+scanner can't see (other network namespaces, missing permissions), and on
+Linux a bind can succeed even though another process is serving the port
+with SO_REUSEPORT.
+
+With --connect, the port is in use if a TCP connection to --host (default
+127.0.0.1) succeeds; nothing is scanned, so it works for other hosts too.
+IPv6 link-local hosts need a zone: --host fe80::1%eth0.
+
+With --registry and no port, fp checks every port in the project's
+freeport.ports file (found in the working directory or a parent) and exits 1
+if any is held by something other than its expected command.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if checkRegistryFile {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		port, err := strconv.Atoi(args[0])
-		if err != nil || port < 1 || port > 65535 {
-			fmt.Fprintf(ui.Stderr(), "%s invalid port: %q\n", ui.LabelErr(ui.Stderr()), args[0])
-			os.Exit(2)
+		if checkRegistryFile {
+			runCheckRegistry(cmd)
+			return
 		}
-
-		inUse, err := waitForPortFree(port, checkWait)
+		port, err := parsePortArg(args[0])
 		if err != nil {
+			fmt.Fprintf(ui.Stderr(), "%s %v\n", ui.LabelErr(ui.Stderr()), err)
+			exit(2)
+		}
+
+		if cmd.Flags().Changed("host") && !checkConnect {
+			fmt.Fprintf(ui.Stderr(), "%s --host needs --connect\n", ui.LabelErr(ui.Stderr()))
+			exit(2)
+		}
+		if checkConnect && (checkProbe || checkFast) {
+			fmt.Fprintf(ui.Stderr(), "%s --connect can't be combined with --probe or --fast\n", ui.LabelErr(ui.Stderr()))
+			exit(2)
+		}
+		if checkAssumeFree && checkAssumeInUse {
+			fmt.Fprintf(ui.Stderr(), "%s --assume-free and --assume-in-use are mutually exclusive\n", ui.LabelErr(ui.Stderr()))
+			exit(2)
+		}
+		assumed := checkAssumeFree || checkAssumeInUse
+
+		var inUse bool
+		if assumed {
+			// Test override for scripts that consume check: nothing is scanned.
+			inUse = checkAssumeInUse
+			fmt.Fprintf(ui.Stderr(), "%s test override: port %d not checked, reporting it as assumed\n", ui.LabelWarn(ui.Stderr()), port)
+		} else if inUse, err = waitForPortFree(port, checkWait); err != nil {
 			fmt.Fprintf(ui.Stderr(), "%s check failed: %v\n", ui.LabelErr(ui.Stderr()), err)
-			os.Exit(2)
+			exit(2)
 		}
 
 		status := "free"
@@ -39,33 +97,116 @@ var checkCmd = &cobra.Command{
 		}
 
 		if jsonOutput {
-			_ = scan.WriteJSON(os.Stdout, map[string]any{
+			result := map[string]any{
 				"port":   port,
 				"status": status,
 				"in_use": inUse,
-			})
+			}
+			if assumed {
+				result["assumed"] = true
+			}
+			_ = writeJSON(os.Stdout, result)
+		} else if assumed {
+			fmt.Fprintf(ui.Stdout(), "port %d: %s %s\n", port, statusStyled, ui.Muted(ui.Stdout(), "(assumed)"))
 		} else {
 			fmt.Fprintf(ui.Stdout(), "port %d: %s\n", port, statusStyled)
 		}
 
 		if inUse {
-			os.Exit(1)
+			exit(1)
 		}
 	},
 }
 
 func init() {
 	checkCmd.Flags().DurationVar(&checkWait, "wait", 0, "Wait for port to become free (e.g., 2s)")
+	checkCmd.Flags().BoolVar(&checkFast, "fast", false, "Only ever run the port-scoped lsof/ss query, with no full-scan fallback")
+	checkCmd.Flags().BoolVar(&checkProbe, "probe", false, "Also try binding the port; in-use if either the bind or the scan says so")
+	checkCmd.Flags().BoolVar(&checkConnect, "connect", false, "Check by connecting to --host instead of scanning; in-use if the connection is accepted")
+	checkCmd.Flags().StringVar(&checkHost, "host", "127.0.0.1", "With --connect, the host to connect to (IPv6 zones like fe80::1%eth0 allowed)")
+	checkCmd.Flags().BoolVar(&checkAssumeFree, "assume-free", false, "Testing only: skip the check and report the port free (exit 0)")
+	checkCmd.Flags().BoolVar(&checkAssumeInUse, "assume-in-use", false, "Testing only: skip the check and report the port in use (exit 1)")
+	checkCmd.Flags().BoolVar(&checkRegistryFile, "registry", false, "Check every port in the project's freeport.ports instead of one port")
+	_ = checkCmd.Flags().MarkHidden("assume-free")
+	_ = checkCmd.Flags().MarkHidden("assume-in-use")
+}
+
+// runCheckRegistry is check --registry: exit 1 if any registry port is held
+// by an unexpected command.
+func runCheckRegistry(cmd *cobra.Command) {
+	for _, name := range []string{"wait", "fast", "probe", "connect", "host", "assume-free", "assume-in-use"} {
+		if cmd.Flags().Changed(name) {
+			fmt.Fprintf(ui.Stderr(), "%s --registry can't be combined with --%s\n", ui.LabelErr(ui.Stderr()), name)
+			exit(2)
+		}
+	}
+	reg, err := loadRegistry()
+	if err == nil && reg.Path == "" {
+		err = fmt.Errorf("no %s in this directory or its parents", config.RegistryFile)
+	}
+	if err != nil {
+		fmt.Fprintf(ui.Stderr(), "%s %v\n", ui.LabelErr(ui.Stderr()), err)
+		exit(2)
+	}
+	statuses, err := checkRegistry(context.Background(), reg)
+	if err != nil {
+		fmt.Fprintf(ui.Stderr(), "%s check failed: %v\n", ui.LabelErr(ui.Stderr()), err)
+		exit(2)
+	}
+
+	conflicts := 0
+	for _, st := range statuses {
+		if st.Status == "conflict" {
+			conflicts++
+		}
+	}
+	if jsonOutput {
+		_ = writeJSON(os.Stdout, map[string]any{
+			"registry":  reg.Path,
+			"entries":   statuses,
+			"conflicts": conflicts,
+		})
+	} else {
+		writeRegistryStatus(ui.Stdout(), statuses)
+	}
+	if conflicts > 0 {
+		exit(1)
+	}
+}
+
+// portInUse reports whether port is taken according to the scanner and, with
+// --probe, a bind attempt. --connect replaces both with a connect to --host.
+func portInUse(ctx context.Context, port int) (bool, error) {
+	if checkConnect {
+		return connectTCP(checkHost, port)
+	}
+	var inUse bool
+	var err error
+	if checkFast {
+		var listeners []scan.Listener
+		listeners, err = queryTCPPort(ctx, port)
+		inUse = len(listeners) > 0
+	} else {
+		inUse, err = hasTCPListenerOnPort(ctx, port)
+	}
+	if err != nil || inUse || !checkProbe {
+		return inUse, err
+	}
+	free, err := probeTCPPort(port)
+	if err != nil {
+		return false, err
+	}
+	return !free, nil
 }
 
 func waitForPortFree(port int, wait time.Duration) (bool, error) {
 	if wait <= 0 {
-		return scan.HasTCPListenerOnPort(context.Background(), port)
+		return portInUse(context.Background(), port)
 	}
 
 	deadline := time.Now().Add(wait)
 	for {
-		inUse, err := scan.HasTCPListenerOnPort(context.Background(), port)
+		inUse, err := portInUse(context.Background(), port)
 		if err != nil {
 			return false, err
 		}
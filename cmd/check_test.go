@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPortInUseCombinesScanAndProbe(t *testing.T) {
+	cases := []struct {
+		name     string
+		scanned  bool
+		bindable bool
+		probe    bool
+		want     bool
+	}{
+		{"reuseport: bind succeeds but scan finds listener", true, true, true, true},
+		{"bind fails, scanner blind", false, false, true, true},
+		{"both free", false, true, true, false},
+		{"bind failure ignored without --probe", false, false, false, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stubPortChecks(t, tc.scanned, tc.bindable)
+			checkProbe = tc.probe
+			t.Cleanup(func() { checkProbe = false })
+
+			got, err := portInUse(context.Background(), 3000)
+			if err != nil {
+				t.Fatalf("portInUse: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected in-use=%v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func stubPortChecks(t *testing.T, scanned, bindable bool) {
+	t.Helper()
+	origScan, origProbe := hasTCPListenerOnPort, probeTCPPort
+	hasTCPListenerOnPort = func(context.Context, int) (bool, error) { return scanned, nil }
+	probeTCPPort = func(int) (bool, error) { return bindable, nil }
+	t.Cleanup(func() {
+		hasTCPListenerOnPort, probeTCPPort = origScan, origProbe
+	})
+}
@@ -1,14 +1,21 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestCheckExitCodes(t *testing.T) {
@@ -55,6 +62,180 @@ func TestRunSetsPort(t *testing.T) {
 	}
 }
 
+func TestRunBannerModes(t *testing.T) {
+	bin := buildCLI(t)
+
+	code, _, errOut := runCLI(bin, "run", "--quiet", "--", "true")
+	if code != 0 || errOut != "" {
+		t.Fatalf("expected silent run with --quiet, got %d (stderr=%q)", code, errOut)
+	}
+
+	code, out, errOut := runCLI(bin, "run", "--json", "--env", "APP_PORT", "--", "/bin/sh", "-c", "echo \"$APP_PORT\"")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d (stderr=%q)", code, errOut)
+	}
+	var banner struct {
+		Port int    `json:"port"`
+		Env  string `json:"env"`
+	}
+	if err := json.Unmarshal([]byte(errOut), &banner); err != nil {
+		t.Fatalf("expected a JSON banner on stderr, got %q: %v", errOut, err)
+	}
+	if banner.Env != "APP_PORT" || itoa(banner.Port) != strings.TrimSpace(out) {
+		t.Fatalf("banner %+v doesn't match the child's port %q", banner, out)
+	}
+
+	code, out, errOut = runCLI(bin, "run", "--banner", "listening on {port} ({env})", "--", "/bin/sh", "-c", "echo \"$PORT\"")
+	if code != 0 || errOut != "listening on "+strings.TrimSpace(out)+" (PORT)\n" {
+		t.Fatalf("expected templated banner, got %d (out=%q err=%q)", code, out, errOut)
+	}
+}
+
+func TestRunRelaysSignalsToChild(t *testing.T) {
+	bin := buildCLI(t)
+
+	// --restart would respawn a child that exits non-zero; a relayed
+	// signal must stop the loop instead.
+	script := `trap 'echo relayed; exit 3' TERM; echo ready; while :; do sleep 0.05; done`
+	c := exec.Command(bin, "run", "--quiet", "--restart", "--range", "45100-45199", "--prefer", "45100", "--", "/bin/sh", "-c", script)
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	lines := bufio.NewScanner(stdout)
+	if !lines.Scan() || lines.Text() != "ready" {
+		_ = c.Process.Kill()
+		t.Fatalf("child didn't start: %q", lines.Text())
+	}
+	if err := c.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	// An orphaned child would keep stdout open, so read with a deadline.
+	var rest []string
+	done := make(chan error, 1)
+	go func() {
+		for lines.Scan() {
+			rest = append(rest, lines.Text())
+		}
+		done <- c.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		_ = c.Process.Kill()
+		t.Fatalf("fp and its child didn't exit after SIGTERM")
+	}
+	if strings.Join(rest, "\n") != "relayed" {
+		t.Fatalf("expected the child to get TERM once and not restart, got %q", rest)
+	}
+}
+
+func TestRunPropagatesChildExitCode(t *testing.T) {
+	bin := buildCLI(t)
+
+	code, _, errOut := runCLI(bin, "run", "--", "/bin/sh", "-c", "exit 3")
+	if code != 3 {
+		t.Fatalf("expected the child's exit 3, got %d (stderr=%q)", code, errOut)
+	}
+	if !strings.Contains(errOut, "fp: command exited with status 3") || strings.Contains(errOut, "Usage:") {
+		t.Fatalf("expected a one-line exit note without usage, got %q", errOut)
+	}
+
+	code, _, errOut = runCLI(bin, "run", "--quiet", "--", "/bin/sh", "-c", "kill -TERM $$")
+	if code != 128+int(syscall.SIGTERM) || errOut != "" {
+		t.Fatalf("expected 143 and a silent --quiet, got %d (stderr=%q)", code, errOut)
+	}
+}
+
+func TestRunMissingCommandFailsBeforePickingPort(t *testing.T) {
+	bin := buildCLI(t)
+
+	for _, name := range []string{"fp-no-such-command", "./fp-no-such-command", "/"} {
+		code, _, errOut := runCLI(bin, "run", "--", name)
+		if code == 0 {
+			t.Fatalf("%s: expected non-zero exit", name)
+		}
+		if !strings.Contains(errOut, "command") || strings.Contains(errOut, "using port") {
+			t.Fatalf("%s: expected preflight error before port selection, got %q", name, errOut)
+		}
+	}
+}
+
+func TestRunExecPassesEnv(t *testing.T) {
+	bin := buildCLI(t)
+
+	code, out, errOut := runCLI(bin, "run", "--exec", "--env", "APP_PORT", "--", "/bin/sh", "-c", "echo \"$APP_PORT\"")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d (stderr=%q)", code, errOut)
+	}
+	port := strings.TrimSpace(out)
+	if port == "" || !strings.Contains(errOut, "fp: using port "+port) {
+		t.Fatalf("expected exec'd process to see the chosen port, got out=%q err=%q", out, errOut)
+	}
+}
+
+func TestRunExecRejectsRestart(t *testing.T) {
+	bin := buildCLI(t)
+
+	code, _, errOut := runCLI(bin, "run", "--exec", "--restart", "--", "true")
+	if code == 0 || !strings.Contains(errOut, "mutually exclusive") {
+		t.Fatalf("expected --exec/--restart conflict, got %d (stderr=%q)", code, errOut)
+	}
+}
+
+func TestRunActivatePassesBoundSocket(t *testing.T) {
+	bin := buildCLI(t)
+
+	t.Setenv("FP_ACTIVATION_CHILD", "1")
+	code, out, errOut := runCLI(bin, "run", "--activate", "--", os.Args[0], "-test.run=^TestActivationChild$")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d (out=%q err=%q)", code, out, errOut)
+	}
+	if !strings.Contains(out, "activated port=") {
+		t.Fatalf("child did not report activation: out=%q err=%q", out, errOut)
+	}
+	port := strings.Fields(out[strings.Index(out, "activated port=")+len("activated port="):])[0]
+	if !strings.Contains(errOut, "fp: using port "+port) {
+		t.Fatalf("child got port %s, fp chose otherwise: %q", port, errOut)
+	}
+}
+
+// TestActivationChild is the activation-aware server for
+// TestRunActivatePassesBoundSocket; it is a no-op in a normal test run.
+func TestActivationChild(t *testing.T) {
+	if os.Getenv("FP_ACTIVATION_CHILD") != "1" || os.Getenv("LISTEN_FDS") == "" {
+		t.Skip("helper process")
+	}
+	if os.Getenv("LISTEN_FDS") != "1" || os.Getenv("LISTEN_PID") != itoa(os.Getpid()) {
+		t.Fatalf("bad activation env: LISTEN_FDS=%q LISTEN_PID=%q pid=%d", os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_PID"), os.Getpid())
+	}
+	ln, err := net.FileListener(os.NewFile(3, "listen"))
+	if err != nil {
+		t.Fatalf("fd 3 is not a listener: %v", err)
+	}
+	defer ln.Close()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	if itoa(port) != os.Getenv("PORT") {
+		t.Fatalf("socket port %d does not match PORT=%q", port, os.Getenv("PORT"))
+	}
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial inherited socket: %v", err)
+	}
+	conn.Close()
+	fmt.Printf("activated port=%d\n", port)
+}
+
 func TestListUniqueFiltersDuplicates(t *testing.T) {
 	bin := buildCLI(t)
 
@@ -76,6 +257,31 @@ func TestListUniqueFiltersDuplicates(t *testing.T) {
 	}
 }
 
+func TestPickCountStaysInRange(t *testing.T) {
+	bin := buildCLI(t)
+
+	code, out, errOut := runCLI(bin, "pick", "--range", "45000-45010", "--count", "5")
+	if code != 0 {
+		t.Fatalf("expected exit 0, got %d (err=%q)", code, errOut)
+	}
+	seen := map[int]bool{}
+	for _, f := range strings.Fields(out) {
+		p, err := strconv.Atoi(f)
+		if err != nil || p < 45000 || p > 45010 || seen[p] {
+			t.Fatalf("expected 5 distinct ports in 45000-45010, got %q", out)
+		}
+		seen[p] = true
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected 5 ports, got %q", out)
+	}
+
+	code, _, errOut = runCLI(bin, "pick", "--range", "45000-45001", "--count", "5")
+	if code == 0 || !strings.Contains(errOut, "of 5 free TCP ports found") {
+		t.Fatalf("expected a shortfall error, got %d (err=%q)", code, errOut)
+	}
+}
+
 func TestKillDryRunDoesNotError(t *testing.T) {
 	bin := buildCLI(t)
 
@@ -95,6 +301,185 @@ func TestKillDryRunDoesNotError(t *testing.T) {
 	}
 }
 
+func TestExitZeroOnNotFound(t *testing.T) {
+	bin := buildCLI(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := itoa(ln.Addr().(*net.TCPAddr).Port)
+	if code, _, errOut := runCLI(bin, "who", port, "--check"); code != 0 {
+		t.Fatalf("expected exit 0 for an occupied port, got %d (err=%q)", code, errOut)
+	}
+	if err := ln.Close(); err != nil {
+		t.Fatalf("close listener: %v", err)
+	}
+
+	cases := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"who", port}, 0},
+		{[]string{"who", port, "--check"}, 1},
+		{[]string{"who", port, "--check", "--json"}, 1},
+		{[]string{"who", port, "--check", "--exit-zero-on-not-found"}, 0},
+		{[]string{"kill", port}, 0},
+		{[]string{"kill", port, "--exit-zero-on-not-found"}, 0},
+		{[]string{"kill", "--name", "fp-no-such-command"}, 0},
+		{[]string{"kill", "--name", "fp-no-such-command", "--exit-zero-on-not-found"}, 0},
+	}
+	for _, tc := range cases {
+		if code, out, errOut := runCLI(bin, tc.args...); code != tc.want {
+			t.Fatalf("fp %s: expected exit %d, got %d (out=%q err=%q)", strings.Join(tc.args, " "), tc.want, code, out, errOut)
+		}
+	}
+}
+
+func TestProfileAndTraceFlagsWriteFiles(t *testing.T) {
+	bin := buildCLI(t)
+	dir := t.TempDir()
+	prof, tr := filepath.Join(dir, "cpu.prof"), filepath.Join(dir, "fp.trace")
+
+	if code, _, errOut := runCLI(bin, "list", "--profile", prof); code != 0 {
+		t.Fatalf("list --profile: exit %d (err=%q)", code, errOut)
+	}
+	f, err := os.Open(prof)
+	if err != nil {
+		t.Fatalf("profile not written: %v", err)
+	}
+	defer f.Close()
+	// A pprof profile is a gzipped protobuf with sample_type (field 1) set.
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("profile is not gzipped: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("read profile: %v", err)
+	}
+	if fields, ok := protobufFields(data); !ok || !fields[1] {
+		t.Fatalf("profile is not a pprof protobuf (%d bytes, fields %v)", len(data), fields)
+	}
+
+	// check exits 1 on a busy port, bypassing cobra; the trace must still
+	// be flushed.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	if code, _, errOut := runCLI(bin, "check", strconv.Itoa(port), "--trace", tr); code != 1 {
+		t.Fatalf("check --trace: exit %d, want 1 (err=%q)", code, errOut)
+	}
+	if data, err := os.ReadFile(tr); err != nil || !bytes.HasPrefix(data, []byte("go 1.")) {
+		t.Fatalf("expected a Go execution trace, got err=%v prefix=%q", err, data[:min(len(data), 16)])
+	}
+}
+
+// protobufFields walks the top-level fields of a protobuf message, reporting
+// which field numbers occur and whether the whole message parsed.
+func protobufFields(data []byte) (map[uint64]bool, bool) {
+	varint := func() (uint64, bool) {
+		var v uint64
+		for shift := 0; shift < 64 && len(data) > 0; shift += 7 {
+			b := data[0]
+			data = data[1:]
+			v |= uint64(b&0x7f) << shift
+			if b < 0x80 {
+				return v, true
+			}
+		}
+		return 0, false
+	}
+	fields := map[uint64]bool{}
+	for len(data) > 0 {
+		tag, ok := varint()
+		if !ok {
+			return fields, false
+		}
+		switch tag & 7 {
+		case 0:
+			if _, ok := varint(); !ok {
+				return fields, false
+			}
+		case 2:
+			n, ok := varint()
+			if !ok || n > uint64(len(data)) {
+				return fields, false
+			}
+			data = data[n:]
+		default:
+			return fields, false
+		}
+		fields[tag>>3] = true
+	}
+	return fields, true
+}
+
+func TestFreeportArgsEnvAppliesAndIsOverridden(t *testing.T) {
+	bin := buildCLI(t)
+
+	t.Setenv("FREEPORT_ARGS", "--format json-array-compact --timeout 5s")
+	code, out, errOut := runCLI(bin, "list")
+	if code != 0 || !strings.HasPrefix(out, "[") || strings.Contains(out, "PORT\t") {
+		t.Fatalf("expected FREEPORT_ARGS to select compact JSON, got %d out=%q err=%q", code, out, errOut)
+	}
+
+	code, out, errOut = runCLI(bin, "list", "--format", "table")
+	if code != 0 || !strings.HasPrefix(out, "PORT") {
+		t.Fatalf("expected explicit --format to win, got %d out=%q err=%q", code, out, errOut)
+	}
+
+	// pick has no --timeout; the flag is skipped rather than rejected.
+	code, _, errOut = runCLI(bin, "pick", "--format", "text")
+	if code != 0 {
+		t.Fatalf("expected flags pick lacks to be skipped, got %d err=%q", code, errOut)
+	}
+}
+
+func TestPickHoldThenRelease(t *testing.T) {
+	bin := buildCLI(t)
+	// Keep the holder's lock file out of the real cache directory.
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	t.Setenv("HOME", cache)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	free := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	code, out, errOut := runCLI(bin, "pick", "--hold", "--prefer", itoa(free), "--range", itoa(free)+"-"+itoa(free), "--json")
+	if code != 0 {
+		t.Fatalf("pick --hold: exit %d (err=%q)", code, errOut)
+	}
+	var held struct{ Port, PID int }
+	if err := json.Unmarshal([]byte(out), &held); err != nil || held.Port != free || held.PID <= 0 {
+		t.Fatalf("unexpected pick --hold output %q (err=%v)", out, err)
+	}
+	// pick has exited; the detached holder must still have the lock.
+	if err := syscall.Kill(held.PID, 0); err != nil {
+		t.Fatalf("holder pid %d not running after pick exited: %v", held.PID, err)
+	}
+	if code, _, _ := runCLI(bin, "reserve", itoa(free), "--duration", "1s"); code == 0 {
+		t.Fatalf("expected reserve to fail while the port is held")
+	}
+
+	code, _, errOut = runCLI(bin, "release", itoa(free))
+	if code != 0 {
+		_ = syscall.Kill(held.PID, syscall.SIGKILL)
+		t.Fatalf("release: exit %d (err=%q)", code, errOut)
+	}
+	code, out, _ = runCLI(bin, "locks", "--json")
+	if code != 0 || strings.Contains(out, fmt.Sprintf(`"port": %d,`, free)) {
+		t.Fatalf("expected the holder to remove its lock file on release, got %q", out)
+	}
+}
+
 func buildCLI(t *testing.T) string {
 	t.Helper()
 	cwd, err := os.Getwd()
@@ -147,3 +532,70 @@ func nonEmptyLines(s string) []string {
 	}
 	return lines
 }
+
+func TestPlainOutputIsASCII(t *testing.T) {
+	bin := buildCLI(t)
+
+	dir := t.TempDir()
+	before := filepath.Join(dir, "before.json")
+	after := filepath.Join(dir, "after.json")
+	if err := os.WriteFile(before, []byte(`[{"port":3000,"pid":1,"command":"nöde","proto":"tcp","address":"*:3000"}]`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(after, []byte(`[{"port":3000,"pid":2,"command":"nöde","proto":"tcp","address":"*:3000"},{"port":4000,"pid":3,"command":"José","proto":"tcp","address":"*:4000"}]`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"diff", before, after, "--plain"},
+		{"list", "--plain"},
+		{"doctor", "--plain"},
+	} {
+		code, out, errOut := runCLI(bin, args...)
+		if code != 0 {
+			t.Fatalf("%v: expected exit 0, got %d (err=%q)", args, code, errOut)
+		}
+		for i, b := range []byte(out + errOut) {
+			if b > 127 || (b < 32 && b != '\n' && b != '\t') {
+				t.Fatalf("%v: non-ASCII or control byte %#x at %d in %q", args, b, i, out)
+			}
+		}
+	}
+}
+
+func TestCheckAssumeOverrides(t *testing.T) {
+	bin := buildCLI(t)
+
+	cases := []struct {
+		flag     string
+		wantCode int
+		status   string
+	}{
+		{"--assume-free", 0, "free"},
+		{"--assume-in-use", 1, "in-use"},
+	}
+	for _, tc := range cases {
+		code, out, errOut := runCLI(bin, "check", "1", tc.flag, "--json")
+		if code != tc.wantCode {
+			t.Fatalf("%s: expected exit %d, got %d (err=%q)", tc.flag, tc.wantCode, code, errOut)
+		}
+		var got struct {
+			Status  string `json:"status"`
+			InUse   bool   `json:"in_use"`
+			Assumed bool   `json:"assumed"`
+		}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("%s: invalid JSON %q: %v", tc.flag, out, err)
+		}
+		if got.Status != tc.status || got.InUse != (tc.wantCode == 1) || !got.Assumed {
+			t.Fatalf("%s: unexpected result %+v", tc.flag, got)
+		}
+		if !strings.Contains(errOut, "test override") {
+			t.Fatalf("%s: expected a test-override warning on stderr, got %q", tc.flag, errOut)
+		}
+	}
+
+	if code, _, _ := runCLI(bin, "check", "1", "--assume-free", "--assume-in-use"); code != 2 {
+		t.Fatalf("expected exit 2 for conflicting overrides, got %d", code)
+	}
+}
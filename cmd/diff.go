@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var diffFailOn string
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <before.json> <after.json>",
+	Short: "Compare two `fp list --json` snapshots",
+	Long: `Compare two snapshots produced by "fp list --json".
+
+Listeners are matched by protocol and address. A socket present only in
+the second snapshot is "added", only in the first is "removed", and one
+whose owning PIDs or commands differ is "changed".
+
+With --fail-on, exit 1 when the chosen class of change is non-empty, which
+turns diff into a CI gate. The default always exits 0.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !validFailOn(diffFailOn) {
+			fmt.Fprintf(ui.Stderr(), "%s invalid --fail-on %q (expected added, removed, changed, or any)\n", ui.LabelErr(ui.Stderr()), diffFailOn)
+			exit(2)
+		}
+
+		before, err := readSnapshot(args[0])
+		if err != nil {
+			fmt.Fprintf(ui.Stderr(), "%s %v\n", ui.LabelErr(ui.Stderr()), err)
+			exit(2)
+		}
+		after, err := readSnapshot(args[1])
+		if err != nil {
+			fmt.Fprintf(ui.Stderr(), "%s %v\n", ui.LabelErr(ui.Stderr()), err)
+			exit(2)
+		}
+
+		d := diffListeners(before, after)
+		if jsonOutput {
+			_ = writeJSON(os.Stdout, d)
+		} else {
+			printDiff(d)
+		}
+
+		if d.fails(diffFailOn) {
+			exit(1)
+		}
+	},
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffFailOn, "fail-on", "", "Exit 1 when this class is non-empty (added, removed, changed, any)")
+	rootCmd.AddCommand(diffCmd)
+}
+
+type listenerChange struct {
+	Key    string          `json:"key"`
+	Before []scan.Listener `json:"before"`
+	After  []scan.Listener `json:"after"`
+}
+
+type listenerDiff struct {
+	Added   []scan.Listener  `json:"added"`
+	Removed []scan.Listener  `json:"removed"`
+	Changed []listenerChange `json:"changed"`
+}
+
+func validFailOn(s string) bool {
+	switch s {
+	case "", "added", "removed", "changed", "any":
+		return true
+	}
+	return false
+}
+
+func (d listenerDiff) fails(failOn string) bool {
+	switch failOn {
+	case "added":
+		return len(d.Added) > 0
+	case "removed":
+		return len(d.Removed) > 0
+	case "changed":
+		return len(d.Changed) > 0
+	case "any":
+		return len(d.Added)+len(d.Removed)+len(d.Changed) > 0
+	}
+	return false
+}
+
+// readSnapshot accepts a plain listener array or the {"listeners": [...]}
+// object written by list --ignore-errors --json.
+func readSnapshot(path string) ([]scan.Listener, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var listeners []scan.Listener
+	if err := json.Unmarshal(data, &listeners); err == nil {
+		return listeners, nil
+	}
+	var wrapped struct {
+		Listeners []scan.Listener `json:"listeners"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return wrapped.Listeners, nil
+}
+
+func diffListeners(before, after []scan.Listener) listenerDiff {
+	byKey := func(ls []scan.Listener) map[string][]scan.Listener {
+		m := make(map[string][]scan.Listener)
+		for _, l := range ls {
+			m[l.Key()] = append(m[l.Key()], l)
+		}
+		return m
+	}
+	b, a := byKey(before), byKey(after)
+
+	d := listenerDiff{
+		Added:   []scan.Listener{},
+		Removed: []scan.Listener{},
+		Changed: []listenerChange{},
+	}
+	for key, ls := range a {
+		prev, ok := b[key]
+		if !ok {
+			d.Added = append(d.Added, ls...)
+			continue
+		}
+		if owners(prev) != owners(ls) {
+			d.Changed = append(d.Changed, listenerChange{Key: key, Before: prev, After: ls})
+		}
+	}
+	for key, ls := range b {
+		if _, ok := a[key]; !ok {
+			d.Removed = append(d.Removed, ls...)
+		}
+	}
+
+	sortByKey := func(ls []scan.Listener) {
+		sort.Slice(ls, func(i, j int) bool {
+			if ls[i].Port != ls[j].Port {
+				return ls[i].Port < ls[j].Port
+			}
+			return ls[i].Key() < ls[j].Key()
+		})
+	}
+	sortByKey(d.Added)
+	sortByKey(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Key < d.Changed[j].Key })
+	return d
+}
+
+// owners summarizes who holds a socket, for change detection.
+func owners(ls []scan.Listener) string {
+	parts := make([]string, 0, len(ls))
+	for _, l := range ls {
+		parts = append(parts, fmt.Sprintf("%d/%s", l.PID, l.Command))
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, ",")
+}
+
+func printDiff(d listenerDiff) {
+	out := ui.Stdout()
+	if len(d.Added)+len(d.Removed)+len(d.Changed) == 0 {
+		fmt.Fprintf(out, "%s no changes\n", ui.LabelOK(out))
+		return
+	}
+	for _, l := range d.Added {
+		fmt.Fprintf(out, "%s %s %s (pid %d)\n", ui.Success(out, "+"), l.Key(), l.Command, l.PID)
+	}
+	for _, l := range d.Removed {
+		fmt.Fprintf(out, "%s %s %s (pid %d)\n", ui.Error(out, "-"), l.Key(), l.Command, l.PID)
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(out, "%s %s %s %s %s\n", ui.Warning(out, "~"), c.Key, owners(c.Before), ui.Symbols().Arrow, owners(c.After))
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+func TestDiffFailOnModes(t *testing.T) {
+	before := []scan.Listener{
+		{Port: 3000, PID: 10, Command: "node", Proto: "tcp", Address: "*:3000"},
+		{Port: 5432, PID: 20, Command: "postgres", Proto: "tcp", Address: "127.0.0.1:5432"},
+		{Port: 6379, PID: 30, Command: "redis", Proto: "tcp", Address: "127.0.0.1:6379"},
+	}
+
+	cases := []struct {
+		name  string
+		after []scan.Listener
+		fails map[string]bool
+	}{
+		{
+			name:  "unchanged",
+			after: before,
+			fails: map[string]bool{"": false, "added": false, "removed": false, "changed": false, "any": false},
+		},
+		{
+			name:  "added",
+			after: append(append([]scan.Listener{}, before...), scan.Listener{Port: 8080, PID: 40, Command: "python", Proto: "tcp", Address: "*:8080"}),
+			fails: map[string]bool{"": false, "added": true, "removed": false, "changed": false, "any": true},
+		},
+		{
+			name:  "removed",
+			after: before[:2],
+			fails: map[string]bool{"": false, "added": false, "removed": true, "changed": false, "any": true},
+		},
+		{
+			name: "changed",
+			after: []scan.Listener{
+				{Port: 3000, PID: 11, Command: "node", Proto: "tcp", Address: "*:3000"},
+				before[1], before[2],
+			},
+			fails: map[string]bool{"": false, "added": false, "removed": false, "changed": true, "any": true},
+		},
+	}
+
+	dir := t.TempDir()
+	beforePath := writeSnapshot(t, dir, "before.json", before)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := readSnapshot(beforePath)
+			if err != nil {
+				t.Fatalf("readSnapshot: %v", err)
+			}
+			a, err := readSnapshot(writeSnapshot(t, dir, tc.name+".json", tc.after))
+			if err != nil {
+				t.Fatalf("readSnapshot: %v", err)
+			}
+			d := diffListeners(b, a)
+			for mode, want := range tc.fails {
+				if got := d.fails(mode); got != want {
+					t.Errorf("fail-on %q: expected %v, got %v (diff=%+v)", mode, want, got, d)
+				}
+			}
+		})
+	}
+}
+
+func TestReadSnapshotAcceptsWrappedListeners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wrapped.json")
+	data := `{"listeners":[{"port":3000,"pid":1}],"backends":[]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	ls, err := readSnapshot(path)
+	if err != nil {
+		t.Fatalf("readSnapshot: %v", err)
+	}
+	if len(ls) != 1 || ls[0].Port != 3000 {
+		t.Fatalf("unexpected listeners %+v", ls)
+	}
+}
+
+func writeSnapshot(t *testing.T, dir, name string, ls []scan.Listener) string {
+	t.Helper()
+	data, err := json.Marshal(ls)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
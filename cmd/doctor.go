@@ -3,64 +3,109 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net"
+	"os"
 	"os/exec"
+	"regexp"
 	"runtime"
+	"slices"
 	"time"
 
-	"fp/internal/scan"
-	"fp/internal/ui"
-	"github.com/muesli/termenv"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
 	"github.com/spf13/cobra"
 )
 
+var doctorStepTimeout time.Duration
+
+// Doctor step statuses. A timeout is reported separately from an error so a
+// hung tool is distinguishable from a missing or broken one.
+const (
+	statusOK      = "ok"
+	statusWarn    = "warn"
+	statusError   = "error"
+	statusTimeout = "timeout"
+)
+
+// Remediation codes attached to failed checks in doctor's JSON output, so
+// setup scripts can react (e.g. install the missing package) without
+// parsing the human text. The set is closed; new codes are additions.
+const (
+	remedyInstallLsof     = "install_lsof"
+	remedyInstallSS       = "install_ss"
+	remedyInstallLsofOrSS = "install_lsof_or_ss"
+	remedyInstallPS       = "install_ps"
+	remedyInstallKill     = "install_kill"
+	remedyCheckScanTool   = "check_scan_tool"
+	remedyCheckLoopback   = "check_loopback"
+	remedyIncreaseTimeout = "increase_timeout"
+)
+
+// doctorStep is one independently bounded diagnostic. Remediation is
+// reported whenever the step doesn't pass; Version, if set, runs after a
+// passing Run within the same timeout.
+type doctorStep struct {
+	Section     string
+	Name        string
+	Remediation string
+	Run         func(ctx context.Context) (status, detail string)
+	Version     func(ctx context.Context) string
+}
+
+type doctorResult struct {
+	Section     string `json:"section"`
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Detail      string `json:"detail,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Elapsed     int64  `json:"elapsed_ms"`
+}
+
 var doctorCmd = &cobra.Command{
 	Use:   "doctor",
 	Short: "Check system dependencies and configuration",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		results := withPortListerCheck(runDoctorSteps(context.Background(), doctorSteps(), doctorStepTimeout))
+		ready := doctorReady(results)
+
+		if jsonOutput {
+			return writeJSON(os.Stdout, map[string]any{
+				"system": map[string]string{
+					"os":   runtime.GOOS,
+					"arch": runtime.GOARCH,
+					"go":   runtime.Version(),
+				},
+				"checks": results,
+				"ready":  ready,
+			})
+		}
+
 		out := ui.Stdout()
 		fmt.Fprintf(out, "%s\n\n", ui.Header(out, "fp doctor"))
 
 		// System info
 		fmt.Fprintf(out, "%s\n", ui.Info(out, "System"))
 		fmt.Fprintf(out, "  OS:       %s/%s\n", runtime.GOOS, runtime.GOARCH)
-		fmt.Fprintf(out, "  Go:       %s\n\n", runtime.Version())
-
-		// Check port listing tools
-		fmt.Fprintf(out, "%s\n", ui.Info(out, "Port listing tools"))
-
-		hasLsof := checkTool("lsof", out)
-		hasSS := checkTool("ss", out)
-
-		if !hasLsof && !hasSS {
-			fmt.Fprintf(out, "\n  %s No port listing tool found. Install lsof or ss.\n", ui.LabelErr(out))
-		}
-		fmt.Fprintln(out)
-
-		// Check process tools
-		fmt.Fprintf(out, "%s\n", ui.Info(out, "Process tools"))
-		checkTool("ps", out)
-		checkTool("kill", out)
-		fmt.Fprintln(out)
+		fmt.Fprintf(out, "  Go:       %s\n", runtime.Version())
 
-		// Test port scanning
-		fmt.Fprintf(out, "%s\n", ui.Info(out, "Port scanning"))
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		start := time.Now()
-		listeners, err := scan.ListTCPListeners(ctx)
-		elapsed := time.Since(start)
-
-		if err != nil {
-			fmt.Fprintf(out, "  %s %v\n", ui.LabelErr(out), err)
-		} else {
-			fmt.Fprintf(out, "  %s Found %d listeners in %v\n", ui.LabelOK(out), len(listeners), elapsed.Round(time.Millisecond))
+		section := ""
+		for _, r := range results {
+			if r.Section != section {
+				section = r.Section
+				fmt.Fprintf(out, "\n%s\n", ui.Info(out, section))
+			}
+			detail := r.Detail
+			if r.Version != "" {
+				detail += ", version " + r.Version
+			}
+			fmt.Fprintf(out, "  %s %s\n", doctorLabel(r.Status), detail)
 		}
 		fmt.Fprintln(out)
 
 		// Summary
 		fmt.Fprintf(out, "%s\n", ui.Info(out, "Status"))
-		if (hasLsof || hasSS) && err == nil {
+		if ready {
 			fmt.Fprintf(out, "  %s fp is ready to use\n", ui.LabelOK(out))
 		} else {
 			fmt.Fprintf(out, "  %s Some issues detected (see above)\n", ui.LabelWarn(out))
@@ -70,16 +115,204 @@ var doctorCmd = &cobra.Command{
 	},
 }
 
-func checkTool(name string, out *termenv.Output) bool {
-	path, err := exec.LookPath(name)
+func doctorSteps() []doctorStep {
+	return []doctorStep{
+		toolStep("Port listing tools", "lsof", remedyInstallLsof),
+		toolStep("Port listing tools", "ss", remedyInstallSS),
+		toolStep("Process tools", "ps", remedyInstallPS),
+		toolStep("Process tools", "kill", remedyInstallKill),
+		{Section: "Port scanning", Name: "scan", Remediation: remedyCheckScanTool, Run: scanStep},
+		{Section: "Port scanning", Name: "udp_scan", Remediation: remedyCheckScanTool, Run: udpScanStep},
+		{Section: "Port scanning", Name: "bind", Remediation: remedyCheckLoopback, Run: bindStep},
+	}
+}
+
+func toolStep(section, name, remediation string) doctorStep {
+	step := doctorStep{
+		Section:     section,
+		Name:        name,
+		Remediation: remediation,
+		Run: func(ctx context.Context) (string, string) {
+			path, err := exec.LookPath(name)
+			if err != nil {
+				return statusWarn, name + " not found"
+			}
+			return statusOK, fmt.Sprintf("%s (%s)", name, path)
+		},
+	}
+	if flag, ok := toolVersionFlags[name]; ok {
+		step.Version = func(ctx context.Context) string {
+			// Some builds print the version to stderr, or exit non-zero
+			// after printing it; the text is all that matters.
+			out, _ := exec.CommandContext(ctx, name, flag).CombinedOutput()
+			return parseToolVersion(name, string(out))
+		}
+	}
+	return step
+}
+
+// toolVersionFlags are the scan tools whose versions doctor reports, since
+// output-format quirks tend to follow tool versions.
+var toolVersionFlags = map[string]string{
+	"lsof": "-v",
+	"ss":   "--version",
+}
+
+var (
+	lsofRevision = regexp.MustCompile(`(?m)^\s*revision:\s*(\S+)`)
+	ssIproute    = regexp.MustCompile(`iproute2-(?:ss)?([0-9][0-9A-Za-z.]*)`)
+)
+
+// parseToolVersion extracts a version from "lsof -v" ("revision: 4.95.0")
+// or "ss --version" ("ss utility, iproute2-6.1.0", or the older
+// "iproute2-ss200127" snapshot form). Anything else is "unknown".
+func parseToolVersion(name, out string) string {
+	var re *regexp.Regexp
+	switch name {
+	case "lsof":
+		re = lsofRevision
+	case "ss":
+		re = ssIproute
+	}
+	if re != nil {
+		if m := re.FindStringSubmatch(out); m != nil {
+			return m[1]
+		}
+	}
+	return "unknown"
+}
+
+func scanStep(ctx context.Context) (string, string) {
+	start := time.Now()
+	listeners, err := scan.ListTCPListeners(ctx)
+	if err != nil {
+		return statusError, err.Error()
+	}
+	return statusOK, fmt.Sprintf("Found %d listeners in %v", len(listeners), time.Since(start).Round(time.Millisecond))
+}
+
+// udpScanStep enumerates bound UDP sockets, checking the UDP backend the
+// same way scanStep checks TCP. It doesn't affect doctorReady, since most
+// commands never scan UDP.
+func udpScanStep(ctx context.Context) (string, string) {
+	start := time.Now()
+	sockets, err := listUDPListeners(ctx)
 	if err != nil {
-		fmt.Fprintf(out, "  %s %s not found\n", ui.LabelWarn(out), name)
+		return statusError, "UDP scan failed: " + err.Error()
+	}
+	return statusOK, fmt.Sprintf("Found %d UDP sockets in %v", len(sockets), time.Since(start).Round(time.Millisecond))
+}
+
+func bindStep(ctx context.Context) (string, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return statusError, "cannot bind on 127.0.0.1: " + err.Error()
+	}
+	_ = ln.Close()
+	return statusOK, "Can bind on 127.0.0.1"
+}
+
+// runDoctorSteps runs each step under its own timeout. A step that doesn't
+// finish in time is reported as timed out and abandoned, so one hung tool
+// can't stall the rest of the diagnostics.
+func runDoctorSteps(ctx context.Context, steps []doctorStep, timeout time.Duration) []doctorResult {
+	results := make([]doctorResult, 0, len(steps))
+	for _, step := range steps {
+		results = append(results, runDoctorStep(ctx, step, timeout))
+	}
+	return results
+}
+
+func runDoctorStep(ctx context.Context, step doctorStep, timeout time.Duration) doctorResult {
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct{ status, detail, version string }
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		status, detail := step.Run(stepCtx)
+		var version string
+		if status == statusOK && step.Version != nil {
+			version = step.Version(stepCtx)
+		}
+		done <- outcome{status, detail, version}
+	}()
+
+	result := doctorResult{Section: step.Section, Name: step.Name}
+	select {
+	case o := <-done:
+		result.Status, result.Detail, result.Version = o.status, o.detail, o.version
+		if result.Status != statusOK {
+			result.Remediation = step.Remediation
+		}
+	case <-stepCtx.Done():
+		result.Status = statusTimeout
+		result.Detail = fmt.Sprintf("%s timed out after %s", step.Name, timeout)
+		result.Remediation = remedyIncreaseTimeout
+	}
+	result.Elapsed = time.Since(start).Milliseconds()
+	return result
+}
+
+// withPortListerCheck adds a failed "port_lister" check after the last port
+// listing tool when neither lsof nor ss is usable.
+func withPortListerCheck(results []doctorResult) []doctorResult {
+	if hasPortLister(results) {
+		return results
+	}
+	at := len(results)
+	for i, r := range results {
+		if r.Name == "lsof" || r.Name == "ss" {
+			at = i + 1
+		}
+	}
+	check := doctorResult{
+		Section:     "Port listing tools",
+		Name:        "port_lister",
+		Status:      statusError,
+		Detail:      "No port listing tool found. Install lsof or ss.",
+		Remediation: remedyInstallLsofOrSS,
+	}
+	return slices.Insert(results, at, check)
+}
+
+// doctorReady reports whether at least one port lister is present and the
+// TCP scan succeeded.
+func doctorReady(results []doctorResult) bool {
+	if !hasPortLister(results) {
 		return false
 	}
-	fmt.Fprintf(out, "  %s %s (%s)\n", ui.LabelOK(out), name, path)
-	return true
+	for _, r := range results {
+		if r.Name == "scan" {
+			return r.Status == statusOK
+		}
+	}
+	return false
+}
+
+func hasPortLister(results []doctorResult) bool {
+	for _, r := range results {
+		if (r.Name == "lsof" || r.Name == "ss") && r.Status == statusOK {
+			return true
+		}
+	}
+	return false
+}
+
+func doctorLabel(status string) string {
+	out := ui.Stdout()
+	switch status {
+	case statusOK:
+		return ui.LabelOK(out)
+	case statusWarn:
+		return ui.LabelWarn(out)
+	}
+	return ui.LabelErr(out)
 }
 
 func init() {
+	doctorCmd.Flags().DurationVar(&doctorStepTimeout, "timeout-per-tool", 5*time.Second, "Bound each check (tool lookup, TCP/UDP scan, bind test) independently")
+	addDumpRawFlag(doctorCmd)
 	rootCmd.AddCommand(doctorCmd)
 }
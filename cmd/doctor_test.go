@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+func TestRunDoctorStepsReportsTimeoutAndContinues(t *testing.T) {
+	steps := []doctorStep{
+		{Section: "Tools", Name: "slow", Run: func(ctx context.Context) (string, string) {
+			time.Sleep(2 * time.Second) // ignores ctx, like a hung LookPath
+			return statusOK, "too late"
+		}},
+		{Section: "Tools", Name: "fast", Run: func(ctx context.Context) (string, string) {
+			return statusOK, "fine"
+		}},
+	}
+
+	start := time.Now()
+	results := runDoctorSteps(context.Background(), steps, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("slow step blocked doctor for %v", elapsed)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	if results[0].Status != statusTimeout || results[0].Name != "slow" {
+		t.Fatalf("expected slow step to time out, got %+v", results[0])
+	}
+	if results[1].Status != statusOK || results[1].Detail != "fine" {
+		t.Fatalf("expected fast step to run, got %+v", results[1])
+	}
+}
+
+func TestDoctorReady(t *testing.T) {
+	ok := []doctorResult{{Name: "lsof", Status: statusWarn}, {Name: "ss", Status: statusOK}, {Name: "scan", Status: statusOK}}
+	if !doctorReady(ok) {
+		t.Fatalf("expected ready with ss and a good scan")
+	}
+	timedOut := []doctorResult{{Name: "ss", Status: statusOK}, {Name: "scan", Status: statusTimeout}}
+	if doctorReady(timedOut) {
+		t.Fatalf("expected not ready when the scan timed out")
+	}
+}
+
+func TestDoctorRemediationForMissingTools(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	var tools []doctorStep
+	for _, step := range doctorSteps() {
+		if step.Section == "Port listing tools" {
+			tools = append(tools, step)
+		}
+	}
+	results := withPortListerCheck(runDoctorSteps(context.Background(), tools, time.Second))
+
+	byName := map[string]doctorResult{}
+	for _, r := range results {
+		byName[r.Name] = r
+	}
+	if got := byName["lsof"].Remediation; got != remedyInstallLsof {
+		t.Fatalf("lsof remediation = %q, want %q", got, remedyInstallLsof)
+	}
+	lister, ok := byName["port_lister"]
+	if !ok || lister.Status != statusError || lister.Remediation != remedyInstallLsofOrSS {
+		t.Fatalf("expected failed port_lister check with %q, got %+v", remedyInstallLsofOrSS, results)
+	}
+	if results[len(results)-1].Name != "port_lister" {
+		t.Fatalf("expected port_lister right after the tool checks, got %+v", results)
+	}
+}
+
+func TestDoctorRemediationOmittedOnSuccess(t *testing.T) {
+	steps := []doctorStep{{Name: "fine", Remediation: remedyCheckLoopback, Run: func(ctx context.Context) (string, string) {
+		return statusOK, ""
+	}}}
+	if r := runDoctorSteps(context.Background(), steps, time.Second)[0]; r.Remediation != "" {
+		t.Fatalf("expected no remediation for a passing check, got %q", r.Remediation)
+	}
+}
+
+func TestParseToolVersion(t *testing.T) {
+	cases := []struct {
+		name, out, want string
+	}{
+		{"lsof", "lsof version information:\n    revision: 4.95.0\n    latest revision: https://github.com/lsof-org/lsof\n", "4.95.0"},
+		{"lsof", "lsof version information:\n    revision: 4.91\n    constructed: Thu Jan  1 00:00:00 UTC 2020\n", "4.91"},
+		{"ss", "ss utility, iproute2-6.1.0\n", "6.1.0"},
+		{"ss", "ss utility, iproute2-ss200127\n", "200127"},
+		{"ss", "ss utility, iproute2-5.15.0, libbpf 0.5.0\n", "5.15.0"},
+		{"ss", "ss: invalid option -- '-'\nUsage: ss [ OPTIONS ]\n", "unknown"},
+		{"lsof", "", "unknown"},
+	}
+	for _, tc := range cases {
+		if got := parseToolVersion(tc.name, tc.out); got != tc.want {
+			t.Errorf("parseToolVersion(%s, %q) = %q, want %q", tc.name, tc.out, got, tc.want)
+		}
+	}
+}
+
+func TestDoctorUDPScanStep(t *testing.T) {
+	orig := listUDPListeners
+	t.Cleanup(func() { listUDPListeners = orig })
+	step := doctorStep{Name: "udp_scan", Remediation: remedyCheckScanTool, Run: udpScanStep}
+
+	listUDPListeners = func(context.Context) ([]scan.Listener, error) {
+		return []scan.Listener{{Port: 53, Proto: "udp"}, {Port: 5353, Proto: "udp"}}, nil
+	}
+	r := runDoctorStep(context.Background(), step, time.Second)
+	if r.Status != statusOK || !strings.HasPrefix(r.Detail, "Found 2 UDP sockets in ") || r.Remediation != "" {
+		t.Fatalf("unexpected passing result %+v", r)
+	}
+
+	listUDPListeners = func(context.Context) ([]scan.Listener, error) {
+		return nil, errors.New("ss: unknown option -u")
+	}
+	r = runDoctorStep(context.Background(), step, time.Second)
+	if r.Status != statusError || !strings.Contains(r.Detail, "unknown option") || r.Remediation != remedyCheckScanTool {
+		t.Fatalf("unexpected failing result %+v", r)
+	}
+
+	results := []doctorResult{{Name: "ss", Status: statusOK}, {Name: "scan", Status: statusOK}, r}
+	if !doctorReady(results) {
+		t.Fatalf("a failed UDP scan shouldn't make fp unready")
+	}
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// argsEnv holds default flags applied to every invocation, shell-split.
+const argsEnv = "FREEPORT_ARGS"
+
+// withEnvArgs inserts the flags from env into args right after the command
+// path, ahead of the user's own flags. Flags the resolved command doesn't
+// have are skipped, so one FREEPORT_ARGS can serve every command, and any
+// flag also given explicitly is dropped so the command line always wins.
+// Words no command knows, like a misspelled flag, are skipped too and
+// returned as warnings.
+func withEnvArgs(root *cobra.Command, args []string, env string) ([]string, []string, error) {
+	words, err := splitShellWords(env)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", argsEnv, err)
+	}
+	if len(words) == 0 {
+		return args, nil, nil
+	}
+
+	c, at := commandPath(root, args)
+	if c.Name() == cobra.ShellCompRequestCmd || c.Name() == cobra.ShellCompNoDescRequestCmd || c.Name() == "help" {
+		return args, nil, nil
+	}
+	lookup := func(name string, short bool) *pflag.Flag { return commandFlag(c, name, short) }
+
+	explicit := make(map[*pflag.Flag]bool)
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		if f := flagToken(a, lookup); f != nil {
+			explicit[f] = true
+		}
+	}
+
+	var inject, warnings []string
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		f := flagToken(w, lookup)
+		known := f
+		if known == nil {
+			known = flagToken(w, func(name string, short bool) *pflag.Flag { return anyCommandFlag(root, name, short) })
+		}
+		if known == nil {
+			warnings = append(warnings, fmt.Sprintf("%s: ignoring %q, which no fp command accepts", argsEnv, w))
+			continue
+		}
+		// A value-taking flag written as "--name value" owns the next word.
+		takesNext := known.NoOptDefVal == "" && !strings.Contains(w, "=") && i+1 < len(words)
+		if f == nil || explicit[f] {
+			if takesNext {
+				i++
+			}
+			continue
+		}
+		inject = append(inject, w)
+		if takesNext {
+			i++
+			inject = append(inject, words[i])
+		}
+	}
+
+	out := make([]string, 0, len(args)+len(inject))
+	out = append(out, args[:at]...)
+	out = append(out, inject...)
+	return append(out, args[at:]...), warnings, nil
+}
+
+// anyCommandFlag is commandFlag for c or any of its subcommands.
+func anyCommandFlag(c *cobra.Command, name string, short bool) *pflag.Flag {
+	if f := commandFlag(c, name, short); f != nil {
+		return f
+	}
+	for _, sub := range c.Commands() {
+		if f := anyCommandFlag(sub, name, short); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// commandFlag finds a flag c accepts, its own or inherited, by name or
+// shorthand.
+func commandFlag(c *cobra.Command, name string, short bool) *pflag.Flag {
+	for _, fs := range []*pflag.FlagSet{c.LocalFlags(), c.InheritedFlags()} {
+		var f *pflag.Flag
+		if short {
+			f = fs.ShorthandLookup(name)
+		} else {
+			f = fs.Lookup(name)
+		}
+		if f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// commandPath resolves the subcommand named in args, skipping flags and
+// their values, and returns it with the index just past its last path word.
+func commandPath(root *cobra.Command, args []string) (*cobra.Command, int) {
+	c, at := root, 0
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			break
+		}
+		if strings.HasPrefix(a, "-") {
+			// "--json-case camel list": camel is the flag's, not a command.
+			f := flagToken(a, func(name string, short bool) *pflag.Flag { return commandFlag(c, name, short) })
+			if f != nil && f.NoOptDefVal == "" && !strings.Contains(a, "=") {
+				i++
+			}
+			continue
+		}
+		var next *cobra.Command
+		for _, sub := range c.Commands() {
+			if sub.Name() == a || sub.HasAlias(a) {
+				next = sub
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		c, at = next, i+1
+	}
+	return c, at
+}
+
+// flagToken returns the flag a "--name[=v]" or "-x" word refers to, if any.
+func flagToken(w string, lookup func(string, bool) *pflag.Flag) *pflag.Flag {
+	switch {
+	case strings.HasPrefix(w, "--") && len(w) > 2:
+		name, _, _ := strings.Cut(w[2:], "=")
+		return lookup(name, false)
+	case strings.HasPrefix(w, "-") && len(w) == 2:
+		return lookup(w[1:], true)
+	}
+	return nil
+}
+
+// splitShellWords splits s like a POSIX shell would, minus expansions:
+// whitespace separates words, single quotes are literal, double quotes
+// allow \" \\ \$ and \` escapes, and a backslash outside quotes escapes
+// the next character.
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		case ch == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case ch == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0 {
+					i++
+				}
+				cur.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		case ch == '\\':
+			if i+1 >= len(s) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			cur.WriteByte(s[i])
+			inWord = true
+		default:
+			cur.WriteByte(ch)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
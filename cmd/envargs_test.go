@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	cases := map[string][]string{
+		"":                            nil,
+		"  --no-color   --timeout 5s": {"--no-color", "--timeout", "5s"},
+		`--label 'ci job' --x="a b"`:  {"--label", "ci job", "--x=a b"},
+		`"say \"hi\"" it\'s`:          {`say "hi"`, "it's"},
+		`''`:                          {""},
+	}
+	for in, want := range cases {
+		got, err := splitShellWords(in)
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("splitShellWords(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{`'open`, `"open`, `trailing\`} {
+		if _, err := splitShellWords(bad); err == nil {
+			t.Errorf("splitShellWords(%q): expected error", bad)
+		}
+	}
+}
+
+func TestWithEnvArgs(t *testing.T) {
+	env := "--no-color --timeout 5s --format json --prefer 8080"
+	cases := []struct {
+		args []string
+		want string
+	}{
+		// Flags go after the command path; ones list lacks are skipped.
+		{[]string{"list", "node"}, "list --no-color --timeout 5s --format json node"},
+		// Explicit flags win, including the value of a skipped pair.
+		{[]string{"list", "--format=table"}, "list --no-color --timeout 5s --format=table"},
+		{[]string{"pick", "--prefer", "9000"}, "pick --no-color --format json --prefer 9000"},
+		// Subcommands are resolved past global flags; "--" is left alone.
+		{[]string{"--json", "locks", "export"}, "--json locks export --no-color"},
+		// A global flag's separate value isn't taken for a command name.
+		{[]string{"--json-case", "camel", "list"}, "--json-case camel list --no-color --timeout 5s --format json"},
+		{[]string{"--json-case=camel", "list"}, "--json-case=camel list --no-color --timeout 5s --format json"},
+		{[]string{"run", "--", "env"}, "run --no-color --prefer 8080 -- env"},
+	}
+	for _, tc := range cases {
+		got, warnings, err := withEnvArgs(rootCmd, tc.args, env)
+		if err != nil || len(warnings) > 0 {
+			t.Fatalf("withEnvArgs(%q): %v %q", tc.args, err, warnings)
+		}
+		if strings.Join(got, " ") != tc.want {
+			t.Errorf("withEnvArgs(%q) = %q, want %q", tc.args, strings.Join(got, " "), tc.want)
+		}
+	}
+
+	if _, _, err := withEnvArgs(rootCmd, []string{"list"}, `--format 'json`); err == nil || !strings.Contains(err.Error(), argsEnv) {
+		t.Fatalf("expected a quoting error naming %s, got %v", argsEnv, err)
+	}
+}
+
+func TestWithEnvArgsWarnsAboutUnknownWords(t *testing.T) {
+	// --prefer is pick's, so list skips it quietly; --no-colour is nobody's.
+	got, warnings, err := withEnvArgs(rootCmd, []string{"list"}, "--no-colour --prefer 8080 --no-color stray")
+	if err != nil {
+		t.Fatalf("withEnvArgs: %v", err)
+	}
+	if strings.Join(got, " ") != "list --no-color" {
+		t.Fatalf("expected only --no-color applied, got %q", got)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[0], `"--no-colour"`) || !strings.Contains(warnings[1], `"stray"`) {
+		t.Fatalf("expected warnings for --no-colour and stray, got %q", warnings)
+	}
+}
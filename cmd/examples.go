@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type example struct {
+	Line string // full invocation, starting with "fp"
+	Desc string
+}
+
+// commandExamples drives each command's help Example and `fp examples`.
+// Every line must parse against the current flags; see validateExample.
+var commandExamples = map[string][]example{
+	"list": {
+		{"fp list", "all ports"},
+		{"fp list node", "ports used by node processes"},
+		{"fp list --exclude-command chrome", "everything except the browser"},
+		{"fp list --port 3000", "filter by port"},
+		{"fp list --range 3000-3999", "only ports in a range"},
+		{"fp list --port-lt 1024", "only privileged ports"},
+		{"fp list --port-gte 3000 --port-lt 4000", "combine bounds into a range"},
+		{"fp list --scope external", "only listeners reachable from other hosts"},
+		{"fp list --proto all", "TCP listeners and bound UDP sockets together"},
+		{"fp list --only-mine", "only your own processes on a shared box"},
+		{"fp list --by exe", "group by executable path; tells two node binaries apart"},
+		{"fp list --started-after 2h", "processes started in the last two hours"},
+		{"fp list --unique -v", "dedupe by port+PID, show executable path"},
+		{"fp list --warn-backlog 50", "flag servers whose accept queue is half full"},
+		{"fp list --connections", "count established connections per listener"},
+		{"fp list --explain-only", "show the lsof/ss command a scan would run"},
+		{"fp list --json", "JSON output"},
+		{"fp list --format json-array-compact", "single-line JSON array"},
+		{"fp list --format html", "HTML table fragment for a wiki or email"},
+		{"fp list --format dot", "Graphviz graph of processes, ports and parents; pipe to dot -Tpng"},
+		{"fp list --watch --interval 1s", "refresh until Ctrl-C"},
+		{"fp list --watch --on-change -- notify-send \"ports changed\"", "run a command when listeners change"},
+		{"fp list --json --host-meta", "tag output with hostname and scan time"},
+		{"fp list --retry 2", "re-scan when a flaky VM returns nothing"},
+	},
+	"who": {
+		{"fp who 3000", "detailed info on port 3000"},
+		{"fp who 3000 --json", "JSON output"},
+		{"fp who 3000 --json --json-case camel", "camelCase keys (commandLine) for JS/Java consumers"},
+		{"fp who web", "port alias from the config file's [aliases] section"},
+		{"fp who 3000 --jsonl", "one compact JSON object per listener"},
+		{"fp who 3000 --watch", "print a line whenever the occupant changes"},
+		{"fp who 3000 --probe", "bind/connect probe only, no lsof/ss needed"},
+		{"fp who 3000 --fast", "port-scoped query, skip process enrichment"},
+		{"fp who 3000 --related", "all ports held by the process on 3000"},
+		{"fp who 3000 --summary", "totals across processes sharing the port"},
+		{"fp who 53 --proto udp", "who has UDP port 53 bound"},
+		{"fp who 3000 --mine-jobs", "is that my forgotten background server?"},
+		{"fp who 3000 --check", "exit 1 when nothing is listening"},
+		{"fp who 3000 --resolve-exe", "canonical executable path; flags binaries replaced since start"},
+		{"fp who 3000 --mem --json", "socket buffer sizes (rcvbuf/sndbuf) from ss -m"},
+	},
+	"kill": {
+		{"fp kill 3000", "SIGTERM with 2s timeout"},
+		{"fp kill 3000-3005 --json", "clear a dev range; JSON reports each port"},
+		{"fp kill --name node --dry-run", "preview killing every node listener, whatever its port"},
+		{"fp kill 3000 --signal INT --timeout 1s", "custom signal and timeout"},
+		{"fp kill 8080 --signal 9", "signal by number, like kill -9"},
+		{"fp kill 53 --proto udp", "only UDP sockets; by default TCP and UDP both count"},
+		{"fp kill 80 --signal HUP", "reload and confirm the process survived"},
+		{"fp kill 80 --signal HUP --no-wait", "reload without waiting or checking"},
+		{"fp kill 3000 --dry-run", "preview targets"},
+		{"fp kill 3000 --signal INT,TERM,KILL --timeout 1s", "try each signal in turn, 1s apart"},
+		{"fp kill 3000 --escalate TERM:2s,INT:3s,KILL", "full escalation plan with per-step waits"},
+		{"fp kill 5432 --protect-users root,postgres", "refuse to touch these users' processes without --force"},
+		{"fp kill 3000 --only-mine", "signal only your own processes, skip the rest"},
+		{"fp kill 80 --sudo", "re-run under sudo if signaling is denied"},
+		{"fp kill 8080 --drain 10s", "let open connections finish before escalating"},
+	},
+	"guard": {
+		{"fp guard 8080 --duration 5m", "kill anything that listens on 8080 for five minutes"},
+		{"fp guard 8080 --signal TERM --interval 1s", "gentler signal, checked once a second"},
+		{"fp guard 8080 --json", "one JSON line per eviction"},
+	},
+	"watch": {
+		{"fp watch", "live listener table; + marks new listeners, - gone ones"},
+		{"fp watch node --port 3000 --interval 1s", "follow one dev server while it boots"},
+		{"fp watch --json", "one JSON line per refresh, with added and removed"},
+	},
+	"free": {
+		{"fp free 3000-3999", "free/in-use counts and utilization"},
+		{"fp free 3000-3999 --json", "summary as JSON"},
+		{"fp free 3000-3999 --probe-timeout 200ms", "count slow-to-bind ports as unknown instead of waiting"},
+		{"fp free 1-65535 --probe-concurrency 4", "whole port space, gentle on a low fd limit"},
+	},
+	"locks": {
+		{"fp locks", "list locks and reservations"},
+		{"fp locks --gc", "clean up stale and expired reservations"},
+		{"fp locks export", "snapshot live reservations as JSON"},
+		{"fp locks import locks.json", "re-acquire exported reservations"},
+	},
+	"pick": {
+		{"fp pick", "prefer 3000, fall back to 3000-3999"},
+		{"fp pick --prefer 8080 --range 8000-8999", "custom preference and range"},
+		{"fp pick --prefer 0", "OS-assigned ephemeral port"},
+		{"fp pick --prefer 3100 --range 3000-3999 --strict", "reject preferred ports outside the range"},
+		{"fp pick --format env --var API_PORT", "print API_PORT=<port> for eval"},
+		{"fp pick --candidates 3000-3005,4000", "try an explicit ordered candidate set"},
+		{"fp pick --from 8080", "first free port >= 8080"},
+		{"fp pick --count 3 --prefer 8080,5432", "three ports: free preferred first, in order, then the range"},
+		{"fp pick --count 3 --sorted", "three ports, ascending"},
+		{"fp pick --range 4000-4010 --count 5", "five distinct ports for a multi-service stack"},
+		{"fp pick --probe-timeout 250ms", "skip ports whose bind hangs under load"},
+		{"fp pick --hold --label ci", "keep the port locked in the background until fp release"},
+	},
+	"release": {
+		{"fp release 3000", "stop the pick --hold process holding port 3000"},
+		{"fp release 3000 --force", "also stop a run/reserve holding it"},
+	},
+	"reserve": {
+		{"fp reserve 3000 --duration 1h", "hold port 3000 for an hour"},
+		{"fp reserve 3000 --label ci-job-42", "tag the reservation for fp locks"},
+	},
+	"run": {
+		{"fp run -- node server.js", "run with PORT set"},
+		{"fp run --prefer 8080 -- python app.py", "prefer a specific port"},
+		{"fp run --prefer 8080 --on-conflict kill -- ./myserver", "evict your old server from the preferred port"},
+		{"fp run --replace --prefer 3000 -- npm run dev", "take exactly port 3000, killing your squatter on it"},
+		{"fp run --env API_PORT -- ./myserver", "custom variable name"},
+		{"fp run --json -- ./myserver", "banner as one JSON line on stderr"},
+		{"fp run --quiet -- ./myserver", "no banner at all"},
+		{"fp run --wait-stable 200ms -- ./myserver", "re-probe before starting; costs 200ms"},
+		{"fp run --restart --max-restarts 5 --restart-window 1m -- ./myserver", "restart on crash, stop crash loops"},
+		{"fp run --exec -- ./myserver", "replace fp with the command (container entrypoints)"},
+		{"fp run --activate -- ./myserver", "pass a pre-bound socket as fd 3 (LISTEN_FDS)"},
+		{"fp run --label api --label ci -- ./myserver", "tag the port lock (shown by fp locks)"},
+	},
+	"check": {
+		{"fp check 3000", "exit 0=free, 1=in-use, 2=error"},
+		{"fp check 3000 --wait 5s", "wait up to 5s for the port to free"},
+		{"fp check 3000 --probe", "also try binding (catches SO_REUSEPORT)"},
+		{"fp check 3000 --fast", "port-scoped query only, lowest latency"},
+		{"fp check 5432 --connect --host db.internal", "is anything accepting connections on another host?"},
+		{"fp check --registry", "validate the repo's freeport.ports: exit 1 on a foreign occupant"},
+	},
+	"diff": {
+		{"fp diff before.json after.json", "compare two list --json snapshots"},
+		{"fp diff before.json after.json --fail-on added", "exit 1 if new listeners appeared"},
+	},
+	"listen": {
+		{"fp listen 8080", "test listener on 127.0.0.1:8080 until Ctrl-C"},
+		{"fp listen 8080 --bind 0.0.0.0", "externally reachable listener"},
+		{"fp listen 0 --bind ::1 --duration 1m", "IPv6 loopback, OS-chosen port, for one minute"},
+	},
+	"doctor": {
+		{"fp doctor", "check system dependencies"},
+		{"fp doctor --json --timeout-per-tool 2s", "machine-readable checks, each bounded to 2s"},
+	},
+	"completion": {
+		{"fp completion bash", "bash completion script"},
+		{"fp completion zsh", "zsh completion script"},
+	},
+	"foreach": {
+		{"fp foreach --command node -- kill -HUP {pid}", "signal every node listener"},
+		{"fp foreach --range 3000-3999 --dry-run -- curl -s localhost:{port}/health", "preview one command per listener"},
+	},
+	"signals": {
+		{"fp signals", "signals kill --signal accepts, with numbers"},
+		{"fp signals --json", "the same as JSON, for tooling and completion"},
+	},
+	"examples": {
+		{"fp examples kill", "show kill examples"},
+		{"fp examples --dry-run", "validate every documented example"},
+	},
+}
+
+var examplesDryRun bool
+
+var examplesCmd = &cobra.Command{
+	Use:   "examples [command]",
+	Short: "Show usage examples (optionally validating them)",
+	Long: `Show usage examples for a command, or for all commands.
+
+With --dry-run, each example is parsed against the command's flags and
+argument rules without running it, so documented examples can't drift from
+actual behavior.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := sortedExampleCommands()
+		if len(args) == 1 {
+			if _, ok := commandExamples[args[0]]; !ok {
+				return fmt.Errorf("no examples for %q", args[0])
+			}
+			names = []string{args[0]}
+		}
+
+		out := ui.Stdout()
+		failed := 0
+		for _, name := range names {
+			if !examplesDryRun {
+				fmt.Fprintf(out, "%s\n%s\n", ui.Header(out, name), formatExamples(commandExamples[name]))
+				continue
+			}
+			for _, ex := range commandExamples[name] {
+				if err := validateExample(ex.Line); err != nil {
+					failed++
+					fmt.Fprintf(out, "%s %s: %v\n", ui.LabelErr(out), ex.Line, err)
+					continue
+				}
+				fmt.Fprintf(out, "%s %s\n", ui.LabelOK(out), ex.Line)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d example(s) failed validation", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	examplesCmd.Flags().BoolVar(&examplesDryRun, "dry-run", false, "Validate examples without running them")
+	rootCmd.AddCommand(examplesCmd)
+
+	for _, c := range []*cobra.Command{listCmd, whoCmd, killCmd, pickCmd, runCmd, checkCmd, diffCmd, freeCmd, reserveCmd, releaseCmd, locksCmd, listenCmd, guardCmd, watchCmd, foreachCmd, signalsCmd, doctorCmd, completionCmd, examplesCmd} {
+		c.Example = formatExamples(commandExamples[c.Name()])
+	}
+}
+
+func sortedExampleCommands() []string {
+	names := make([]string, 0, len(commandExamples))
+	for name := range commandExamples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func formatExamples(examples []example) string {
+	width := 0
+	for _, ex := range examples {
+		width = max(width, len(ex.Line))
+	}
+	lines := make([]string, 0, len(examples))
+	for _, ex := range examples {
+		lines = append(lines, fmt.Sprintf("  %-*s  # %s", width, ex.Line, ex.Desc))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validateExample resolves the example to a command and checks its flags and
+// positional arguments, then restores every flag so nothing leaks into the
+// current invocation.
+func validateExample(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != rootCmd.Name() {
+		return fmt.Errorf("example must start with %q", rootCmd.Name())
+	}
+
+	c, rest, err := rootCmd.Find(fields[1:])
+	if err != nil {
+		return err
+	}
+
+	flags := c.Flags()
+	restore := snapshotFlags(flags)
+	defer restore()
+
+	if err := c.ParseFlags(rest); err != nil {
+		return err
+	}
+	if c.Args != nil {
+		if err := c.ValidateArgs(flags.Args()); err != nil {
+			return err
+		}
+	}
+	if c.Runnable() {
+		return nil
+	}
+	return fmt.Errorf("%q is not runnable", c.CommandPath())
+}
+
+func snapshotFlags(fs *pflag.FlagSet) func() {
+	type saved struct {
+		value   string
+		slice   []string
+		changed bool
+	}
+	state := map[*pflag.Flag]saved{}
+	fs.VisitAll(func(f *pflag.Flag) {
+		s := saved{value: f.Value.String(), changed: f.Changed}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			s.slice = append([]string(nil), sv.GetSlice()...)
+		}
+		state[f] = s
+	})
+	return func() {
+		for f, s := range state {
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				_ = sv.Replace(s.slice)
+			} else {
+				_ = f.Value.Set(s.value)
+			}
+			f.Changed = s.changed
+		}
+		// Parse never resets the "--" position; Init does. Cobra flag sets
+		// always continue on error.
+		fs.Init(fs.Name(), pflag.ContinueOnError)
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import "testing"
+
+func TestDocumentedExamplesParse(t *testing.T) {
+	for _, name := range sortedExampleCommands() {
+		for _, ex := range commandExamples[name] {
+			if err := validateExample(ex.Line); err != nil {
+				t.Errorf("example %q: %v", ex.Line, err)
+			}
+		}
+	}
+}
+
+func TestValidateExampleRejectsDrift(t *testing.T) {
+	cases := []string{
+		"fp who",
+		"fp kill 3000 --no-such-flag",
+		"fp list a b",
+		"freeport list",
+	}
+	for _, line := range cases {
+		if err := validateExample(line); err == nil {
+			t.Errorf("expected %q to fail validation", line)
+		}
+	}
+}
+
+func TestValidateExampleRestoresFlags(t *testing.T) {
+	if err := validateExample("fp pick --prefer 8080 --range 8000-8999"); err != nil {
+		t.Fatalf("validateExample: %v", err)
+	}
+	if pickRange != "3000-3999" {
+		t.Fatalf("expected --range to be restored, got %q", pickRange)
+	}
+	if len(pickPrefer) != 1 || pickPrefer[0] != 3000 {
+		t.Fatalf("expected --prefer to be restored, got %v", pickPrefer)
+	}
+	if f := pickCmd.Flags().Lookup("prefer"); f.Changed {
+		t.Fatalf("expected --prefer to be marked unchanged")
+	}
+}
+
+func TestEveryCommandHasExamples(t *testing.T) {
+	for _, c := range rootCmd.Commands() {
+		if c.Hidden || c.Name() == "help" {
+			continue
+		}
+		if len(commandExamples[c.Name()]) == 0 {
+			t.Errorf("command %q has no examples", c.Name())
+		}
+	}
+}
+
+func TestCommandsShowTheirExamples(t *testing.T) {
+	for _, name := range sortedExampleCommands() {
+		c, _, err := rootCmd.Find([]string{name})
+		if err != nil || c == rootCmd {
+			t.Errorf("examples for %q match no command", name)
+			continue
+		}
+		if c.Example != formatExamples(commandExamples[name]) {
+			t.Errorf("fp %s --help doesn't show its examples; add it to the Example loop in examples.go", name)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !unix
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func execCommand(argv []string, env []string) error {
+	return errors.New("--exec is only supported on Unix")
+}
+
+func detachAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func groupAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// signalGroup has no process groups to use, so only pid is signaled.
+func signalGroup(pid int, sig os.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
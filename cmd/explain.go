@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/muesli/termenv"
+)
+
+// explainScan is scan.ExplainScan; tests swap it out.
+var explainScan = scan.ExplainScan
+
+// scanExplanation is list --explain's JSON: the plans for each protocol
+// the listing would scan.
+type scanExplanation struct {
+	TCP []scan.ScanPlan `json:"tcp,omitempty"`
+	UDP []scan.ScanPlan `json:"udp,omitempty"`
+	// AllBackends is set with --ignore-errors, which runs every TCP plan
+	// rather than only falling back to the later ones.
+	AllBackends bool `json:"all_backends,omitempty"`
+}
+
+func explainListScan(proto string, ignoreErrors bool) (scanExplanation, error) {
+	var e scanExplanation
+	var err error
+	if proto != "udp" {
+		if e.TCP, err = explainScan(false); err != nil {
+			return e, err
+		}
+		e.AllBackends = ignoreErrors
+	}
+	if proto != "tcp" {
+		if e.UDP, err = explainScan(true); err != nil {
+			return e, err
+		}
+	}
+	return e, nil
+}
+
+// writeScanExplanation prints the scan commands the way a user would type
+// them, so the run can be reproduced by hand.
+func writeScanExplanation(w *termenv.Output, e scanExplanation) {
+	write := func(plans []scan.ScanPlan, all bool) {
+		for i, p := range plans {
+			line := "would run: "
+			switch {
+			case i > 0 && all:
+				line = "would also run: "
+			case i > 0:
+				line = "  if that finds nothing: "
+			}
+			fmt.Fprintf(w, "%s %s%s\n", ui.LabelInfo(w), line, strings.Join(p.Command, " "))
+		}
+	}
+	write(e.TCP, e.AllBackends)
+	write(e.UDP, false)
+}
+
+// explainList handles list --explain and --explain-only. Explaining alone
+// writes to stdout (JSON with --json); ahead of a real scan it goes to
+// stderr so the listing's output stays clean.
+func explainList(only bool) error {
+	e, err := explainListScan(listProto, listIgnoreErrors)
+	if err != nil {
+		return err
+	}
+	if !only {
+		writeScanExplanation(ui.Stderr(), e)
+		return nil
+	}
+	if jsonOutput {
+		return writeJSON(os.Stdout, e)
+	}
+	writeScanExplanation(ui.Stdout(), e)
+	return nil
+}
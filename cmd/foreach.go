@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	foreachCommand  string
+	foreachPort     int
+	foreachRange    string
+	foreachUser     string
+	foreachOnlyMine bool
+	foreachAll      bool
+	foreachDryRun   bool
+
+	foreachCaseSensitive bool
+)
+
+var foreachCmd = &cobra.Command{
+	Use:   "foreach [filters] -- <cmd...>",
+	Short: "Run a command once for each matching listener",
+	Long: `Run a command once for each matching listener.
+
+Listeners are filtered like list (--command matches the command name,
+executable or command line), deduplicated by port and PID, and the command
+after -- is run for each one in port order, with these placeholders
+replaced in every argument:
+
+  {pid} {port} {command} {user} {address}
+
+At least one filter is required; pass --all to really run for every
+listener. A failing command doesn't stop the rest; fp exits non-zero if any
+failed.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash != 0 || len(args) == 0 {
+			return fmt.Errorf("expected -- followed by the command to run")
+		}
+		if foreachOnlyMine && foreachUser != "" {
+			return fmt.Errorf("--only-mine and --user are mutually exclusive")
+		}
+		filtered := foreachCommand != "" || foreachPort > 0 || foreachRange != "" || foreachUser != "" || foreachOnlyMine
+		if !filtered && !foreachAll {
+			return fmt.Errorf("refusing to run for every listener; add a filter (--command, --port, --range, --user, --only-mine) or --all")
+		}
+
+		ctx := context.Background()
+		listeners, err := listTCPListeners(ctx)
+		if err != nil {
+			return err
+		}
+		targets, err := foreachTargets(ctx, listeners)
+		if err != nil {
+			return err
+		}
+		return runForeach(targets, args, foreachDryRun)
+	},
+}
+
+// foreachTargets applies the foreach filters, enriching first when the
+// command line, executable or owner is needed to match.
+func foreachTargets(ctx context.Context, listeners []scan.Listener) ([]scan.Listener, error) {
+	var r *ports.Range
+	if foreachRange != "" {
+		parsed, err := ports.ParseRange(foreachRange)
+		if err != nil {
+			return nil, err
+		}
+		r = &parsed
+	}
+	owner := foreachUser
+	if foreachOnlyMine {
+		if owner = currentUsername(); owner == "" {
+			return nil, fmt.Errorf("--only-mine: can't determine the current user")
+		}
+	}
+	if foreachCommand != "" || owner != "" {
+		enrichProcessInfo(ctx, listeners)
+	}
+
+	var targets []scan.Listener
+	seen := make(map[[2]int]bool)
+	for _, l := range listeners {
+		key := [2]int{l.Port, l.PID}
+		switch {
+		case seen[key]:
+		case foreachPort > 0 && l.Port != foreachPort:
+		case r != nil && !r.Contains(l.Port):
+		case owner != "" && l.User != owner:
+		case foreachCommand != "" && !matchesFilter(l, foreachCommand, foreachCaseSensitive):
+		default:
+			seen[key] = true
+			targets = append(targets, l)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Port != targets[j].Port {
+			return targets[i].Port < targets[j].Port
+		}
+		return targets[i].PID < targets[j].PID
+	})
+	return targets, nil
+}
+
+// expandPlaceholders substitutes l's fields into each argument of template.
+func expandPlaceholders(template []string, l scan.Listener) []string {
+	replacer := strings.NewReplacer(
+		"{pid}", strconv.Itoa(l.PID),
+		"{port}", strconv.Itoa(l.Port),
+		"{command}", l.Command,
+		"{user}", l.User,
+		"{address}", l.Address,
+	)
+	out := make([]string, len(template))
+	for i, arg := range template {
+		out[i] = replacer.Replace(arg)
+	}
+	return out
+}
+
+// foreachResult is one foreach run, or one planned run with --dry-run.
+type foreachResult struct {
+	Port     int      `json:"port"`
+	PID      int      `json:"pid"`
+	Args     []string `json:"args"`
+	ExitCode *int     `json:"exit_code,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// foreachExec runs one expanded command with fp's stdio. Tests replace it.
+var foreachExec = func(args []string) error {
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+func runForeach(targets []scan.Listener, template []string, dryRun bool) error {
+	if len(targets) == 0 {
+		if jsonOutput {
+			return writeJSON(os.Stdout, []foreachResult{})
+		}
+		fmt.Fprintf(ui.Stderr(), "%s no listeners matched\n", ui.LabelWarn(ui.Stderr()))
+		return nil
+	}
+
+	results := make([]foreachResult, 0, len(targets))
+	failed := 0
+	for _, t := range targets {
+		res := foreachResult{Port: t.Port, PID: t.PID, Args: expandPlaceholders(template, t)}
+		if dryRun {
+			if !jsonOutput {
+				fmt.Fprintf(ui.Stdout(), "%s would run: %s\n", ui.LabelInfo(ui.Stdout()), shellJoin(res.Args))
+			}
+			results = append(results, res)
+			continue
+		}
+		if !jsonOutput {
+			fmt.Fprintf(ui.Stderr(), "%s port %d pid %d: %s\n", ui.Brand(ui.Stderr(), "fp:"), t.Port, t.PID, shellJoin(res.Args))
+		}
+		err := foreachExec(res.Args)
+		code := 0
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			code = exitErr.ExitCode()
+		case err != nil:
+			code = -1
+			res.Error = err.Error()
+		}
+		res.ExitCode = &code
+		if code != 0 {
+			failed++
+			if !jsonOutput {
+				fmt.Fprintf(ui.Stderr(), "%s port %d pid %d: command failed (%v)\n", ui.LabelWarn(ui.Stderr()), t.Port, t.PID, err)
+			}
+		}
+		results = append(results, res)
+	}
+
+	if jsonOutput {
+		if err := writeJSON(os.Stdout, results); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d commands failed", failed, len(targets))
+	}
+	return nil
+}
+
+// shellJoin quotes args for display as one command line.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func init() {
+	foreachCmd.Flags().StringVar(&foreachCommand, "command", "", "Only listeners whose command, executable or command line contains this")
+	foreachCmd.Flags().BoolVar(&foreachCaseSensitive, "case-sensitive", false, "Match --command case-sensitively")
+	foreachCmd.Flags().IntVar(&foreachPort, "port", 0, "Only listeners on this port")
+	foreachCmd.Flags().StringVar(&foreachRange, "range", "", "Only listeners in this port range, e.g. 3000-3999")
+	foreachCmd.Flags().StringVar(&foreachUser, "user", "", "Only processes owned by this user")
+	foreachCmd.Flags().BoolVar(&foreachOnlyMine, "only-mine", false, "Only your own processes")
+	foreachCmd.Flags().BoolVar(&foreachAll, "all", false, "Allow running with no filter, once for every listener")
+	foreachCmd.Flags().BoolVar(&foreachDryRun, "dry-run", false, "Print the commands instead of running them")
+	rootCmd.AddCommand(foreachCmd)
+}
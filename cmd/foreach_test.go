@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+func TestForeachRunsOncePerMatchWithSubstitution(t *testing.T) {
+	listeners := []scan.Listener{
+		{Port: 3001, PID: 20, Command: "node", User: "dev"},
+		{Port: 3000, PID: 10, Command: "node", User: "dev", Address: "127.0.0.1:3000"},
+		{Port: 3000, PID: 10, Command: "node", User: "dev", Address: "[::1]:3000"},
+		{Port: 5432, PID: 30, Command: "postgres", User: "postgres"},
+	}
+	origRange, origExec := foreachRange, foreachExec
+	t.Cleanup(func() { foreachRange, foreachExec = origRange, origExec })
+	foreachRange = "3000-3999"
+
+	var runs [][]string
+	foreachExec = func(args []string) error {
+		runs = append(runs, args)
+		return nil
+	}
+
+	targets, err := foreachTargets(context.Background(), listeners)
+	if err != nil {
+		t.Fatalf("foreachTargets: %v", err)
+	}
+	if err := runForeach(targets, []string{"kill", "-HUP", "{pid}", "--tag={command}:{port}"}, false); err != nil {
+		t.Fatalf("runForeach: %v", err)
+	}
+	want := [][]string{
+		{"kill", "-HUP", "10", "--tag=node:3000"},
+		{"kill", "-HUP", "20", "--tag=node:3001"},
+	}
+	if !slices.EqualFunc(runs, want, slices.Equal) {
+		t.Fatalf("expected one run per port+pid, got %v", runs)
+	}
+
+	runs = nil
+	if err := runForeach(targets, []string{"echo", "{pid}"}, true); err != nil || len(runs) != 0 {
+		t.Fatalf("--dry-run must not execute, ran %v (err=%v)", runs, err)
+	}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var freeCmd = &cobra.Command{
+	Use:   "free <start-end>",
+	Short: "Summarize how many ports in a range are free",
+	Long: `Summarize how many ports in a range are free.
+
+Every port in the range is probed by binding it on 127.0.0.1. The summary
+reports free and in-use counts, utilization (in-use / total), and a bar
+showing how full the range is. Up to --probe-concurrency ports are probed at
+once, each holding a file descriptor while it runs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := ports.ParseRange(args[0])
+		if err != nil {
+			return err
+		}
+		if freeProbeConcurrency < 1 {
+			return fmt.Errorf("invalid --probe-concurrency %d (must be at least 1)", freeProbeConcurrency)
+		}
+		busy, indeterminate, err := ports.ScanRange(r, ports.ProbeOptions{Timeout: freeProbeTimeout, Concurrency: freeProbeConcurrency})
+		if err != nil {
+			return err
+		}
+
+		s := newRangeSummary(r, busy, indeterminate)
+		if jsonOutput {
+			return writeJSON(os.Stdout, s)
+		}
+
+		out := ui.Stdout()
+		fmt.Fprintf(out, "%s %s\n", ui.Header(out, fmt.Sprintf("ports %d-%d", r.Start, r.End)),
+			ui.Muted(out, fmt.Sprintf("(%d total)", s.Total)))
+		fmt.Fprintf(out, "  %s %d\n", ui.Info(out, "free:"), s.Free)
+		fmt.Fprintf(out, "  %s %d\n", ui.Info(out, "in use:"), s.InUse)
+		if s.Indeterminate > 0 {
+			fmt.Fprintf(out, "  %s %d %s\n", ui.Info(out, "unknown:"), s.Indeterminate,
+				ui.Muted(out, fmt.Sprintf("(probe exceeded %s)", freeProbeTimeout)))
+		}
+		fmt.Fprintf(out, "  %s %s %.1f%%\n", ui.Info(out, "used:"), utilizationBar(s.Utilization, 30), s.Utilization*100)
+		return nil
+	},
+}
+
+var (
+	freeProbeTimeout     time.Duration
+	freeProbeConcurrency int
+)
+
+func init() {
+	freeCmd.Flags().DurationVar(&freeProbeTimeout, "probe-timeout", ports.DefaultProbeOptions().Timeout, "Give up on a port whose bind probe takes longer and count it as unknown (0 waits forever)")
+	freeCmd.Flags().IntVar(&freeProbeConcurrency, "probe-concurrency", ports.DefaultProbeOptions().Concurrency, "Probe at most this many ports at once (1 probes them one by one)")
+	rootCmd.AddCommand(freeCmd)
+}
+
+type rangeSummary struct {
+	Start       int     `json:"start"`
+	End         int     `json:"end"`
+	Total       int     `json:"total"`
+	Free        int     `json:"free"`
+	InUse       int     `json:"in_use"`
+	Utilization float64 `json:"utilization"`
+	InUsePorts  []int   `json:"in_use_ports"`
+	// Indeterminate counts ports whose probe timed out; they are neither
+	// free nor in use.
+	Indeterminate int `json:"indeterminate,omitempty"`
+}
+
+func newRangeSummary(r ports.Range, busy, indeterminate []int) rangeSummary {
+	total := r.End - r.Start + 1
+	if total < 0 {
+		total = 0
+	}
+	if busy == nil {
+		busy = []int{}
+	}
+	return rangeSummary{
+		Start:       r.Start,
+		End:         r.End,
+		Total:       total,
+		Free:        total - len(busy) - len(indeterminate),
+		InUse:       len(busy),
+		Utilization: utilization(len(busy), total),
+		InUsePorts:  busy,
+
+		Indeterminate: len(indeterminate),
+	}
+}
+
+// utilization returns inUse/total clamped to [0, 1], and 0 for an empty range.
+func utilization(inUse, total int) float64 {
+	if total <= 0 || inUse <= 0 {
+		return 0
+	}
+	if inUse >= total {
+		return 1
+	}
+	return float64(inUse) / float64(total)
+}
+
+// utilizationBar renders frac as a width-character bar using the current
+// glyph set, so --plain stays ASCII.
+func utilizationBar(frac float64, width int) string {
+	filled := int(frac*float64(width) + 0.5)
+	if frac > 0 && filled == 0 {
+		filled = 1
+	}
+	filled = min(max(filled, 0), width)
+	g := ui.Symbols()
+	return "[" + strings.Repeat(g.BarFull, filled) + strings.Repeat(g.BarEmpty, width-filled) + "]"
+}
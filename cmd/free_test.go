@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/ui"
+)
+
+func TestUtilizationBoundaries(t *testing.T) {
+	cases := []struct {
+		inUse, total int
+		want         float64
+	}{
+		{0, 0, 0},
+		{5, 0, 0},
+		{0, 1000, 0},
+		{10, 1000, 0.01},
+		{1000, 1000, 1},
+		{1, 1, 1},
+	}
+	for _, tc := range cases {
+		if got := utilization(tc.inUse, tc.total); got != tc.want {
+			t.Errorf("utilization(%d, %d) = %v, want %v", tc.inUse, tc.total, got, tc.want)
+		}
+	}
+}
+
+func TestRangeSummaryFullyUsed(t *testing.T) {
+	s := newRangeSummary(ports.Range{Start: 3000, End: 3002}, []int{3000, 3001, 3002}, nil)
+	if s.Total != 3 || s.Free != 0 || s.InUse != 3 || s.Utilization != 1 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+}
+
+func TestUtilizationBar(t *testing.T) {
+	ui.Configure(true, true)
+	defer ui.Configure(false, false)
+
+	cases := map[float64]string{
+		0:     "[----------]",
+		0.001: "[#---------]",
+		0.5:   "[#####-----]",
+		1:     "[##########]",
+	}
+	for frac, want := range cases {
+		if got := utilizationBar(frac, 10); got != want {
+			t.Errorf("utilizationBar(%v) = %q, want %q", frac, got, want)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	guardDuration     time.Duration
+	guardInterval     time.Duration
+	guardSignal       string
+	guardForce        bool
+	guardProtectUsers []string
+)
+
+var guardCmd = &cobra.Command{
+	Use:   "guard <port>",
+	Short: "Keep a port clear by killing anything that starts listening on it",
+	Long: `Keep a port clear by killing anything that starts listening on it.
+
+The port is polled every --interval until --duration elapses or fp is
+interrupted, and each process found listening is sent --signal (KILL by
+default). Unlike reserve, which only coordinates fp invocations through a
+lock file, guard evicts any process. The same ownership and protected-user
+checks as kill apply: other users' processes are reported but left alone
+unless --force is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		port, err := parsePortArg(args[0])
+		if err != nil {
+			return err
+		}
+		if guardInterval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+		sig, err := parseSignal(guardSignal)
+		if err != nil {
+			return err
+		}
+		protected, err := protectedUsers(guardProtectUsers, cmd.Flags().Changed("protect-users"))
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if guardDuration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, guardDuration)
+			defer cancel()
+		}
+
+		g := &guard{
+			port:        port,
+			sig:         sig,
+			force:       guardForce,
+			currentUser: currentUsername(),
+			protected:   protected,
+			scan:        listTCPListenersOnPort,
+			kill:        syscall.Kill,
+			report:      reportGuardEvent,
+		}
+		if !jsonOutput {
+			until := "until interrupted"
+			if guardDuration > 0 {
+				until = "for " + guardDuration.String()
+			}
+			fmt.Fprintf(ui.Stderr(), "%s guarding port %d %s\n", ui.Brand(ui.Stderr(), "fp:"), port, until)
+		}
+		g.run(ctx, guardInterval)
+		if !jsonOutput {
+			fmt.Fprintf(ui.Stderr(), "%s stopped guarding port %d; evicted %d process(es)\n", ui.Brand(ui.Stderr(), "fp:"), port, g.evicted)
+		}
+		return nil
+	},
+}
+
+// guardEvent is one action guard took, printed as it happens.
+type guardEvent struct {
+	Time    time.Time `json:"time"`
+	Port    int       `json:"port"`
+	PID     int       `json:"pid"`
+	Command string    `json:"command,omitempty"`
+	User    string    `json:"user,omitempty"`
+	Signal  string    `json:"signal,omitempty"`
+	Result  string    `json:"result"`
+	Detail  string    `json:"detail,omitempty"`
+}
+
+// Guard event results.
+const (
+	guardEvicted = "evicted"
+	guardGone    = "gone"
+	guardRefused = "refused"
+	guardFailed  = "error"
+)
+
+// guard evicts whatever listens on port. scan, kill and report are
+// injectable so the loop can be tested without real processes.
+type guard struct {
+	port        int
+	sig         syscall.Signal
+	force       bool
+	currentUser string
+	protected   []string
+
+	scan   func(context.Context, int) ([]scan.Listener, error)
+	kill   func(int, syscall.Signal) error
+	report func(guardEvent)
+
+	evicted int
+	refused map[int]bool // PIDs already reported as refused
+}
+
+// run sweeps every interval until ctx is done. Scan errors are reported
+// and retried on the next tick rather than ending the guard.
+func (g *guard) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := g.sweep(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(ui.Stderr(), "%s scan failed: %v\n", ui.LabelWarn(ui.Stderr()), err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep scans the port once and signals every listener on it that passes
+// the ownership checks.
+func (g *guard) sweep(ctx context.Context) error {
+	listeners, err := g.scan(ctx, g.port)
+	if err != nil {
+		return err
+	}
+	seen := make(map[int]bool)
+	for _, l := range listeners {
+		if l.Port != g.port || l.PID <= 0 || seen[l.PID] {
+			continue
+		}
+		seen[l.PID] = true
+		event := guardEvent{Time: time.Now(), Port: g.port, PID: l.PID, Command: l.Command, User: l.User}
+
+		if !g.force {
+			if err := checkKillSafety([]scan.Listener{l}, g.currentUser, g.protected); err != nil {
+				if g.refused == nil {
+					g.refused = make(map[int]bool)
+				}
+				if !g.refused[l.PID] {
+					g.refused[l.PID] = true
+					event.Result, event.Detail = guardRefused, err.Error()
+					g.report(event)
+				}
+				continue
+			}
+		}
+
+		event.Signal = signalName(g.sig)
+		switch err := g.kill(l.PID, g.sig); {
+		case err == nil:
+			g.evicted++
+			event.Result = guardEvicted
+		case errors.Is(err, syscall.ESRCH):
+			event.Result = guardGone
+		default:
+			event.Result, event.Detail = guardFailed, err.Error()
+		}
+		g.report(event)
+	}
+	return nil
+}
+
+func reportGuardEvent(e guardEvent) {
+	if jsonOutput {
+		_ = writeJSONLine(os.Stdout, e)
+		return
+	}
+	out := ui.Stdout()
+	stamp := e.Time.Format(time.TimeOnly)
+	switch e.Result {
+	case guardEvicted:
+		fmt.Fprintf(out, "%s %s evicted pid %d (%s) from port %d with %s\n", stamp, ui.LabelOK(out), e.PID, e.Command, e.Port, e.Signal)
+	case guardGone:
+		fmt.Fprintf(out, "%s %s pid %d (%s) exited before it could be signaled\n", stamp, ui.LabelInfo(out), e.PID, e.Command)
+	case guardRefused:
+		fmt.Fprintf(out, "%s %s %s\n", stamp, ui.LabelWarn(out), e.Detail)
+	default:
+		fmt.Fprintf(out, "%s %s signal pid %d (%s): %s\n", stamp, ui.LabelErr(out), e.PID, e.Command, e.Detail)
+	}
+}
+
+func init() {
+	guardCmd.Flags().DurationVar(&guardDuration, "duration", 0, "Stop guarding after this long (0 = until interrupted)")
+	guardCmd.Flags().DurationVar(&guardInterval, "interval", 250*time.Millisecond, "How often to check the port")
+	guardCmd.Flags().StringVar(&guardSignal, "signal", "KILL", "Signal sent to intruders (TERM, INT, QUIT, KILL, HUP, USR1, USR2)")
+	guardCmd.Flags().BoolVar(&guardForce, "force", false, "Also evict processes owned by other or protected users")
+	guardCmd.Flags().StringSliceVar(&guardProtectUsers, "protect-users", nil, "Never signal processes owned by these users without --force (default from config "+protectUsersKey+")")
+	rootCmd.AddCommand(guardCmd)
+}
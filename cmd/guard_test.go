@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+func TestGuardEvictsIntruder(t *testing.T) {
+	// The intruder shows up on the second scan and is gone once signaled.
+	scans := 0
+	alive := true
+	var signaled []int
+	var events []guardEvent
+	g := &guard{
+		port:        8080,
+		sig:         syscall.SIGKILL,
+		currentUser: "dev",
+		scan: func(_ context.Context, port int) ([]scan.Listener, error) {
+			scans++
+			if scans < 2 || !alive {
+				return nil, nil
+			}
+			return []scan.Listener{{Port: port, PID: 4242, User: "dev", Command: "intruder"}}, nil
+		},
+		kill: func(pid int, sig syscall.Signal) error {
+			if sig != syscall.SIGKILL {
+				t.Fatalf("sent %v, want SIGKILL", sig)
+			}
+			signaled = append(signaled, pid)
+			alive = false
+			return nil
+		},
+		report: func(e guardEvent) { events = append(events, e) },
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	g.run(ctx, 5*time.Millisecond)
+
+	if scans < 3 {
+		t.Fatalf("expected guard to keep polling, got %d scans", scans)
+	}
+	if len(signaled) != 1 || signaled[0] != 4242 || g.evicted != 1 {
+		t.Fatalf("expected pid 4242 evicted once, got signaled=%v evicted=%d", signaled, g.evicted)
+	}
+	if len(events) != 1 || events[0].Result != guardEvicted || events[0].Signal != "SIGKILL" || events[0].Command != "intruder" {
+		t.Fatalf("expected one eviction event, got %+v", events)
+	}
+}
+
+func TestGuardRespectsOwnership(t *testing.T) {
+	var events []guardEvent
+	kills := 0
+	g := &guard{
+		port:        8080,
+		sig:         syscall.SIGKILL,
+		currentUser: "dev",
+		protected:   []string{"postgres"},
+		scan: func(_ context.Context, port int) ([]scan.Listener, error) {
+			return []scan.Listener{
+				{Port: port, PID: 1, User: "root", Command: "nginx"},
+				{Port: port, PID: 2, User: "postgres", Command: "postgres"},
+			}, nil
+		},
+		kill:   func(int, syscall.Signal) error { kills++; return nil },
+		report: func(e guardEvent) { events = append(events, e) },
+	}
+
+	for range 3 {
+		if err := g.sweep(context.Background()); err != nil {
+			t.Fatalf("sweep: %v", err)
+		}
+	}
+	if kills != 0 {
+		t.Fatalf("expected foreign and protected processes to be left alone, got %d kills", kills)
+	}
+	if len(events) != 2 || events[0].Result != guardRefused || events[1].Result != guardRefused {
+		t.Fatalf("expected each refusal reported once, got %+v", events)
+	}
+
+	g.force = true
+	if err := g.sweep(context.Background()); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if kills != 2 || g.evicted != 2 {
+		t.Fatalf("expected --force to evict both, got kills=%d evicted=%d", kills, g.evicted)
+	}
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/lock"
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// holdReadyTimeout bounds how long pick --hold waits for the background
+// holder to take the lock.
+const holdReadyTimeout = 5 * time.Second
+
+var holdLabels []string
+
+// holdCmd is the detached process behind pick --hold. It takes the lock,
+// reports on fd 3 that it did, and keeps it until signaled.
+var holdCmd = &cobra.Command{
+	Use:    "hold <port>",
+	Short:  "Hold a port lock in the background (internal, used by pick --hold)",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ready := os.NewFile(3, "ready")
+		defer ready.Close()
+		fail := func(err error) error {
+			fmt.Fprintf(ready, "error: %v\n", err)
+			return err
+		}
+
+		port, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fail(fmt.Errorf("invalid port %q", args[0]))
+		}
+		// Registered before the lock is taken so a quick release can't
+		// kill the holder before it cleans up.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		signal.Ignore(syscall.SIGHUP)
+
+		h, err := lock.LockTCPPort(port, 0)
+		if err != nil {
+			return fail(err)
+		}
+		defer h.Close()
+		if len(holdLabels) > 0 {
+			if err := h.SetLabels(holdLabels); err != nil {
+				return fail(err)
+			}
+		}
+		if err := h.SetDetached(); err != nil {
+			return fail(err)
+		}
+		fmt.Fprintln(ready, "ok")
+		ready.Close()
+
+		<-ctx.Done()
+		return nil
+	},
+}
+
+// startHold launches a detached "fp hold" for port and waits until it holds
+// the lock, returning its PID. The holder is in its own session, so it
+// outlives the shell or script that ran pick.
+func startHold(port int, labels []string) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	args := []string{"hold", strconv.Itoa(port)}
+	for _, l := range labels {
+		args = append(args, "--label", l)
+	}
+	child := exec.Command(exe, args...)
+	child.ExtraFiles = []*os.File{w}
+	child.SysProcAttr = detachAttr()
+	if err := child.Start(); err != nil {
+		_ = w.Close()
+		return 0, err
+	}
+	_ = w.Close()
+
+	line := make(chan string, 1)
+	go func() {
+		s, _ := bufio.NewReader(r).ReadString('\n')
+		line <- strings.TrimSpace(s)
+	}()
+	var status string
+	select {
+	case status = <-line:
+	case <-time.After(holdReadyTimeout):
+		_ = child.Process.Kill()
+		_ = child.Wait()
+		return 0, fmt.Errorf("background holder for port %d did not start within %s", port, holdReadyTimeout)
+	}
+	if status != "ok" {
+		_ = child.Wait()
+		if msg, ok := strings.CutPrefix(status, "error: "); ok {
+			return 0, errors.New(msg)
+		}
+		return 0, fmt.Errorf("background holder for port %d exited early", port)
+	}
+	pid := child.Process.Pid
+	_ = child.Process.Release()
+	return pid, nil
+}
+
+var (
+	releaseForce   bool
+	releaseTimeout time.Duration
+)
+
+var releaseCmd = &cobra.Command{
+	Use:   "release <port>",
+	Short: "Release a port held by pick --hold",
+	Long: `Release a port held by pick --hold.
+
+The PID recorded in the port's lock file is sent SIGTERM, and fp waits for
+the lock to be dropped. Locks taken by run or reserve are refused unless
+--force is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		port, err := parsePortArg(args[0])
+		if err != nil {
+			return err
+		}
+		pid, err := lock.Release(port, releaseForce, releaseTimeout)
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return writeJSON(os.Stdout, map[string]any{"port": port, "pid": pid, "status": "released"})
+		}
+		fmt.Fprintf(ui.Stderr(), "%s released port %d (pid %d)\n", ui.Brand(ui.Stderr(), "fp:"), port, pid)
+		return nil
+	},
+}
+
+func init() {
+	holdCmd.Flags().StringArrayVar(&holdLabels, "label", nil, "Tag the lock")
+	releaseCmd.Flags().BoolVar(&releaseForce, "force", false, "Also signal holders that aren't pick --hold (run, reserve)")
+	releaseCmd.Flags().DurationVar(&releaseTimeout, "timeout", 2*time.Second, "How long to wait for the holder to drop the lock")
+	rootCmd.AddCommand(holdCmd)
+	rootCmd.AddCommand(releaseCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
+)
+
+// changeHook runs a command when the listener set changes between watch
+// scans. Changes are debounced: the hook fires once the set has been stable
+// for the debounce window, with the net diff since it last fired, so a
+// flapping port doesn't trigger a burst of runs.
+type changeHook struct {
+	debounce time.Duration
+	run      func(env []string) error
+
+	primed    bool
+	baseline  []scan.Listener // state last reported to the hook
+	last      []scan.Listener // previous scan
+	changedAt time.Time       // zero once settled
+}
+
+func newChangeHook(argv []string, debounce time.Duration) *changeHook {
+	return &changeHook{
+		debounce: debounce,
+		run: func(env []string) error {
+			c := exec.Command(argv[0], argv[1:]...)
+			c.Stdout = os.Stdout
+			c.Stderr = os.Stderr
+			c.Env = append(os.Environ(), env...)
+			return c.Run()
+		},
+	}
+}
+
+// Observe feeds one scan to the hook. The first scan only sets the baseline.
+func (h *changeHook) Observe(now time.Time, listeners []scan.Listener) error {
+	if !h.primed {
+		h.baseline, h.last, h.primed = listeners, listeners, true
+		return nil
+	}
+	if diffListeners(h.last, listeners).fails("any") {
+		h.changedAt = now
+	}
+	h.last = listeners
+	if h.changedAt.IsZero() || now.Sub(h.changedAt) < h.debounce {
+		return nil
+	}
+
+	h.changedAt = time.Time{}
+	d := diffListeners(h.baseline, listeners)
+	h.baseline = listeners
+	if !d.fails("any") {
+		return nil // flapped back to where we were
+	}
+	return h.run(hookEnv(d))
+}
+
+// hookEnv exposes a diff to the hook as comma-separated Listener.Key values.
+func hookEnv(d listenerDiff) []string {
+	keys := func(ls []scan.Listener) string {
+		parts := make([]string, 0, len(ls))
+		for _, l := range ls {
+			parts = append(parts, l.Key())
+		}
+		return strings.Join(parts, ",")
+	}
+	changed := make([]string, 0, len(d.Changed))
+	for _, c := range d.Changed {
+		changed = append(changed, c.Key)
+	}
+	return []string{
+		"FREEPORT_ADDED=" + keys(d.Added),
+		"FREEPORT_REMOVED=" + keys(d.Removed),
+		"FREEPORT_CHANGED=" + strings.Join(changed, ","),
+	}
+}
+
+// observeChange runs the hook, warning rather than stopping the watch if the
+// hook command fails.
+func observeChange(h *changeHook, listeners []scan.Listener) {
+	if h == nil {
+		return
+	}
+	if err := h.Observe(time.Now(), listeners); err != nil {
+		fmt.Fprintf(ui.Stderr(), "%s on-change hook failed: %v\n", ui.LabelWarn(ui.Stderr()), err)
+	}
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+func TestChangeHookFiresOnlyOnChanges(t *testing.T) {
+	var fired [][]string
+	h := &changeHook{run: func(env []string) error {
+		fired = append(fired, env)
+		return nil
+	}}
+
+	base := []scan.Listener{{Port: 3000, PID: 1, Command: "node", Proto: "tcp", Address: "127.0.0.1:3000"}}
+	grown := append(base[:1:1], scan.Listener{Port: 8080, PID: 2, Command: "go", Proto: "tcp", Address: "*:8080"})
+	now := time.Unix(0, 0)
+	step := func(ls []scan.Listener) {
+		now = now.Add(time.Second)
+		if err := h.Observe(now, ls); err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+	}
+
+	step(base) // baseline
+	step(base)
+	step(base)
+	if len(fired) != 0 {
+		t.Fatalf("hook fired without a change: %v", fired)
+	}
+
+	step(grown)
+	if len(fired) != 1 {
+		t.Fatalf("expected one firing after a change, got %d", len(fired))
+	}
+	if env := strings.Join(fired[0], "\n"); !strings.Contains(env, "FREEPORT_ADDED=tcp *:8080") || !strings.Contains(env, "FREEPORT_REMOVED=\n") {
+		t.Fatalf("unexpected hook env:\n%s", env)
+	}
+
+	step(grown)
+	if len(fired) != 1 {
+		t.Fatalf("hook fired again without a change")
+	}
+}
+
+func TestChangeHookDebouncesFlapping(t *testing.T) {
+	fired := 0
+	h := &changeHook{debounce: 5 * time.Second, run: func([]string) error {
+		fired++
+		return nil
+	}}
+
+	a := []scan.Listener{{Port: 3000, PID: 1, Proto: "tcp", Address: "127.0.0.1:3000"}}
+	b := []scan.Listener{{Port: 3000, PID: 2, Proto: "tcp", Address: "127.0.0.1:3000"}}
+	start := time.Unix(0, 0)
+	observe := func(offset time.Duration, ls []scan.Listener) {
+		if err := h.Observe(start.Add(offset), ls); err != nil {
+			t.Fatalf("Observe: %v", err)
+		}
+	}
+
+	observe(0, a)
+	observe(1*time.Second, b)
+	observe(2*time.Second, a) // back where we started
+	observe(8*time.Second, a) // settled, but no net change
+	if fired != 0 {
+		t.Fatalf("expected no firing for a flap, got %d", fired)
+	}
+
+	observe(9*time.Second, b)
+	observe(10*time.Second, b)
+	if fired != 0 {
+		t.Fatalf("fired before the debounce window elapsed")
+	}
+	observe(14*time.Second, b)
+	if fired != 1 {
+		t.Fatalf("expected one firing once settled, got %d", fired)
+	}
+}
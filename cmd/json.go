@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+// hostMeta wraps every JSON output with machine identity (--host-meta) so
+// results from many hosts can be aggregated in one store.
+var hostMeta bool
+
+type hostEnvelope struct {
+	Host      string    `json:"host"`
+	ScannedAt time.Time `json:"scanned_at"`
+	Data      any       `json:"data"`
+}
+
+func withHostMeta(v any) any {
+	if !hostMeta {
+		return v
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return hostEnvelope{Host: host, ScannedAt: time.Now().UTC(), Data: v}
+}
+
+// jsonCase is --json-case: "snake" (the struct tags as written) or "camel".
+var jsonCase string
+
+const (
+	jsonCaseSnake = "snake"
+	jsonCaseCamel = "camel"
+)
+
+func validateJSONCase(c string) error {
+	if c != jsonCaseSnake && c != jsonCaseCamel {
+		return fmt.Errorf("invalid --json-case %q (want %s or %s)", c, jsonCaseSnake, jsonCaseCamel)
+	}
+	return nil
+}
+
+// prepareJSON applies --host-meta and --json-case to v. Camel case is done
+// by re-keying the encoded form, so every struct gets it without a second
+// set of tags. Only field names change: see rekeyJSON for how map keys that
+// are data, such as list --by's group keys, are told apart.
+func prepareJSON(v any) (any, error) {
+	v = withHostMeta(v)
+	if jsonCase != jsonCaseCamel {
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encode json: %w", err)
+	}
+	rekeyed, err := rekeyJSON(data, reflect.ValueOf(v), snakeToCamel)
+	if err != nil {
+		return nil, fmt.Errorf("encode json: %w", err)
+	}
+	return json.RawMessage(rekeyed), nil
+}
+
+// snakeToCamel turns "command_line" into "commandLine".
+func snakeToCamel(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// rekeyJSON rewrites the field names in data, the encoding of v, with key,
+// keeping the order of keys and the values as they are. v is walked
+// alongside to tell field names from data: struct fields and the keys of
+// map[string]any, which is how commands build ad-hoc result objects, are
+// renamed; the keys of any other map, like a map[string][]Listener of
+// groups, are left alone.
+func rekeyJSON(data []byte, v reflect.Value, key func(string) string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if err := rekeyValue(dec, &buf, v, key); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonChild returns the value encoded under object key k or array index i
+// of v, or an invalid Value if it can't be found.
+func jsonChild(v reflect.Value, k string, i int) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		return fieldByJSONName(v, k)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}
+		}
+		return v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+	case reflect.Slice, reflect.Array:
+		if i < v.Len() {
+			return v.Index(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// fieldByJSONName finds the field of struct v that encoding/json writes as
+// name, looking through untagged embedded structs.
+func fieldByJSONName(v reflect.Value, name string) reflect.Value {
+	t := v.Type()
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "-" || !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			if inner := indirect(v.Field(i)); inner.Kind() == reflect.Struct {
+				if found := fieldByJSONName(inner, name); found.IsValid() {
+					return found
+				}
+			}
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if tag == name {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// indirect follows pointers and interfaces down to the value they hold.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func rekeyValue(dec *json.Decoder, buf *bytes.Buffer, v reflect.Value, key func(string) string) error {
+	v = indirect(v)
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		b, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		return nil
+	}
+	closing := byte(']')
+	if delim == '{' {
+		closing = '}'
+	}
+	// Keys are data in any map but a map[string]any.
+	dataKeys := v.Kind() == reflect.Map && v.Type().Elem().Kind() != reflect.Interface
+	buf.WriteByte(byte(delim))
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		var k string
+		if delim == '{' {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			k = tok.(string)
+			name := k
+			if !dataKeys {
+				name = key(k)
+			}
+			b, err := json.Marshal(name)
+			if err != nil {
+				return err
+			}
+			buf.Write(b)
+			buf.WriteByte(':')
+		}
+		if err := rekeyValue(dec, buf, jsonChild(v, k, i), key); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	buf.WriteByte(closing)
+	return nil
+}
+
+// writeJSON, writeJSONOpts and writeJSONLine are the scan writers with
+// --host-meta and --json-case applied; commands use these rather than
+// calling scan directly.
+func writeJSON(w io.Writer, v any) error {
+	v, err := prepareJSON(v)
+	if err != nil {
+		return err
+	}
+	return scan.WriteJSON(w, v)
+}
+
+func writeJSONOpts(w io.Writer, v any, opts scan.JSONOptions) error {
+	v, err := prepareJSON(v)
+	if err != nil {
+		return err
+	}
+	return scan.WriteJSONOpts(w, v, opts)
+}
+
+func writeJSONLine(w io.Writer, v any) error {
+	v, err := prepareJSON(v)
+	if err != nil {
+		return err
+	}
+	return scan.WriteJSONLine(w, v)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+func TestWriteJSONHostMeta(t *testing.T) {
+	hostMeta = true
+	t.Cleanup(func() { hostMeta = false })
+
+	var buf bytes.Buffer
+	before := time.Now().UTC().Add(-time.Second)
+	if err := writeJSON(&buf, map[string]int{"port": 3000}); err != nil {
+		t.Fatalf("writeJSON: %v", err)
+	}
+
+	var got struct {
+		Host      string         `json:"host"`
+		ScannedAt time.Time      `json:"scanned_at"`
+		Data      map[string]int `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal %q: %v", buf.String(), err)
+	}
+	if want, _ := os.Hostname(); got.Host == "" || got.Host != want {
+		t.Fatalf("host = %q, want %q", got.Host, want)
+	}
+	if got.ScannedAt.Before(before) || got.ScannedAt.After(time.Now().Add(time.Second)) {
+		t.Fatalf("implausible scanned_at %v", got.ScannedAt)
+	}
+	if got.Data["port"] != 3000 {
+		t.Fatalf("data not wrapped: %s", buf.String())
+	}
+}
+
+func TestWriteJSONWithoutHostMetaIsUnchanged(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, map[string]int{"port": 3000}); err != nil {
+		t.Fatalf("writeJSON: %v", err)
+	}
+	if buf.String() != "{\n  \"port\": 3000\n}\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+func TestWriteJSONCamelCase(t *testing.T) {
+	jsonCase = jsonCaseCamel
+	t.Cleanup(func() { jsonCase = jsonCaseSnake })
+
+	// Shaped like enriched who output.
+	who := []scan.Listener{{
+		Port: 3000, PID: 42, PPID: 1, User: "dev", Command: "node",
+		CommandLine: "node server.js", Executable: "/usr/bin/node", CWD: "/app",
+		Proto: "tcp", Address: "127.0.0.1:3000", RawAddress: "[::ffff:127.0.0.1]:3000", Family: "ipv4",
+		Started: time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+	}}
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, who); err != nil {
+		t.Fatalf("writeJSON: %v", err)
+	}
+	if strings.Contains(buf.String(), "_") {
+		t.Fatalf("expected no snake_case keys, got %s", buf.String())
+	}
+
+	dec := json.NewDecoder(strings.NewReader(buf.String()))
+	var keys []string
+	depth := 0
+	expectKey := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch tok {
+		case json.Delim('{'):
+			depth++
+			expectKey = true
+			continue
+		case json.Delim('}'):
+			depth--
+		}
+		if s, ok := tok.(string); ok && expectKey && depth == 1 {
+			keys = append(keys, s)
+			expectKey = false
+			continue
+		}
+		expectKey = depth == 1
+	}
+	want := []string{"port", "pid", "ppid", "user", "command", "commandLine", "executable", "cwd", "proto", "address", "rawAddress", "family", "started"}
+	if !slices.Equal(keys, want) {
+		t.Fatalf("expected keys %v in order, got %v", want, keys)
+	}
+	if !strings.Contains(buf.String(), `"commandLine": "node server.js"`) {
+		t.Fatalf("expected values kept and output indented, got %s", buf.String())
+	}
+}
+
+func TestWriteJSONCamelCaseKeepsDataKeys(t *testing.T) {
+	jsonCase = jsonCaseCamel
+	hostMeta = true
+	t.Cleanup(func() { jsonCase, hostMeta = jsonCaseSnake, false })
+
+	// list --by exe/user output: group keys are data, the listeners inside
+	// are structs.
+	groups := map[string][]scan.Listener{
+		"/usr/lib/my_app": {{Port: 3000, PID: 42, CommandLine: "my_app --serve"}},
+		"svc_user":        {{Port: 3001, RawAddress: "[::]:3001"}},
+	}
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, groups); err != nil {
+		t.Fatalf("writeJSON: %v", err)
+	}
+	var got struct {
+		ScannedAt string                       `json:"scannedAt"`
+		Data      map[string][]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("decode %s: %v", buf.String(), err)
+	}
+	if got.ScannedAt == "" || len(got.Data["/usr/lib/my_app"]) != 1 || len(got.Data["svc_user"]) != 1 {
+		t.Fatalf("expected group keys kept as written, got %s", buf.String())
+	}
+	for _, want := range []string{`"commandLine": "my_app --serve"`, `"rawAddress": "[::]:3001"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %s inside the groups, got %s", want, buf.String())
+		}
+	}
+
+	// Ad-hoc result objects built as map[string]any are field names.
+	buf.Reset()
+	hostMeta = false
+	if err := writeJSON(&buf, map[string]any{"signal_description": "terminated"}); err != nil {
+		t.Fatalf("writeJSON: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"signalDescription"`) {
+		t.Fatalf("expected map[string]any keys camel-cased, got %s", buf.String())
+	}
+}
+
+func TestSnakeToCamel(t *testing.T) {
+	for in, want := range map[string]string{
+		"pid": "pid", "command_line": "commandLine", "scanned_at": "scannedAt",
+		"signal_description": "signalDescription", "a_b_c": "aBC",
+	} {
+		if got := snakeToCamel(in); got != want {
+			t.Errorf("snakeToCamel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -5,84 +5,148 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/user"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
-	"fp/internal/scan"
-	"fp/internal/ui"
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 var (
-	killForce   bool
-	killSignal  string
-	killTimeout time.Duration
-	killJSON    bool
-	killDryRun  bool
+	killForce    bool
+	killSignal   string
+	killTimeout  time.Duration
+	killJSON     bool
+	killDryRun   bool
+	killAudit    string
+	killEscalate string
+
+	killProtectUsers []string
+	killOnlyMine     bool
+	killSudo         bool
+	killDrain        time.Duration
+	killNoWait       bool
+	killExitZero     bool
+	killName         string
+	killProto        string
 )
 
 var killCmd = &cobra.Command{
-	Use:   "kill <port>",
+	Use:   "kill <port|start-end> | --name <command> [port|start-end]",
 	Short: "Send a signal to processes listening on a port",
-	Args:  cobra.ExactArgs(1),
+	Long: `Send a signal to processes listening on a port.
+
+A range such as 3000-3005 targets every listener in it, signaling each
+process once however many of the ports it holds; JSON then reports the
+result per port. With --name, targets are listeners whose command contains
+the name (case-insensitive), on any port; a port or range argument narrows
+them.
+
+Finding nothing to signal, whether an idle port or a --name or range that
+matches nothing, exits 0.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if killName == "" {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		return cobra.MaximumNArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		port, err := strconv.Atoi(args[0])
-		if err != nil || port < 1 || port > 65535 {
-			return fmt.Errorf("invalid port: %q", args[0])
+		scope := killScope{Name: killName}
+		if len(args) > 0 {
+			r, err := parseKillPorts(args[0])
+			if err != nil {
+				return err
+			}
+			scope.Ports = &r
 		}
+		port := scope.port()
+		if port == 0 && killDrain > 0 {
+			return fmt.Errorf("--drain needs a single port to count connections on")
+		}
+		what := scope.String()
 
-		sig, err := parseSignal(killSignal)
+		if killNoWait && (killEscalate != "" || strings.Contains(killSignal, ",") || killDrain > 0) {
+			return fmt.Errorf("--no-wait sends one signal and returns; it can't be combined with --escalate, a --signal list or --drain")
+		}
+		if err := validateProto(killProto); err != nil {
+			return err
+		}
+		plan, err := killPlan(cmd.Flags().Changed("signal"))
 		if err != nil {
 			return err
 		}
 
-		listeners, err := scan.ListTCPListeners(context.Background())
+		listeners, proto, err := killListeners(context.Background(), killProto, cmd.Flags().Changed("proto"))
 		if err != nil {
 			return err
 		}
 
-		var targets []scan.Listener
-		seen := make(map[int]bool)
-		for _, l := range listeners {
-			if l.Port != port {
-				continue
+		matched := scope.filter(listeners)
+		targets := uniquePIDs(matched)
+		// results holds each PID's outcome for the per-port JSON summary.
+		results := make(map[int]string)
+
+		skipped := 0
+		if killOnlyMine {
+			me := currentUsername()
+			if me == "" {
+				return fmt.Errorf("--only-mine: can't determine the current user")
 			}
-			if l.PID <= 0 || seen[l.PID] {
-				continue
+			if slices.ContainsFunc(targets, func(l scan.Listener) bool { return l.User == "" }) {
+				enrichProcessInfo(context.Background(), targets)
 			}
-			seen[l.PID] = true
-			targets = append(targets, l)
+			all := len(targets)
+			for _, t := range targets {
+				results[t.PID] = "skipped"
+			}
+			targets = ownedBy(targets, me)
+			skipped = all - len(targets)
 		}
 
 		if len(targets) == 0 {
+			// Idle is success, with or without --exit-zero-on-not-found;
+			// nothing below this point runs for it.
 			if jsonOutput || killJSON {
-				return scan.WriteJSON(os.Stdout, map[string]any{
-					"port":     port,
-					"status":   "idle",
-					"signaled": 0,
-				})
+				result := scope.result(matched, results)
+				result["status"] = "idle"
+				result["signaled"] = 0
+				if skipped > 0 {
+					result["skipped"] = skipped
+				}
+				return writeJSON(os.Stdout, result)
+			}
+			if skipped > 0 {
+				fmt.Fprintf(ui.Stdout(), "%s %s: nothing of yours to kill (%d other process(es) left alone)\n", ui.LabelWarn(ui.Stdout()), what, skipped)
+				return nil
 			}
-			fmt.Fprintf(ui.Stdout(), "%s port %d: nothing to kill\n", ui.LabelWarn(ui.Stdout()), port)
+			fmt.Fprintf(ui.Stdout(), "%s %s: nothing to kill\n", ui.LabelWarn(ui.Stdout()), what)
 			return nil
 		}
 
-		current, _ := user.Current()
-		for _, t := range targets {
-			if !killForce && current != nil && t.User != "" && t.User != current.Username {
-				return fmt.Errorf("refusing to kill pid %d owned by %q (use --force to override)", t.PID, t.User)
+		protected, err := protectedUsers(killProtectUsers, cmd.Flags().Changed("protect-users"))
+		if err != nil {
+			return err
+		}
+		if !killForce {
+			if err := checkKillSafety(targets, currentUsername(), protected); err != nil {
+				return err
 			}
 		}
 
 		if killDryRun {
 			if jsonOutput || killJSON {
-				return scan.WriteJSON(os.Stdout, map[string]any{
-					"port":    port,
-					"status":  "dry-run",
-					"targets": targets,
-				})
+				for _, t := range targets {
+					results[t.PID] = "dry-run"
+				}
+				result := scope.result(matched, results)
+				result["status"] = "dry-run"
+				result["targets"] = targets
+				return writeJSON(os.Stdout, result)
 			}
 			for _, t := range targets {
 				fmt.Fprintf(ui.Stdout(), "%s would signal pid %d (%s)\n", ui.LabelInfo(ui.Stdout()), t.PID, t.Command)
@@ -90,75 +154,622 @@ var killCmd = &cobra.Command{
 			return nil
 		}
 
+		audit := openAuditLog(killAudit)
+		defer audit.Close()
+
+		first := plan[0].Signal
 		signaled := 0
 		for _, t := range targets {
-			fmt.Fprintf(ui.Stdout(), "%s sending %s to pid %d (%s)\n", ui.LabelInfo(ui.Stdout()), sig.String(), t.PID, t.Command)
-			if err := syscall.Kill(t.PID, sig); err != nil {
+			fmt.Fprintf(ui.Stdout(), "%s sending %s to pid %d (%s)\n", ui.LabelInfo(ui.Stdout()), describeSignal(first), t.PID, t.Command)
+			if err := signalProcess(t.PID, first); err != nil {
 				if errors.Is(err, syscall.ESRCH) {
+					audit.Record(t.Port, first, t, "gone")
+					results[t.PID] = "gone"
 					continue
 				}
+				audit.Record(t.Port, first, t, "error: "+err.Error())
+				if errors.Is(err, syscall.EPERM) {
+					if killSudo && os.Geteuid() != 0 {
+						fmt.Fprintf(ui.Stderr(), "%s permission denied for pid %d; re-running under sudo\n", ui.LabelWarn(ui.Stderr()), t.PID)
+						audit.Close()
+						code, err := sudoRerun(withoutFlag(invocationArgs, "sudo"))
+						if err != nil {
+							return err
+						}
+						exit(code)
+					}
+					return killPermissionError(t.PID, t.Command, invocationArgs)
+				}
 				return err
 			}
+			audit.Record(t.Port, first, t, "signaled")
+			results[t.PID] = "signaled"
 			signaled++
 		}
 
-		if killTimeout > 0 && sig != syscall.SIGKILL {
-			deadline := time.Now().Add(killTimeout)
-			for time.Now().Before(deadline) {
-				time.Sleep(150 * time.Millisecond)
-				stillListening, err := scan.HasTCPListenerOnPort(context.Background(), port)
-				if err != nil {
-					return err
-				}
-				if !stillListening {
-					if jsonOutput || killJSON {
-						return scan.WriteJSON(os.Stdout, map[string]any{
-							"port":     port,
-							"status":   "signaled",
-							"signaled": signaled,
-							"signal":   sig.String(),
-						})
-					}
-					return nil
+		if killNoWait {
+			// Fire and forget: no escalation, reload check or rescan.
+			if jsonOutput || killJSON {
+				return writeJSON(os.Stdout, killResult(scope.result(matched, results), "signaled", signaled, first))
+			}
+			return nil
+		}
+
+		connections := -1
+		if killDrain > 0 && signaled > 0 {
+			connections, err = drainConnections(context.Background(), port, killDrain, func(n int) {
+				if !jsonOutput && !killJSON {
+					fmt.Fprintf(ui.Stdout(), "%s port %d: %d established connection(s)\n", ui.LabelInfo(ui.Stdout()), port, n)
 				}
+			})
+			if err != nil {
+				return err
 			}
+			if connections > 0 && !jsonOutput && !killJSON {
+				fmt.Fprintf(ui.Stdout(), "%s port %d: %d connection(s) still open after %s; continuing\n", ui.LabelWarn(ui.Stdout()), port, connections, killDrain)
+			}
+		}
+
+		if len(plan) == 1 && !isTerminatingSignal(first) {
+			return confirmReload(scope.result(matched, results), first, targets, signaled)
+		}
 
-			fmt.Fprintf(ui.Stdout(), "%s port %d still busy after %s; sending SIGKILL\n", ui.LabelWarn(ui.Stdout()), port, killTimeout)
+		for i := 1; i < len(plan); i++ {
+			var freed bool
+			if port > 0 {
+				freed, err = waitForPortRelease(port, proto, plan[i-1].Wait)
+			} else {
+				// By name alone, done means the targets are gone; other
+				// processes may share their ports.
+				freed = waitForExit(targets, plan[i-1].Wait)
+			}
+			if err != nil {
+				return err
+			}
+			if freed {
+				break
+			}
+			next := plan[i].Signal
+			fmt.Fprintf(ui.Stdout(), "%s %s still busy after %s; sending %s\n", ui.LabelWarn(ui.Stdout()), what, plan[i-1].Wait, signalName(next))
 			for _, t := range targets {
-				_ = syscall.Kill(t.PID, syscall.SIGKILL)
+				result := "signaled"
+				if err := signalProcess(t.PID, next); err != nil {
+					if errors.Is(err, syscall.ESRCH) {
+						result = "gone"
+					} else {
+						result = "error: " + err.Error()
+					}
+				}
+				audit.Record(t.Port, next, t, result)
 			}
 		}
 
 		if jsonOutput || killJSON {
-			return scan.WriteJSON(os.Stdout, map[string]any{
-				"port":     port,
-				"status":   "signaled",
-				"signaled": signaled,
-				"signal":   sig.String(),
-			})
+			result := killResult(scope.result(matched, results), "signaled", signaled, first)
+			if connections >= 0 {
+				result["connections"] = connections
+			}
+			return writeJSON(os.Stdout, result)
 		}
 
 		return nil
 	},
 }
 
+// drainPoll is how often --drain recounts connections.
+var drainPoll = 250 * time.Millisecond
+
+// drainConnections waits up to timeout for port's established connections
+// to reach zero, calling report with the first count and each change. It
+// returns the last count seen.
+func drainConnections(ctx context.Context, port int, timeout time.Duration, report func(int)) (int, error) {
+	deadline := time.Now().Add(timeout)
+	last := -1
+	for {
+		conns, err := listConnections(ctx, port)
+		if err != nil {
+			return last, fmt.Errorf("count connections: %w", err)
+		}
+		if n := len(conns); n != last {
+			report(n)
+			last = n
+		}
+		if last == 0 || !time.Now().Before(deadline) {
+			return last, nil
+		}
+		time.Sleep(min(drainPoll, time.Until(deadline)))
+	}
+}
+
+// killScope is what kill aims at: a port range (often a single port), a
+// command name, or both intersected.
+type killScope struct {
+	Ports *ports.Range
+	Name  string
+}
+
+// parseKillPorts reads kill's argument: a port, alias or service name, or
+// a start-end range.
+func parseKillPorts(arg string) (ports.Range, error) {
+	if strings.Contains(arg, "-") {
+		if r, err := ports.ParseRange(arg); err == nil {
+			return r, nil
+		}
+	}
+	port, err := parsePortArg(arg)
+	if err != nil {
+		return ports.Range{}, err
+	}
+	return ports.Range{Start: port, End: port}, nil
+}
+
+// port is the scope's port when it is exactly one, else 0.
+func (s killScope) port() int {
+	if s.Ports == nil || s.Ports.Start != s.Ports.End {
+		return 0
+	}
+	return s.Ports.Start
+}
+
+func (s killScope) isRange() bool {
+	return s.Ports != nil && s.Ports.Start != s.Ports.End
+}
+
+func (s killScope) filter(listeners []scan.Listener) []scan.Listener {
+	var out []scan.Listener
+	for _, l := range listeners {
+		if s.Ports != nil && !s.Ports.Contains(l.Port) {
+			continue
+		}
+		if s.Name != "" && !strings.Contains(foldCommand(l.Command, false), foldCommand(s.Name, false)) {
+			continue
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// String names the scope in messages.
+func (s killScope) String() string {
+	var where string
+	switch {
+	case s.isRange():
+		where = fmt.Sprintf("ports %d-%d", s.Ports.Start, s.Ports.End)
+	case s.Ports != nil:
+		where = fmt.Sprintf("port %d", s.Ports.Start)
+	}
+	switch {
+	case s.Name == "":
+		return where
+	case where == "":
+		return fmt.Sprintf("%q", s.Name)
+	}
+	return fmt.Sprintf("%s (%q)", where, s.Name)
+}
+
+// killPortResult is one port of a range kill's JSON summary.
+type killPortResult struct {
+	Port      int              `json:"port"`
+	Processes []killPIDOutcome `json:"processes"`
+}
+
+type killPIDOutcome struct {
+	PID     int    `json:"pid"`
+	Command string `json:"command,omitempty"`
+	Result  string `json:"result"`
+}
+
+// result starts kill's JSON output with the scope: "port", or "range" plus
+// per-port outcomes from results (by PID), and "name" for --name.
+func (s killScope) result(matched []scan.Listener, results map[int]string) map[string]any {
+	out := map[string]any{}
+	switch {
+	case s.isRange():
+		out["range"] = fmt.Sprintf("%d-%d", s.Ports.Start, s.Ports.End)
+		out["ports"] = portOutcomes(matched, results)
+	case s.Ports != nil:
+		out["port"] = s.Ports.Start
+	}
+	if s.Name != "" {
+		out["name"] = s.Name
+	}
+	return out
+}
+
+// portOutcomes groups matched listeners by port, in port order, with each
+// process's result. A process kill never got to is "pending".
+func portOutcomes(matched []scan.Listener, results map[int]string) []killPortResult {
+	out := []killPortResult{}
+	index := make(map[int]int)
+	for _, l := range matched {
+		if l.PID <= 0 {
+			continue
+		}
+		i, ok := index[l.Port]
+		if !ok {
+			i = len(out)
+			index[l.Port] = i
+			out = append(out, killPortResult{Port: l.Port})
+		}
+		if slices.ContainsFunc(out[i].Processes, func(o killPIDOutcome) bool { return o.PID == l.PID }) {
+			continue
+		}
+		result := results[l.PID]
+		if result == "" {
+			result = "pending"
+		}
+		out[i].Processes = append(out[i].Processes, killPIDOutcome{PID: l.PID, Command: l.Command, Result: result})
+	}
+	slices.SortFunc(out, func(a, b killPortResult) int { return a.Port - b.Port })
+	return out
+}
+
+// uniquePIDs keeps the first listener of each process, so a process on
+// several ports is signaled once.
+func uniquePIDs(listeners []scan.Listener) []scan.Listener {
+	var targets []scan.Listener
+	seen := make(map[int]bool)
+	for _, l := range listeners {
+		if l.PID <= 0 || seen[l.PID] {
+			continue
+		}
+		seen[l.PID] = true
+		targets = append(targets, l)
+	}
+	return targets
+}
+
+// waitForExit polls until every target process has exited or wait elapses.
+func waitForExit(targets []scan.Listener, wait time.Duration) bool {
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		time.Sleep(150 * time.Millisecond)
+		if !slices.ContainsFunc(targets, func(t scan.Listener) bool { return processAlive(t.PID) }) {
+			return true
+		}
+	}
+	return false
+}
+
+// killListeners scans what kill may target. An explicit --proto is scanned
+// as given. By default both TCP and UDP are, so a service bound to both on
+// one port is stopped entirely; a UDP scan failure then only warns, and the
+// returned proto ("tcp") says UDP is out of the picture for the re-check.
+func killListeners(ctx context.Context, proto string, explicit bool) ([]scan.Listener, string, error) {
+	if explicit {
+		listeners, err := listenersForProto(ctx, proto, listTCPListeners)
+		return listeners, proto, err
+	}
+	listeners, err := listTCPListeners(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	udp, err := listUDPListeners(ctx)
+	if err != nil {
+		fmt.Fprintf(ui.Stderr(), "%s UDP scan failed, only TCP listeners considered: %v\n", ui.LabelWarn(ui.Stderr()), err)
+		return listeners, "tcp", nil
+	}
+	return append(listeners, udp...), "all", nil
+}
+
+// portBusy reports whether anything still holds port over proto.
+func portBusy(ctx context.Context, port int, proto string) (bool, error) {
+	if proto != "udp" {
+		busy, err := hasTCPListenerOnPort(ctx, port)
+		if err != nil || busy {
+			return busy, err
+		}
+	}
+	if proto != "tcp" {
+		udp, err := listUDPListeners(ctx)
+		if err != nil {
+			return false, fmt.Errorf("udp: %w", err)
+		}
+		return slices.ContainsFunc(udp, func(l scan.Listener) bool { return l.Port == port }), nil
+	}
+	return false, nil
+}
+
+// waitForPortRelease polls until nothing holds port over proto (both TCP
+// and UDP for "all") or wait elapses.
+func waitForPortRelease(port int, proto string, wait time.Duration) (bool, error) {
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		time.Sleep(150 * time.Millisecond)
+		stillListening, err := portBusy(context.Background(), port, proto)
+		if err != nil {
+			return false, err
+		}
+		if !stillListening {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func init() {
 	killCmd.Flags().BoolVar(&killForce, "force", false, "Allow killing processes not owned by your user")
-	killCmd.Flags().StringVar(&killSignal, "signal", "TERM", "Signal to send (TERM, INT, KILL)")
+	killCmd.Flags().StringVar(&killSignal, "signal", defaultKillSignal(), "Signal to send (TERM, INT, QUIT, KILL, HUP, USR1, USR2, or a number like 9), or a list like TERM,KILL tried --timeout apart (default from $"+killSignalEnv+")")
+	killCmd.Flags().StringVar(&killEscalate, "escalate", "", "Full escalation plan, e.g. TERM:2s,INT:3s,KILL (overrides --signal/--timeout)")
 	killCmd.Flags().DurationVar(&killTimeout, "timeout", 2*time.Second, "Wait before escalating to SIGKILL (0 to disable)")
 	killCmd.Flags().BoolVar(&killJSON, "json", false, "Output JSON (alias for --json)")
+	killCmd.Flags().BoolVar(&killNoWait, "no-wait", false, "Send the first signal and return at once: no escalation or post-check, regardless of --timeout")
+	killCmd.Flags().BoolVar(&killExitZero, "exit-zero-on-not-found", false, "Exit 0 when nothing is listening, whatever other flags say (the idle case already does)")
+	killCmd.Flags().StringVar(&killProto, "proto", "all", "Protocol: "+strings.Join(protoChoices, ", ")+" (default both: a process bound to TCP and UDP is signaled once)")
+	killCmd.Flags().StringVar(&killName, "name", "", "Target listeners whose command contains this (case-insensitive), on any port or the one given")
 	killCmd.Flags().BoolVar(&killDryRun, "dry-run", false, "Show targets without sending signals")
+	killCmd.Flags().DurationVar(&killDrain, "drain", 0, "After the first signal, wait up to this long for established connections to close")
+	killCmd.Flags().BoolVar(&killSudo, "sudo", false, "On permission denied, re-run this kill under sudo")
+	killCmd.Flags().BoolVar(&killOnlyMine, "only-mine", false, "Only signal your own processes; skip others instead of refusing")
+	killCmd.Flags().StringSliceVar(&killProtectUsers, "protect-users", nil, "Never signal processes owned by these users without --force (default from config "+protectUsersKey+")")
+	killCmd.Flags().StringVar(&killAudit, "audit-log", "", "Append a JSON record per signaled process to this file (or \"journald\")")
 }
 
+// protectUsersKey is the config key holding the default --protect-users.
+const protectUsersKey = "protect_users"
+
+// protectedUsers is --protect-users if given, else the config default.
+func protectedUsers(flag []string, changed bool) ([]string, error) {
+	if changed {
+		return flag, nil
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.List(protectUsersKey), nil
+}
+
+// checkKillSafety refuses targets kill shouldn't touch without --force:
+// processes owned by a protected user, then processes owned by anyone other
+// than current. The error names the protection that triggered.
+func checkKillSafety(targets []scan.Listener, current string, protected []string) error {
+	for _, t := range targets {
+		if t.User == "" {
+			continue
+		}
+		for _, p := range protected {
+			if t.User == p {
+				return fmt.Errorf("refusing to kill pid %d: owner %q is a protected user (use --force to override)", t.PID, t.User)
+			}
+		}
+		if current != "" && t.User != current {
+			return fmt.Errorf("refusing to kill pid %d owned by %q (use --force to override)", t.PID, t.User)
+		}
+	}
+	return nil
+}
+
+// killSignalEnv overrides the default --signal, e.g. INT for dev servers.
+const killSignalEnv = "FREEPORT_KILL_SIGNAL"
+
+func defaultKillSignal() string {
+	if env := strings.TrimSpace(os.Getenv(killSignalEnv)); env != "" {
+		return env
+	}
+	return "TERM"
+}
+
+// effectiveKillSignals parses --signal, a signal or comma-separated list.
+// When the flag wasn't given and the value came from FREEPORT_KILL_SIGNAL,
+// errors name the variable so a bad environment is easy to spot.
+func effectiveKillSignals(flag string, explicit bool) ([]syscall.Signal, error) {
+	var sigs []syscall.Signal
+	for _, name := range strings.Split(flag, ",") {
+		sig, err := parseSignal(name)
+		if err != nil {
+			if !explicit && os.Getenv(killSignalEnv) != "" {
+				return nil, fmt.Errorf("invalid %s: %w", killSignalEnv, err)
+			}
+			return nil, err
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+// escalationStep sends Signal, then waits up to Wait for the port to free
+// before moving on to the next step. The last step doesn't wait.
+type escalationStep struct {
+	Signal syscall.Signal
+	Wait   time.Duration
+}
+
+// killPlan builds the escalation plan from --escalate, or from --signal and
+// --timeout: the shorthand --signal a,b tries each signal --timeout apart,
+// and a single terminating signal escalates to KILL after --timeout.
+func killPlan(signalExplicit bool) ([]escalationStep, error) {
+	if killEscalate != "" {
+		if signalExplicit {
+			return nil, fmt.Errorf("--escalate and --signal are mutually exclusive")
+		}
+		return parseEscalate(killEscalate)
+	}
+	sigs, err := effectiveKillSignals(killSignal, signalExplicit)
+	if err != nil {
+		return nil, err
+	}
+	return signalPlan(sigs, killTimeout), nil
+}
+
+func signalPlan(sigs []syscall.Signal, timeout time.Duration) []escalationStep {
+	if len(sigs) == 1 {
+		sig := sigs[0]
+		if isTerminatingSignal(sig) && sig != syscall.SIGKILL && timeout > 0 {
+			return []escalationStep{{Signal: sig, Wait: timeout}, {Signal: syscall.SIGKILL}}
+		}
+		return []escalationStep{{Signal: sig}}
+	}
+	plan := make([]escalationStep, len(sigs))
+	for i, sig := range sigs {
+		plan[i] = escalationStep{Signal: sig, Wait: timeout}
+	}
+	plan[len(plan)-1].Wait = 0
+	return plan
+}
+
+// parseEscalate parses "TERM:2s,INT:3s,KILL". Every step but the last needs
+// a wait; the last takes none.
+func parseEscalate(spec string) ([]escalationStep, error) {
+	items := strings.Split(spec, ",")
+	plan := make([]escalationStep, 0, len(items))
+	for i, item := range items {
+		name, wait, hasWait := strings.Cut(strings.TrimSpace(item), ":")
+		sig, err := parseSignal(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --escalate step %q: %w", item, err)
+		}
+		step := escalationStep{Signal: sig}
+		last := i == len(items)-1
+		switch {
+		case hasWait && last:
+			return nil, fmt.Errorf("invalid --escalate step %q: the last step takes no wait", item)
+		case !hasWait && !last:
+			return nil, fmt.Errorf("invalid --escalate step %q: needs a wait, e.g. %s:2s", item, name)
+		case hasWait:
+			d, err := time.ParseDuration(wait)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid --escalate step %q: bad wait %q", item, wait)
+			}
+			step.Wait = d
+		}
+		plan = append(plan, step)
+	}
+	return plan, nil
+}
+
+// signalName is the SIG-prefixed name of a signal kill can send. Signals
+// without a name in supportedSignals are "SIG" plus their number, so JSON
+// consumers never see the OS's free-text description.
+func signalName(sig syscall.Signal) string {
+	for _, s := range supportedSignals {
+		if s.Signal == sig {
+			return "SIG" + s.Name
+		}
+	}
+	return "SIG" + strconv.Itoa(int(sig))
+}
+
+// describeSignal is signalName for people: a signal without a name gets the
+// OS's description too, as in "SIG11 (segmentation fault)".
+func describeSignal(sig syscall.Signal) string {
+	name := signalName(sig)
+	if name == "SIG"+strconv.Itoa(int(sig)) {
+		return name + " (" + sig.String() + ")"
+	}
+	return name
+}
+
+// killResult completes the JSON summary of a kill begun by killScope.result.
+// "signal" is the canonical name (SIGTERM), stable across platforms;
+// "signal_description" is the human-readable form from the OS
+// ("terminated").
+func killResult(result map[string]any, status string, signaled int, sig syscall.Signal) map[string]any {
+	result["status"] = status
+	result["signaled"] = signaled
+	result["signal"] = signalName(sig)
+	result["signal_description"] = sig.String()
+	return result
+}
+
+// supportedSignals are the signals kill, guard and the other signaling
+// commands accept, by name without the SIG prefix. Numbers come from the
+// platform's syscall package.
+var supportedSignals = []struct {
+	Name   string
+	Signal syscall.Signal
+}{
+	{"TERM", syscall.SIGTERM},
+	{"INT", syscall.SIGINT},
+	{"KILL", syscall.SIGKILL},
+	{"HUP", syscall.SIGHUP},
+	{"QUIT", syscall.SIGQUIT},
+	{"USR1", syscall.SIGUSR1},
+	{"USR2", syscall.SIGUSR2},
+}
+
+// parseSignal accepts a supportedSignals name, with or without SIG, or a
+// number as kill(1) takes it, so --signal 9 is SIGKILL.
 func parseSignal(s string) (syscall.Signal, error) {
-	switch strings.ToUpper(strings.TrimSpace(s)) {
-	case "TERM", "SIGTERM":
-		return syscall.SIGTERM, nil
-	case "INT", "SIGINT":
-		return syscall.SIGINT, nil
-	case "KILL", "SIGKILL":
-		return syscall.SIGKILL, nil
-	default:
-		return 0, fmt.Errorf("unsupported signal: %q", s)
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		if n < 1 || n > maxSignal {
+			return 0, fmt.Errorf("signal number %d out of range (1-%d)", n, maxSignal)
+		}
+		return syscall.Signal(n), nil
+	}
+	name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "SIG")
+	for _, sig := range supportedSignals {
+		if sig.Name == name {
+			return sig.Signal, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported signal: %q", s)
+}
+
+// survivedNote says how a process that got sig and kept running did.
+func survivedNote(sig syscall.Signal) string {
+	if sig == syscall.SIGHUP {
+		return "reloaded"
+	}
+	return "still running after " + signalName(sig)
+}
+
+// reloadSettle is how long to give a process to handle a reload signal
+// before checking that it survived.
+var reloadSettle = 500 * time.Millisecond
+
+// isTerminatingSignal reports whether sig is expected to stop the target,
+// and so may be escalated to KILL. HUP (reload), USR1/USR2 (often a state
+// dump or log reopen), CONT and WINCH are expected to leave it running;
+// anything else, a number like 6 (ABRT) included, ends it as kill(1) would.
+func isTerminatingSignal(sig syscall.Signal) bool {
+	switch sig {
+	case syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGCONT, syscall.SIGWINCH:
+		return false
+	}
+	return true
+}
+
+// processAlive probes pid with the null signal. EPERM still means the
+// process exists; we just may not signal it.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// confirmReload checks that every target survived a non-terminating signal.
+// Success here means "still running", not "port freed".
+func confirmReload(target map[string]any, sig syscall.Signal, targets []scan.Listener, signaled int) error {
+	time.Sleep(reloadSettle)
+
+	var exited []int
+	for _, t := range targets {
+		if processAlive(t.PID) {
+			if !(jsonOutput || killJSON) {
+				fmt.Fprintf(ui.Stdout(), "%s pid %d (%s) %s\n", ui.LabelOK(ui.Stdout()), t.PID, t.Command, survivedNote(sig))
+			}
+			continue
+		}
+		exited = append(exited, t.PID)
+		if !(jsonOutput || killJSON) {
+			fmt.Fprintf(ui.Stdout(), "%s pid %d (%s) exited unexpectedly\n", ui.LabelErr(ui.Stdout()), t.PID, t.Command)
+		}
+	}
+
+	if jsonOutput || killJSON {
+		status := "reloaded"
+		if len(exited) > 0 {
+			status = "exited"
+		}
+		result := killResult(target, status, signaled, sig)
+		result["exited"] = exited
+		if err := writeJSON(os.Stdout, result); err != nil {
+			return err
+		}
+	}
+
+	if len(exited) > 0 {
+		return fmt.Errorf("%d process(es) exited after %s", len(exited), describeSignal(sig))
 	}
+	return nil
 }
@@ -1,6 +1,21 @@
 package cmd
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/scan"
+)
 
 func TestParseSignal(t *testing.T) {
 	cases := []struct {
@@ -13,8 +28,24 @@ func TestParseSignal(t *testing.T) {
 		{"SIGINT", true},
 		{"KILL", true},
 		{"SIGKILL", true},
+		{"HUP", true},
+		{"SIGHUP", true},
+		{"QUIT", true},
+		{"SIGQUIT", true},
+		{"USR1", true},
+		{"sigusr1", true},
+		{"USR2", true},
+		{"SIGUSR2", true},
+		{"9", true},
+		{" 15 ", true},
+		{"64", true},
 		{"", false},
-		{"HUP", false},
+		{"WINCH", false},
+		{"SIG", false},
+		{"0", false},
+		{"65", false},
+		{"-9", false},
+		{"SIG9", false},
 	}
 
 	for _, tc := range cases {
@@ -28,3 +59,467 @@ func TestParseSignal(t *testing.T) {
 	}
 }
 
+func TestProcessAlive(t *testing.T) {
+	if !processAlive(os.Getpid()) {
+		t.Fatalf("expected current process to be alive")
+	}
+
+	child := exec.Command("true")
+	if err := child.Run(); err != nil {
+		t.Skipf("cannot run helper process: %v", err)
+	}
+	if processAlive(child.Process.Pid) {
+		t.Fatalf("expected reaped child pid %d to be gone", child.Process.Pid)
+	}
+}
+
+func TestParseSignalNumbers(t *testing.T) {
+	top := strconv.Itoa(maxSignal)
+	for in, want := range map[string]syscall.Signal{"9": syscall.SIGKILL, "15": syscall.SIGTERM, "1": syscall.SIGHUP, top: syscall.Signal(maxSignal)} {
+		if got, err := parseSignal(in); err != nil || got != want {
+			t.Fatalf("parseSignal(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"0", "-1", strconv.Itoa(maxSignal + 1)} {
+		if _, err := parseSignal(in); err == nil {
+			t.Fatalf("expected parseSignal(%q) to be out of range", in)
+		}
+	}
+	if got, _ := parseSignal("9"); signalName(got) != "SIGKILL" {
+		t.Fatalf("expected 9 to be reported as SIGKILL, got %s", signalName(got))
+	}
+
+	// Signals without a name get a stable one from their number.
+	got, _ := parseSignal(top)
+	if name := signalName(got); name != "SIG"+top {
+		t.Fatalf("expected SIG%s, got %q", top, name)
+	}
+	if desc := describeSignal(got); !strings.HasPrefix(desc, "SIG"+top+" (") {
+		t.Fatalf("expected the OS description alongside SIG%s, got %q", top, desc)
+	}
+	if desc := describeSignal(syscall.SIGTERM); desc != "SIGTERM" {
+		t.Fatalf("expected a named signal to describe as its name, got %q", desc)
+	}
+}
+
+func TestIsTerminatingSignal(t *testing.T) {
+	for _, sig := range []syscall.Signal{syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGCONT, syscall.SIGWINCH} {
+		if isTerminatingSignal(sig) {
+			t.Fatalf("expected %v to leave the process running", sig)
+		}
+		if plan := signalPlan([]syscall.Signal{sig}, 2*time.Second); len(plan) != 1 {
+			t.Fatalf("expected no KILL escalation after %v, got %+v", sig, plan)
+		}
+	}
+	for _, sig := range []syscall.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGKILL, syscall.SIGABRT, syscall.SIGSEGV, syscall.SIGALRM, syscall.SIGPIPE} {
+		if !isTerminatingSignal(sig) {
+			t.Fatalf("expected %v to be terminating", sig)
+		}
+	}
+	if plan := signalPlan([]syscall.Signal{syscall.SIGQUIT}, 2*time.Second); len(plan) != 2 || plan[1].Signal != syscall.SIGKILL {
+		t.Fatalf("expected QUIT to escalate to KILL, got %+v", plan)
+	}
+}
+
+func TestKillSignalDefaultFromEnv(t *testing.T) {
+	t.Setenv(killSignalEnv, "INT")
+	if got := defaultKillSignal(); got != "INT" {
+		t.Fatalf("expected default INT from env, got %q", got)
+	}
+	sigs, err := effectiveKillSignals(defaultKillSignal(), false)
+	if err != nil || len(sigs) != 1 || sigs[0] != syscall.SIGINT {
+		t.Fatalf("expected SIGINT, got %v (err=%v)", sigs, err)
+	}
+
+	// An explicit flag wins over the environment.
+	sigs, err = effectiveKillSignals("KILL", true)
+	if err != nil || len(sigs) != 1 || sigs[0] != syscall.SIGKILL {
+		t.Fatalf("expected explicit SIGKILL, got %v (err=%v)", sigs, err)
+	}
+}
+
+func TestKillSignalDefaultWithoutEnv(t *testing.T) {
+	t.Setenv(killSignalEnv, "")
+	if got := defaultKillSignal(); got != "TERM" {
+		t.Fatalf("expected TERM fallback, got %q", got)
+	}
+}
+
+func TestKillSignalInvalidEnvNamesVariable(t *testing.T) {
+	t.Setenv(killSignalEnv, "BOGUS")
+	_, err := effectiveKillSignals(defaultKillSignal(), false)
+	if err == nil || !strings.Contains(err.Error(), killSignalEnv) {
+		t.Fatalf("expected error naming %s, got %v", killSignalEnv, err)
+	}
+}
+
+func TestSignalListShorthandPlan(t *testing.T) {
+	sigs, err := effectiveKillSignals("TERM, INT ,KILL", true)
+	if err != nil {
+		t.Fatalf("effectiveKillSignals: %v", err)
+	}
+	plan := signalPlan(sigs, 2*time.Second)
+	want := []escalationStep{
+		{Signal: syscall.SIGTERM, Wait: 2 * time.Second},
+		{Signal: syscall.SIGINT, Wait: 2 * time.Second},
+		{Signal: syscall.SIGKILL},
+	}
+	if !slices.Equal(plan, want) {
+		t.Fatalf("plan = %v, want %v", plan, want)
+	}
+
+	if _, err := effectiveKillSignals("TERM,BOGUS", true); err == nil || !strings.Contains(err.Error(), "BOGUS") {
+		t.Fatalf("expected error naming the bad signal, got %v", err)
+	}
+}
+
+func TestKillResultUsesCanonicalSignalName(t *testing.T) {
+	sigs, err := effectiveKillSignals("TERM", true)
+	if err != nil {
+		t.Fatalf("effectiveKillSignals: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, killResult(map[string]any{"port": 3000}, "signaled", 1, sigs[0])); err != nil {
+		t.Fatalf("writeJSON: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal %q: %v", buf.String(), err)
+	}
+	if got["signal"] != "SIGTERM" {
+		t.Fatalf("signal = %v, want SIGTERM", got["signal"])
+	}
+	if got["signal_description"] != syscall.SIGTERM.String() {
+		t.Fatalf("signal_description = %v, want %q", got["signal_description"], syscall.SIGTERM.String())
+	}
+}
+
+func TestSingleSignalPlanEscalatesToKill(t *testing.T) {
+	cases := []struct {
+		sig     syscall.Signal
+		timeout time.Duration
+		want    []escalationStep
+	}{
+		{syscall.SIGTERM, time.Second, []escalationStep{{Signal: syscall.SIGTERM, Wait: time.Second}, {Signal: syscall.SIGKILL}}},
+		{syscall.SIGTERM, 0, []escalationStep{{Signal: syscall.SIGTERM}}},
+		{syscall.SIGKILL, time.Second, []escalationStep{{Signal: syscall.SIGKILL}}},
+		{syscall.SIGHUP, time.Second, []escalationStep{{Signal: syscall.SIGHUP}}},
+	}
+	for _, tc := range cases {
+		if got := signalPlan([]syscall.Signal{tc.sig}, tc.timeout); !slices.Equal(got, tc.want) {
+			t.Errorf("signalPlan(%v, %v) = %v, want %v", tc.sig, tc.timeout, got, tc.want)
+		}
+	}
+}
+
+func TestParseEscalate(t *testing.T) {
+	plan, err := parseEscalate("TERM:2s,INT:3s,KILL")
+	if err != nil {
+		t.Fatalf("parseEscalate: %v", err)
+	}
+	want := []escalationStep{
+		{Signal: syscall.SIGTERM, Wait: 2 * time.Second},
+		{Signal: syscall.SIGINT, Wait: 3 * time.Second},
+		{Signal: syscall.SIGKILL},
+	}
+	if !slices.Equal(plan, want) {
+		t.Fatalf("plan = %v, want %v", plan, want)
+	}
+
+	for _, bad := range []string{"TERM,KILL", "TERM:2s,KILL:1s", "TERM:soon,KILL", "NOPE:1s,KILL"} {
+		if _, err := parseEscalate(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestCheckKillSafetyProtectedUser(t *testing.T) {
+	targets := []scan.Listener{{PID: 42, User: "postgres"}}
+
+	// Protection applies even to the caller's own processes.
+	err := checkKillSafety(targets, "postgres", []string{"root", "postgres"})
+	if err == nil || !strings.Contains(err.Error(), "protected user") {
+		t.Fatalf("expected protected-user refusal, got %v", err)
+	}
+
+	if err := checkKillSafety(targets, "postgres", nil); err != nil {
+		t.Fatalf("unexpected refusal without protection: %v", err)
+	}
+}
+
+func TestCheckKillSafetyOwnership(t *testing.T) {
+	targets := []scan.Listener{{PID: 42, User: "alice"}}
+	err := checkKillSafety(targets, "bob", []string{"root"})
+	if err == nil || !strings.Contains(err.Error(), `owned by "alice"`) {
+		t.Fatalf("expected ownership refusal, got %v", err)
+	}
+}
+
+func TestKillPermissionErrorSuggestsSudo(t *testing.T) {
+	args := []string{"kill", "80", "--signal", "HUP", "--audit-log", "/tmp/my log.jsonl", "--sudo"}
+	err := killPermissionError(4242, "nginx", args)
+	msg := err.Error()
+	for _, want := range []string{"permission denied signaling pid 4242 (nginx)", "kill 80 --signal HUP --audit-log '/tmp/my log.jsonl'", "sudo ", "--sudo)"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("expected %q in %q", want, msg)
+		}
+	}
+	if strings.Contains(msg, "jsonl' --sudo") {
+		t.Fatalf("suggested command should not repeat --sudo: %q", msg)
+	}
+}
+
+func TestWithoutFlagKeepsArgsAfterDash(t *testing.T) {
+	got := withoutFlag([]string{"kill", "--sudo", "3000", "--sudo=true", "--signal", "INT", "--", "--sudo"}, "sudo")
+	want := []string{"kill", "3000", "--signal", "INT", "--", "--sudo"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestKillReportsEPERMWithSudoHint(t *testing.T) {
+	stubPortArgLookups(t, "", nil)
+	stubListeners(t, func() []scan.Listener {
+		return []scan.Listener{{Port: 8080, PID: 4242, Command: "setuid-srv"}}
+	})
+	origKill, origSudo, origArgs := signalProcess, sudoRerun, invocationArgs
+	t.Cleanup(func() { signalProcess, sudoRerun, invocationArgs = origKill, origSudo, origArgs })
+	signalProcess = func(int, syscall.Signal) error { return syscall.EPERM }
+	sudoRerun = func([]string) (int, error) {
+		t.Fatal("sudo re-exec must be opt-in")
+		return 0, nil
+	}
+	invocationArgs = []string{"kill", "8080"}
+
+	err := killCmd.RunE(killCmd, []string{"8080"})
+	if err == nil || !strings.Contains(err.Error(), "permission denied signaling pid 4242 (setuid-srv)") || !strings.Contains(err.Error(), "kill 8080 (or add --sudo)") {
+		t.Fatalf("expected EPERM with sudo suggestion, got %v", err)
+	}
+}
+
+func TestDrainConnectionsReportsCountsToZero(t *testing.T) {
+	orig, origPoll := listConnections, drainPoll
+	t.Cleanup(func() { listConnections, drainPoll = orig, origPoll })
+	drainPoll = time.Millisecond
+
+	counts := []int{3, 2, 2, 1, 0}
+	calls := 0
+	listConnections = func(_ context.Context, port int) ([]scan.Connection, error) {
+		if port != 8080 {
+			t.Fatalf("unexpected port %d", port)
+		}
+		n := counts[min(calls, len(counts)-1)]
+		calls++
+		return make([]scan.Connection, n), nil
+	}
+
+	var reported []int
+	final, err := drainConnections(context.Background(), 8080, time.Second, func(n int) { reported = append(reported, n) })
+	if err != nil || final != 0 {
+		t.Fatalf("expected to drain to 0, got %d (err=%v)", final, err)
+	}
+	if !slices.Equal(reported, []int{3, 2, 1, 0}) {
+		t.Fatalf("expected each change reported once, got %v", reported)
+	}
+
+	// Connections that never close give up at the deadline with the last count.
+	listConnections = func(context.Context, int) ([]scan.Connection, error) {
+		return make([]scan.Connection, 2), nil
+	}
+	final, err = drainConnections(context.Background(), 8080, 20*time.Millisecond, func(int) {})
+	if err != nil || final != 2 {
+		t.Fatalf("expected timeout with 2 connections, got %d (err=%v)", final, err)
+	}
+}
+
+func TestKillNoWaitSkipsPostCheck(t *testing.T) {
+	stubPortArgLookups(t, "", nil)
+	scans := 0
+	stubListeners(t, func() []scan.Listener {
+		if scans++; scans > 1 {
+			t.Fatal("--no-wait must not rescan after signaling")
+		}
+		return []scan.Listener{{Port: 8080, PID: 4242, Command: "node"}}
+	})
+	origKill, origHas, origNoWait := signalProcess, hasTCPListenerOnPort, killNoWait
+	t.Cleanup(func() { signalProcess, hasTCPListenerOnPort, killNoWait = origKill, origHas, origNoWait })
+	var sent []syscall.Signal
+	signalProcess = func(pid int, sig syscall.Signal) error {
+		sent = append(sent, sig)
+		return nil
+	}
+	hasTCPListenerOnPort = func(context.Context, int) (bool, error) {
+		t.Fatal("--no-wait must not wait for the port to free")
+		return false, nil
+	}
+	killNoWait = true
+
+	start := time.Now()
+	if err := killCmd.RunE(killCmd, []string{"8080"}); err != nil {
+		t.Fatalf("kill --no-wait: %v", err)
+	}
+	if !slices.Equal(sent, []syscall.Signal{syscall.SIGTERM}) {
+		t.Fatalf("expected a single SIGTERM, sent %v", sent)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("--no-wait took %s", elapsed)
+	}
+}
+
+func TestKillTargetsTCPAndUDPOnce(t *testing.T) {
+	stubPortArgLookups(t, "", nil)
+	stubListeners(t, func() []scan.Listener {
+		return []scan.Listener{{Port: 53, PID: 77, Command: "dnsmasq", Proto: "tcp"}}
+	})
+	udpBusy := 3
+	listUDPListeners = func(context.Context) ([]scan.Listener, error) {
+		if udpBusy == 0 {
+			return nil, nil
+		}
+		udpBusy--
+		return []scan.Listener{{Port: 53, PID: 77, Command: "dnsmasq", Proto: "udp"}}, nil
+	}
+	origKill, origHas, origNoWait := signalProcess, hasTCPListenerOnPort, killNoWait
+	t.Cleanup(func() { signalProcess, hasTCPListenerOnPort, killNoWait = origKill, origHas, origNoWait })
+	var sent []int
+	signalProcess = func(pid int, _ syscall.Signal) error {
+		sent = append(sent, pid)
+		return nil
+	}
+	hasTCPListenerOnPort = func(context.Context, int) (bool, error) { return false, nil }
+	killNoWait = true
+
+	if err := killCmd.RunE(killCmd, []string{"53"}); err != nil {
+		t.Fatalf("kill 53: %v", err)
+	}
+	if !slices.Equal(sent, []int{77}) {
+		t.Fatalf("expected pid 77 signaled once across TCP and UDP, sent %v", sent)
+	}
+
+	// TCP is free at once, but the port only counts as released when the
+	// UDP socket is gone too.
+	freed, err := waitForPortRelease(53, "all", 2*time.Second)
+	if err != nil || !freed || udpBusy != 0 {
+		t.Fatalf("expected release after UDP freed, got freed=%v err=%v (udp busy %d)", freed, err, udpBusy)
+	}
+	udpBusy = 1 << 30
+	if freed, err := waitForPortRelease(53, "all", 400*time.Millisecond); err != nil || freed {
+		t.Fatalf("expected a UDP holder to keep the port busy, got freed=%v err=%v", freed, err)
+	}
+	if freed, err := waitForPortRelease(53, "tcp", 400*time.Millisecond); err != nil || !freed {
+		t.Fatalf("expected --proto tcp to ignore UDP, got freed=%v err=%v", freed, err)
+	}
+}
+
+func TestKillNumericSignalEndsTargetLikeKill(t *testing.T) {
+	stubPortArgLookups(t, "", nil)
+	stubListeners(t, func() []scan.Listener {
+		return []scan.Listener{{Port: 8080, PID: 4242, Command: "node"}}
+	})
+	origKill, origHas := signalProcess, hasTCPListenerOnPort
+	t.Cleanup(func() {
+		signalProcess, hasTCPListenerOnPort = origKill, origHas
+		f := killCmd.Flags().Lookup("signal")
+		f.Value.Set(f.DefValue)
+		f.Changed = false
+	})
+	var sent []syscall.Signal
+	signalProcess = func(_ int, sig syscall.Signal) error {
+		sent = append(sent, sig)
+		return nil
+	}
+	hasTCPListenerOnPort = func(context.Context, int) (bool, error) { return false, nil }
+	if err := killCmd.Flags().Set("signal", "6"); err != nil {
+		t.Fatal(err)
+	}
+
+	// ABRT ends the process: the port freeing is success, not a reload
+	// target that "exited unexpectedly".
+	if err := killCmd.RunE(killCmd, []string{"8080"}); err != nil {
+		t.Fatalf("kill --signal 6: %v", err)
+	}
+	if !slices.Equal(sent, []syscall.Signal{syscall.SIGABRT}) {
+		t.Fatalf("expected SIGABRT alone once the port freed, sent %v", sent)
+	}
+}
+
+func TestKillScopeSelectsTargets(t *testing.T) {
+	listeners := []scan.Listener{
+		{Port: 3000, PID: 10, Command: "node"},
+		{Port: 3001, PID: 10, Command: "node"}, // same process, second port
+		{Port: 4000, PID: 11, Command: "Node"},
+		{Port: 3000, PID: 12, Command: "nginx"},
+		{Port: 5000, PID: 0, Command: "node"}, // no PID to signal
+	}
+	pids := func(ls []scan.Listener) []int {
+		var out []int
+		for _, l := range ls {
+			out = append(out, l.PID)
+		}
+		return out
+	}
+	rng := func(start, end int) *ports.Range { return &ports.Range{Start: start, End: end} }
+
+	// list's and foreach's --case-sensitive don't reach kill --name.
+	listCaseSensitive, foreachCaseSensitive = true, true
+	t.Cleanup(func() { listCaseSensitive, foreachCaseSensitive = false, false })
+
+	cases := []struct {
+		scope killScope
+		want  []int
+	}{
+		{killScope{Name: "NODE"}, []int{10, 11}},
+		{killScope{Ports: rng(3000, 3000), Name: "node"}, []int{10}},
+		{killScope{Ports: rng(3000, 3000)}, []int{10, 12}},
+		{killScope{Ports: rng(3000, 3005)}, []int{10, 12}},
+		{killScope{Ports: rng(3001, 4000), Name: "node"}, []int{10, 11}},
+		{killScope{Ports: rng(4000, 4000), Name: "nginx"}, nil},
+	}
+	for _, tc := range cases {
+		if got := pids(uniquePIDs(tc.scope.filter(listeners))); !slices.Equal(got, tc.want) {
+			t.Fatalf("%s: got pids %v, want %v", tc.scope, got, tc.want)
+		}
+	}
+
+	if got := (killScope{Name: "node"}).String(); got != `"node"` {
+		t.Fatalf("name-only scope = %s", got)
+	}
+	if got := (killScope{Name: "node"}).result(nil, nil); got["name"] != "node" || got["port"] != nil {
+		t.Fatalf("expected JSON target without a port, got %v", got)
+	}
+}
+
+func TestKillRangeReportsPerPortResults(t *testing.T) {
+	if r, err := parseKillPorts("3000-3005"); err != nil || r.Start != 3000 || r.End != 3005 {
+		t.Fatalf("parseKillPorts(3000-3005) = %+v, %v", r, err)
+	}
+	if r, err := parseKillPorts("3000"); err != nil || r.Start != 3000 || r.End != 3000 {
+		t.Fatalf("parseKillPorts(3000) = %+v, %v", r, err)
+	}
+
+	scope := killScope{Ports: &ports.Range{Start: 3000, End: 3005}}
+	matched := []scan.Listener{
+		{Port: 3002, PID: 20, Command: "vite"},
+		{Port: 3000, PID: 10, Command: "node"},
+		{Port: 3000, PID: 11, Command: "node"},
+		{Port: 3001, PID: 10, Command: "node"},
+	}
+	result := scope.result(matched, map[int]string{10: "signaled", 11: "gone"})
+	if result["range"] != "3000-3005" || result["port"] != nil {
+		t.Fatalf("expected a range target, got %v", result)
+	}
+	got := result["ports"].([]killPortResult)
+	want := []killPortResult{
+		{Port: 3000, Processes: []killPIDOutcome{{10, "node", "signaled"}, {11, "node", "gone"}}},
+		{Port: 3001, Processes: []killPIDOutcome{{10, "node", "signaled"}}},
+		{Port: 3002, Processes: []killPIDOutcome{{20, "vite", "pending"}}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Port != want[i].Port || !slices.Equal(got[i].Processes, want[i].Processes) {
+			t.Fatalf("port %d: got %+v, want %+v", want[i].Port, got[i], want[i])
+		}
+	}
+}
@@ -3,127 +3,563 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"slices"
 	"sort"
 	"strings"
+	"syscall"
+	"time"
 
-	"fp/internal/scan"
-	"fp/internal/ui"
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 var listCmd = &cobra.Command{
-	Use:   "list [filter]",
+	Use:   "list [filter] [--watch --on-change -- <cmd...>]",
 	Short: "List listening TCP ports (best-effort)",
 	Long: `List listening TCP ports (best-effort).
 
 Optional filter argument matches against command name, executable path,
-and command line (case-insensitive).
-
-Examples:
-  fp list           # all ports
-  fp list node      # ports used by node processes
-  fp list python    # ports used by python processes
-  fp list redis     # ports used by redis`,
-	Args: cobra.MaximumNArgs(1),
+and command line. Matching, --exclude-command and --by command ignore case
+unless --case-sensitive is given.
+
+With --watch --on-change, the command after -- runs whenever the listener
+set changes. It sees FREEPORT_ADDED, FREEPORT_REMOVED and FREEPORT_CHANGED,
+each a comma-separated list of sockets such as "tcp 127.0.0.1:3000".`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			args = args[:dash]
+		}
+		return cobra.MaximumNArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		listeners, err := scan.ListTCPListeners(context.Background())
-		if err != nil {
-			return err
+		var hookArgs []string
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			args, hookArgs = args[:dash], args[dash:]
 		}
-
 		var filter string
 		if len(args) > 0 {
-			filter = strings.ToLower(args[0])
+			filter = args[0]
+		}
+		if !validListFormat(listFormat) {
+			return fmt.Errorf("invalid format %q (expected %s)", listFormat, strings.Join(listFormats, ", "))
+		}
+		if err := validateProto(listProto); err != nil {
+			return err
+		}
+		if listSort != "port" && listSort != "none" {
+			return fmt.Errorf("invalid sort %q (expected port or none)", listSort)
+		}
+		if listHTMLDocument && listFormat != "html" {
+			return fmt.Errorf("--html-document needs --format html")
+		}
+		if listScope != "" && listScope != "loopback" && listScope != "external" {
+			return fmt.Errorf("invalid scope %q (expected loopback or external)", listScope)
+		}
+		if !slices.Contains(listGroupKeys, listBy) {
+			return fmt.Errorf("invalid --by %q (expected %s)", listBy, strings.Join(listGroupKeys[1:], ", "))
+		}
+		if listBy != "" && listFormat != "table" && listFormat != "json" {
+			return fmt.Errorf("--by works with table or json output, not %s", listFormat)
+		}
+		if listOnlyMine && listUser != "" {
+			return fmt.Errorf("--only-mine and --user are mutually exclusive")
+		}
+		if listRetry < 0 {
+			return fmt.Errorf("--retry must not be negative")
+		}
+		if listWarnBacklog < 1 || listWarnBacklog > 100 {
+			return fmt.Errorf("--warn-backlog must be a percentage from 1 to 100")
+		}
+		listBacklogCheck = listEnrich || cmd.Flags().Changed("warn-backlog")
+		if listOnChange && (!listWatch || len(hookArgs) == 0) {
+			return fmt.Errorf("--on-change needs --watch and a command after --")
+		}
+		if !listOnChange && len(hookArgs) > 0 {
+			return fmt.Errorf("unexpected command after --; did you mean --on-change?")
+		}
+
+		if listExplain || listExplainOnly {
+			if err := explainList(listExplainOnly); err != nil || listExplainOnly {
+				return err
+			}
+		}
+
+		if listWatch {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			var hook *changeHook
+			if listOnChange {
+				hook = newChangeHook(hookArgs, listDebounce)
+			}
+			return watchList(ctx, filter, hook)
+		}
+		ctx := context.Background()
+		if listTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, listTimeout)
+			defer cancel()
+		}
+		return runList(ctx, filter)
+	},
+}
+
+func runList(ctx context.Context, filter string) error {
+	listeners, backends, err := collectListeners(ctx, filter, listPort)
+	if err != nil {
+		return err
+	}
+	return renderListeners(listeners, backends)
+}
+
+// listRetryDelay is the pause between --retry attempts.
+var listRetryDelay = 200 * time.Millisecond
+
+// scanListeners runs the primary backend, or every backend with
+// --ignore-errors, in which case per-backend results are returned as well.
+// A scan that succeeds but finds nothing is repeated up to --retry times,
+// for VMs where the first ss/lsof run occasionally comes back empty.
+func scanListeners(ctx context.Context) ([]scan.Listener, []scan.BackendResult, error) {
+	for attempt := 0; ; attempt++ {
+		listeners, backends, err := scanListenersOnce(ctx)
+		if err != nil || len(listeners) > 0 || attempt >= listRetry {
+			return listeners, backends, err
+		}
+		select {
+		case <-ctx.Done():
+			return listeners, backends, nil
+		case <-time.After(listRetryDelay):
+		}
+	}
+}
+
+func scanListenersOnce(ctx context.Context) ([]scan.Listener, []scan.BackendResult, error) {
+	if !listIgnoreErrors {
+		listeners, err := listenersForProto(ctx, listProto, listTCPListeners)
+		return listeners, nil, err
+	}
+	if listProto == "udp" {
+		listeners, err := listUDPListeners(ctx)
+		return listeners, nil, err
+	}
+	listeners, backends, err := listTCPListenersAll(ctx)
+	if err == nil && listProto == "all" {
+		// --ignore-errors merges TCP backends; a failed UDP scan is just noted.
+		if udp, udpErr := listUDPListeners(ctx); udpErr != nil {
+			fmt.Fprintf(ui.Stderr(), "%s udp scan failed: %v\n", ui.LabelWarn(ui.Stderr()), udpErr)
+		} else {
+			listeners = append(listeners, udp...)
+		}
+	}
+	for _, b := range backends {
+		switch {
+		case b.Partial:
+			fmt.Fprintf(ui.Stderr(), "%s %s cut off (%s); using %d listeners parsed so far\n", ui.LabelWarn(ui.Stderr()), b.Name, b.Error, b.Count)
+		case !b.OK:
+			fmt.Fprintf(ui.Stderr(), "%s %s failed: %s\n", ui.LabelWarn(ui.Stderr()), b.Name, b.Error)
 		}
+	}
+	return listeners, backends, err
+}
+
+// collectListeners scans and applies list's filters, keeping only port when
+// it is non-zero.
+func collectListeners(ctx context.Context, filter string, port int) ([]scan.Listener, []scan.BackendResult, error) {
+	listeners, backends, err := scanListeners(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		if listPort > 0 {
-			filtered := listeners[:0]
-			for _, l := range listeners {
-				if l.Port == listPort {
-					filtered = append(filtered, l)
-				}
+	if port > 0 {
+		filtered := listeners[:0]
+		for _, l := range listeners {
+			if l.Port == port {
+				filtered = append(filtered, l)
 			}
-			listeners = filtered
 		}
+		listeners = filtered
+	}
 
-		if filter != "" {
-			// Enrich for better filtering if not already verbose
-			if !listVerbose {
-				scan.EnrichListenersWithProcessInfo(context.Background(), listeners)
+	if r, ok, err := portComparisonRange(listPortLT, listPortGT, listPortLTE, listPortGTE); err != nil {
+		return nil, nil, err
+	} else if ok {
+		filtered := listeners[:0]
+		for _, l := range listeners {
+			if r.Contains(l.Port) {
+				filtered = append(filtered, l)
 			}
-			filtered := listeners[:0]
-			for _, l := range listeners {
-				if matchesFilter(l, filter) {
-					filtered = append(filtered, l)
-				}
+		}
+		listeners = filtered
+	}
+
+	if listRange != "" {
+		r, err := ports.ParseRange(listRange)
+		if err != nil {
+			return nil, nil, err
+		}
+		filtered := listeners[:0]
+		for _, l := range listeners {
+			if r.Contains(l.Port) {
+				filtered = append(filtered, l)
 			}
-			listeners = filtered
 		}
+		listeners = filtered
+	}
 
-		if listUnique {
-			seen := make(map[string]bool)
-			filtered := listeners[:0]
-			for _, l := range listeners {
-				key := fmt.Sprintf("%d:%d", l.Port, l.PID)
-				if seen[key] {
-					continue
-				}
-				seen[key] = true
+	if listScope != "" {
+		// IPv4-mapped binds count as IPv4 here: [::ffff:127.0.0.1] is loopback.
+		wantLoopback := listScope == "loopback"
+		filtered := listeners[:0]
+		for _, l := range listeners {
+			if l.IsLoopback() == wantLoopback {
 				filtered = append(filtered, l)
 			}
-			listeners = filtered
 		}
+		listeners = filtered
+	}
+
+	enriched := false
+	enrich := func() {
+		if !enriched {
+			enrichProcessInfo(ctx, listeners)
+			enriched = true
+		}
+	}
+
+	if filter != "" || len(listExcludeCommands) > 0 {
+		// Enrich for better filtering against the full command line
+		enrich()
+		filtered := listeners[:0]
+		for _, l := range listeners {
+			if filter != "" && !matchesFilter(l, filter, listCaseSensitive) {
+				continue
+			}
+			if slices.ContainsFunc(listExcludeCommands, func(x string) bool { return matchesFilter(l, x, listCaseSensitive) }) {
+				continue
+			}
+			filtered = append(filtered, l)
+		}
+		listeners = filtered
+	}
+
+	if owner := listUser; owner != "" || listOnlyMine {
+		if listOnlyMine {
+			if owner = currentUsername(); owner == "" {
+				return nil, nil, fmt.Errorf("--only-mine: can't determine the current user")
+			}
+		}
+		if slices.ContainsFunc(listeners, func(l scan.Listener) bool { return l.User == "" }) {
+			// ss doesn't report owners; enrichment reads them from /proc.
+			enrich()
+		}
+		listeners = ownedBy(listeners, owner)
+	}
 
+	if listStartedAfter != "" || listStartedBefore != "" {
+		// Relative bounds are re-read on every --watch refresh.
+		var after, before time.Time
+		now := time.Now()
+		if listStartedAfter != "" {
+			if after, err = parseTimeBound(listStartedAfter, now); err != nil {
+				return nil, nil, fmt.Errorf("--started-after: %w", err)
+			}
+		}
+		if listStartedBefore != "" {
+			if before, err = parseTimeBound(listStartedBefore, now); err != nil {
+				return nil, nil, fmt.Errorf("--started-before: %w", err)
+			}
+		}
+		enrich()
+		listeners = filterStarted(listeners, after, before)
+	}
+
+	if listUnique {
+		seen := make(map[string]bool)
+		filtered := listeners[:0]
+		for _, l := range listeners {
+			key := fmt.Sprintf("%d:%d", l.Port, l.PID)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			filtered = append(filtered, l)
+		}
+		listeners = filtered
+	}
+
+	// --sort none keeps the backend's discovery order, for correlating with
+	// --dump-raw output line by line.
+	if listSort == "port" {
 		sort.Slice(listeners, func(i, j int) bool {
 			if listeners[i].Port != listeners[j].Port {
 				return listeners[i].Port < listeners[j].Port
 			}
 			return listeners[i].PID < listeners[j].PID
 		})
+	}
 
-		if listVerbose {
-			scan.EnrichListenersWithProcessInfo(context.Background(), listeners)
+	// Grouping by executable needs the path enrichment reads from /proc or
+	// lsof; the dot graph's parent edges need the PPIDs ps reports.
+	if listVerbose || listEnrich || listBy == "exe" || listFormat == "dot" {
+		enrich()
+	}
+	if listResolve {
+		scan.ResolveHostnames(ctx, net.DefaultResolver, listeners, resolveTimeout)
+	}
+	if listBacklogCheck {
+		if err := scan.AddBacklog(ctx, listeners); err != nil {
+			fmt.Fprintf(ui.Stderr(), "%s accept queues unavailable: %v\n", ui.LabelWarn(ui.Stderr()), err)
 		}
+	}
+	if listConnCount {
+		if err := countConnections(ctx, listeners); err != nil {
+			fmt.Fprintf(ui.Stderr(), "%s connection counts unavailable: %v\n", ui.LabelWarn(ui.Stderr()), err)
+		}
+	}
+	return listeners, backends, nil
+}
+
+// listFormats are the values accepted by list --format.
+var listFormats = []string{"table", "json", "json-array-compact", "html", "dot"}
 
-		if jsonOutput {
-			return scan.WriteJSON(os.Stdout, listeners)
+func validListFormat(f string) bool {
+	return slices.Contains(listFormats, f)
+}
+
+func renderListeners(listeners []scan.Listener, backends []scan.BackendResult) error {
+	format := listFormat
+	if jsonOutput && format == "table" {
+		format = "json"
+	}
+
+	if listBy != "" {
+		return renderGrouped(listeners, listBy, listCaseSensitive, format == "json")
+	}
+
+	switch format {
+	case "json":
+		if listIgnoreErrors {
+			return writeJSON(os.Stdout, map[string]any{
+				"listeners": listeners,
+				"backends":  backends,
+			})
+		}
+		return writeJSON(os.Stdout, listeners)
+	case "json-array-compact":
+		if listeners == nil {
+			listeners = []scan.Listener{}
 		}
+		return writeJSONOpts(os.Stdout, listeners, scan.JSONOptions{})
+	case "html":
+		return writeListHTML(os.Stdout, listeners, listVerbose, listHTMLDocument)
+	case "dot":
+		return writeListDot(os.Stdout, listeners)
+	}
 
-		if listVerbose {
-			fmt.Fprintf(ui.Stdout(), "%s\n", ui.Header(ui.Stdout(), "PORT\tPID\tUSER\tEXE"))
-			for _, l := range listeners {
-				port := ui.Emphasis(ui.Stdout(), fmt.Sprintf("%d", l.Port))
-				exe := truncatePath(l.CommandLine, 60)
-				if exe == "" {
-					exe = l.Command
-				}
-				fmt.Fprintf(ui.Stdout(), "%s\t%d\t%s\t%s\n", port, l.PID, l.User, exe)
+	if listVerbose {
+		fmt.Fprintf(ui.Stdout(), "%s\n", ui.Header(ui.Stdout(), "PORT\tPID\tUSER\tEXE"))
+		for _, l := range listeners {
+			port := ui.PortClass(ui.Stdout(), portLabel(l), ports.Classify(l.Port))
+			exe := truncatePath(l.CommandLine, 60)
+			if exe == "" {
+				exe = l.Command
 			}
-		} else {
-			fmt.Fprintf(ui.Stdout(), "%s\n", ui.Header(ui.Stdout(), "PORT\tPID\tUSER\tCOMMAND\tADDR"))
-			for _, l := range listeners {
-				port := ui.Emphasis(ui.Stdout(), fmt.Sprintf("%d", l.Port))
-				command := ui.Emphasis(ui.Stdout(), l.Command)
-				fmt.Fprintf(ui.Stdout(), "%s\t%d\t%s\t%s\t%s\n", port, l.PID, l.User, command, l.Address)
+			fmt.Fprintf(ui.Stdout(), "%s\t%d\t%s\t%s%s%s\n", port, l.PID, l.User, exe, connectionsNote(l), backlogNote(l))
+		}
+	} else {
+		fmt.Fprintf(ui.Stdout(), "%s\n", ui.Header(ui.Stdout(), "PORT\tPID\tUSER\tCOMMAND\tADDR"))
+		for _, l := range listeners {
+			port := ui.PortClass(ui.Stdout(), portLabel(l), ports.Classify(l.Port))
+			command := ui.Emphasis(ui.Stdout(), l.Command)
+			addr := l.Address
+			if l.Hostname != "" {
+				addr += " " + ui.Muted(ui.Stdout(), "("+l.Hostname+")")
 			}
+			fmt.Fprintf(ui.Stdout(), "%s\t%d\t%s\t%s\t%s%s%s\n", port, l.PID, l.User, command, addr, connectionsNote(l), backlogNote(l))
 		}
-		return nil
-	},
+	}
+	return nil
 }
 
 var (
-	listPort    int
-	listUnique  bool
-	listVerbose bool
+	listPort         int
+	listUnique       bool
+	listVerbose      bool
+	listWatch        bool
+	listInterval     time.Duration
+	listIgnoreErrors bool
+	listResolve      bool
+	listFormat       string
+	listProto        string
+	listTimeout      time.Duration
+	listSort         string
+	listRange        string
+	listOnChange     bool
+	listDebounce     time.Duration
+
+	listStartedAfter  string
+	listStartedBefore string
+	listRetry         int
+	listHTMLDocument  bool
+	listScope         string
+	listUser          string
+	listOnlyMine      bool
+	listBy            string
+	listEnrich        bool
+	listWarnBacklog   int
+	listConnCount     bool
+
+	listExcludeCommands []string
+	listCaseSensitive   bool
+
+	listExplain, listExplainOnly bool
+
+	// listBacklogCheck is set when accept queues are read and flagged:
+	// with --enrich or an explicit --warn-backlog.
+	listBacklogCheck bool
+
+	listPortLT, listPortGT, listPortLTE, listPortGTE int
 )
 
 func init() {
 	listCmd.Flags().IntVar(&listPort, "port", 0, "Filter by port")
+	listCmd.Flags().IntVar(&listPortLT, "port-lt", 0, "Only ports below this one")
+	listCmd.Flags().IntVar(&listPortGT, "port-gt", 0, "Only ports above this one")
+	listCmd.Flags().IntVar(&listPortLTE, "port-lte", 0, "Only ports at or below this one")
+	listCmd.Flags().IntVar(&listPortGTE, "port-gte", 0, "Only ports at or above this one")
+	listCmd.Flags().StringVar(&listRange, "range", "", "Only show ports in this range, e.g. 3000-3999")
+	listCmd.Flags().StringVar(&listScope, "scope", "", "Only loopback-bound listeners (loopback) or everything else (external)")
+	listCmd.Flags().StringVar(&listUser, "user", "", "Only processes owned by this user")
+	listCmd.Flags().BoolVar(&listOnlyMine, "only-mine", false, "Only your own processes (--user with the current user)")
+	listCmd.Flags().StringArrayVar(&listExcludeCommands, "exclude-command", nil, "Hide listeners whose command, executable or command line contains this (repeatable)")
+	listCmd.Flags().BoolVar(&listCaseSensitive, "case-sensitive", false, "Match the filter, --exclude-command and --by command case-sensitively")
+	listCmd.Flags().StringVar(&listBy, "by", "", "Group listeners by command, user, or exe (executable path)")
+	listCmd.Flags().BoolVar(&listEnrich, "enrich", false, "Add process details (ppid, args, exe, cwd, start time) to every listener")
+	listCmd.Flags().IntVar(&listWarnBacklog, "warn-backlog", 80, "Flag listeners whose accept queue is at least this % full (ss only; on with --enrich)")
+	listCmd.Flags().BoolVar(&listConnCount, "connections", false, "Count each listener's established connections (one extra scan per port)")
 	listCmd.Flags().BoolVar(&listUnique, "unique", false, "Deduplicate by port+PID")
 	listCmd.Flags().BoolVarP(&listVerbose, "verbose", "v", false, "Show executable path")
+	listCmd.Flags().BoolVar(&listWatch, "watch", false, "Refresh the listing until interrupted")
+	listCmd.Flags().DurationVar(&listInterval, "interval", 2*time.Second, "Refresh interval for --watch")
+	listCmd.Flags().BoolVar(&listOnChange, "on-change", false, "With --watch, run the command after -- whenever the listener set changes")
+	listCmd.Flags().DurationVar(&listDebounce, "debounce", time.Second, "With --on-change, wait for the set to be stable this long before firing")
+	listCmd.Flags().StringVar(&listStartedAfter, "started-after", "", "Only processes started after this time (RFC 3339, YYYY-MM-DD HH:MM, HH:MM, or a duration like 2h ago)")
+	listCmd.Flags().StringVar(&listStartedBefore, "started-before", "", "Only processes started before this time (same forms as --started-after)")
+	listCmd.Flags().StringVar(&listSort, "sort", "port", "Sort order: port, or none to keep the backend's discovery order")
+	listCmd.Flags().StringVar(&listProto, "proto", "tcp", "Protocol: "+strings.Join(protoChoices, ", ")+" (UDP lists bound, unconnected sockets)")
+	listCmd.Flags().StringVar(&listFormat, "format", "table", "Output format (table, json, json-array-compact, html, dot)")
+	listCmd.Flags().BoolVar(&listHTMLDocument, "html-document", false, "With --format html, wrap the table in a complete HTML document")
+	listCmd.Flags().BoolVar(&listResolve, "resolve", false, "Reverse-resolve bind addresses to hostnames")
+	listCmd.Flags().BoolVar(&listIgnoreErrors, "ignore-errors", false, "Try every backend and merge results; fail only if all fail")
+	listCmd.Flags().IntVar(&listRetry, "retry", 0, "Re-run a scan that finds no listeners up to N more times")
+	listCmd.Flags().DurationVar(&listTimeout, "timeout", 0, "Give up scanning after this long (0 = no limit); with --ignore-errors, keep what was parsed")
+	listCmd.Flags().BoolVar(&listExplain, "explain", false, "Print the scan command(s) fp will run, then list as usual")
+	listCmd.Flags().BoolVar(&listExplainOnly, "explain-only", false, "Print the scan command(s) fp would run, without scanning")
+	addDumpRawFlag(listCmd)
 }
 
+// backlogSaturated reports whether l's accept queue is at least pct percent
+// full. Listeners without queue data (no ss) never are.
+func backlogSaturated(l scan.Listener, pct int) bool {
+	return l.Backlog != nil && l.Backlog.Max > 0 && l.Backlog.Queued*100 >= pct*l.Backlog.Max
+}
+
+// backlogNote is the table annotation for a saturated accept queue, which
+// means the server isn't accepting connections as fast as they arrive.
+func backlogNote(l scan.Listener) string {
+	if !listBacklogCheck || !backlogSaturated(l, listWarnBacklog) {
+		return ""
+	}
+	return fmt.Sprintf("\t%s backlog %d/%d (%.0f%%)", ui.LabelWarn(ui.Stdout()), l.Backlog.Queued, l.Backlog.Max, l.Backlog.Percent())
+}
+
+// countConnections sets ConnectionCount on each TCP listener, with one
+// connection scan per port. A connection whose owning PID is known counts
+// only for that process's listener, so servers sharing a port through
+// SO_REUSEPORT are told apart.
+func countConnections(ctx context.Context, listeners []scan.Listener) error {
+	byPort := map[int][]scan.Connection{}
+	for i := range listeners {
+		l := &listeners[i]
+		if l.Proto != "" && l.Proto != "tcp" {
+			continue
+		}
+		conns, ok := byPort[l.Port]
+		if !ok {
+			var err error
+			if conns, err = listConnections(ctx, l.Port); err != nil {
+				return err
+			}
+			byPort[l.Port] = conns
+		}
+		n := 0
+		for _, c := range conns {
+			if c.PID == 0 || l.PID == 0 || c.PID == l.PID {
+				n++
+			}
+		}
+		l.ConnectionCount = &n
+	}
+	return nil
+}
+
+// connectionsNote is the table's conns=N column under --connections.
+func connectionsNote(l scan.Listener) string {
+	if l.ConnectionCount == nil {
+		return ""
+	}
+	return fmt.Sprintf("\tconns=%d", *l.ConnectionCount)
+}
+
+// ownedBy keeps the listeners whose process belongs to user. Listeners
+// with no known owner are dropped.
+func ownedBy(listeners []scan.Listener, user string) []scan.Listener {
+	filtered := listeners[:0]
+	for _, l := range listeners {
+		if l.User == user {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered
+}
+
+// portComparisonRange intersects the --port-lt/gt/lte/gte bounds into one
+// range; 0 leaves a bound unset. ok is false when no bound is set.
+func portComparisonRange(lt, gt, lte, gte int) (r ports.Range, ok bool, err error) {
+	r = ports.Range{Start: 1, End: 65535}
+	bounds := []struct {
+		flag  string
+		value int
+		apply func(int)
+	}{
+		{"--port-lt", lt, func(v int) { r.End = min(r.End, v-1) }},
+		{"--port-gt", gt, func(v int) { r.Start = max(r.Start, v+1) }},
+		{"--port-lte", lte, func(v int) { r.End = min(r.End, v) }},
+		{"--port-gte", gte, func(v int) { r.Start = max(r.Start, v) }},
+	}
+	for _, b := range bounds {
+		if b.value == 0 {
+			continue
+		}
+		if b.value < 1 || b.value > 65535 {
+			return ports.Range{}, false, fmt.Errorf("invalid %s %d (must be 1-65535)", b.flag, b.value)
+		}
+		b.apply(b.value)
+		ok = true
+	}
+	if ok && r.Start > r.End {
+		return ports.Range{}, false, fmt.Errorf("port filters exclude every port (need %d <= port <= %d)", r.Start, r.End)
+	}
+	return r, ok, nil
+}
+
+// resolveTimeout bounds the whole --resolve pass.
+const resolveTimeout = 2 * time.Second
+
 func truncatePath(cmdLine string, maxLen int) string {
 	if cmdLine == "" {
 		return ""
@@ -141,16 +577,24 @@ func truncatePath(cmdLine string, maxLen int) string {
 	return exe
 }
 
-func matchesFilter(l scan.Listener, filter string) bool {
-	// Match against command name, executable, or command line
-	if strings.Contains(strings.ToLower(l.Command), filter) {
-		return true
-	}
-	if strings.Contains(strings.ToLower(l.Executable), filter) {
-		return true
+// foldCommand normalizes a command name, executable path or command line
+// for filtering and grouping, so every place that compares commands agrees
+// on case. By default Python and python are the same program;
+// caseSensitive, from a command's --case-sensitive, keeps them apart.
+func foldCommand(s string, caseSensitive bool) string {
+	if caseSensitive {
+		return s
 	}
-	if strings.Contains(strings.ToLower(l.CommandLine), filter) {
-		return true
+	return strings.ToLower(s)
+}
+
+func matchesFilter(l scan.Listener, filter string, caseSensitive bool) bool {
+	filter = foldCommand(filter, caseSensitive)
+	// Match against command name, executable, or command line
+	for _, have := range []string{l.Command, l.Executable, l.CommandLine} {
+		if strings.Contains(foldCommand(have, caseSensitive), filter) {
+			return true
+		}
 	}
 	return false
 }
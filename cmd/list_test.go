@@ -0,0 +1,522 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/muesli/termenv"
+)
+
+func TestCompactJSONArrayIsSingleLine(t *testing.T) {
+	listeners := []scan.Listener{
+		{Port: 3000, PID: 10, Command: "node", Proto: "tcp", Address: "*:3000"},
+		{Port: 5432, PID: 20, Command: "postgres", Proto: "tcp", Address: "127.0.0.1:5432"},
+	}
+
+	var compact, pretty bytes.Buffer
+	if err := scan.WriteJSONOpts(&compact, listeners, scan.JSONOptions{}); err != nil {
+		t.Fatalf("WriteJSONOpts: %v", err)
+	}
+	if err := scan.WriteJSON(&pretty, listeners); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+
+	out := compact.Bytes()
+	if bytes.ContainsAny(out, "\n\t") || bytes.Contains(out, []byte("  ")) {
+		t.Fatalf("expected single-line compact output, got %q", out)
+	}
+	if out[0] != '[' || out[len(out)-1] != ']' {
+		t.Fatalf("expected a bare JSON array with no trailing newline, got %q", out)
+	}
+	if compact.Len() >= pretty.Len() {
+		t.Fatalf("expected compact (%d bytes) to be smaller than pretty (%d bytes)", compact.Len(), pretty.Len())
+	}
+
+	var decoded []scan.Listener
+	if err := json.Unmarshal(out, &decoded); err != nil || len(decoded) != 2 {
+		t.Fatalf("expected valid array of 2, got %v (err=%v)", decoded, err)
+	}
+}
+
+func TestListSortNonePreservesDiscoveryOrder(t *testing.T) {
+	emitted := []scan.Listener{
+		{Port: 8080, PID: 30, Command: "go", Proto: "tcp"},
+		{Port: 22, PID: 1, Command: "sshd", Proto: "tcp"},
+		{Port: 3000, PID: 10, Command: "node", Proto: "tcp"},
+	}
+	stubListeners(t, func() []scan.Listener { return append([]scan.Listener(nil), emitted...) })
+
+	orig := listSort
+	t.Cleanup(func() { listSort = orig })
+
+	listSort = "none"
+	got, _, err := collectListeners(context.Background(), "", 0)
+	if err != nil {
+		t.Fatalf("collectListeners: %v", err)
+	}
+	for i := range emitted {
+		if got[i].Port != emitted[i].Port {
+			t.Fatalf("expected discovery order %v, got %v", emitted, got)
+		}
+	}
+
+	listSort = "port"
+	got, _, err = collectListeners(context.Background(), "", 0)
+	if err != nil {
+		t.Fatalf("collectListeners: %v", err)
+	}
+	if got[0].Port != 22 || got[1].Port != 3000 || got[2].Port != 8080 {
+		t.Fatalf("expected port order, got %v", got)
+	}
+}
+
+func TestListRangeFilter(t *testing.T) {
+	stubListeners(t, func() []scan.Listener {
+		return []scan.Listener{
+			{Port: 22, PID: 1, Command: "sshd"},
+			{Port: 3000, PID: 10, Command: "node"},
+			{Port: 3999, PID: 11, Command: "vite"},
+			{Port: 4000, PID: 12, Command: "go"},
+		}
+	})
+	orig := listRange
+	t.Cleanup(func() { listRange = orig })
+
+	listRange = "3000-3999"
+	got, _, err := collectListeners(context.Background(), "", 0)
+	if err != nil {
+		t.Fatalf("collectListeners: %v", err)
+	}
+	if len(got) != 2 || got[0].Port != 3000 || got[1].Port != 3999 {
+		t.Fatalf("expected ports 3000 and 3999, got %+v", got)
+	}
+
+	listRange = "3999-3000"
+	if _, _, err := collectListeners(context.Background(), "", 0); err == nil {
+		t.Fatalf("expected an invalid range to be rejected")
+	}
+}
+
+func TestScanListenersRetriesEmptyScan(t *testing.T) {
+	calls := 0
+	orig := listTCPListeners
+	listTCPListeners = func(context.Context) ([]scan.Listener, error) {
+		calls++
+		if calls == 1 {
+			return nil, nil
+		}
+		return []scan.Listener{{Port: 22, PID: 1, Command: "sshd"}}, nil
+	}
+	origRetry, origDelay := listRetry, listRetryDelay
+	listRetry, listRetryDelay = 2, 0
+	t.Cleanup(func() {
+		listTCPListeners = orig
+		listRetry, listRetryDelay = origRetry, origDelay
+	})
+
+	listeners, _, err := scanListeners(context.Background())
+	if err != nil {
+		t.Fatalf("scanListeners: %v", err)
+	}
+	if len(listeners) != 1 || calls != 2 {
+		t.Fatalf("expected the second attempt's listener after 2 calls, got %v after %d", listeners, calls)
+	}
+
+	calls = 0
+	listTCPListeners = func(context.Context) ([]scan.Listener, error) {
+		calls++
+		return nil, nil
+	}
+	if listeners, _, err := scanListeners(context.Background()); err != nil || len(listeners) != 0 || calls != 1+listRetry {
+		t.Fatalf("expected 1+%d attempts then an empty result, got %v (err=%v) after %d", listRetry, listeners, err, calls)
+	}
+}
+
+func TestListHTMLEscapesAndIsWellFormed(t *testing.T) {
+	listeners := []scan.Listener{
+		{Port: 3000, PID: 10, User: "dev", Command: "a<b&c", CommandLine: `node -e "x<y && y>z"`, Address: "127.0.0.1:3000"},
+	}
+
+	for _, tc := range []struct {
+		verbose, document bool
+		want              string
+	}{
+		{false, false, "a&lt;b&amp;c"},
+		{true, true, "node -e &#34;x&lt;y &amp;&amp; y&gt;z&#34;"},
+	} {
+		var buf bytes.Buffer
+		if err := writeListHTML(&buf, listeners, tc.verbose, tc.document); err != nil {
+			t.Fatalf("writeListHTML: %v", err)
+		}
+		out := buf.String()
+		if !strings.Contains(out, tc.want) {
+			t.Fatalf("expected escaped %q in:\n%s", tc.want, out)
+		}
+		if strings.Contains(out, "a<b") || strings.Contains(out, "x<y") {
+			t.Fatalf("unescaped command in:\n%s", out)
+		}
+		if strings.HasPrefix(out, "<!DOCTYPE") != tc.document {
+			t.Fatalf("document=%v but got:\n%s", tc.document, out)
+		}
+
+		// Balanced tags and valid entities: the markup parses as XML once
+		// the doctype is dropped.
+		body := strings.TrimPrefix(out, "<!DOCTYPE html>\n")
+		body = strings.Replace(body, `<meta charset="utf-8">`, `<meta charset="utf-8"/>`, 1)
+		dec := xml.NewDecoder(strings.NewReader(body))
+		for {
+			if _, err := dec.Token(); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				t.Fatalf("malformed HTML: %v\n%s", err, out)
+			}
+		}
+	}
+}
+
+func TestListDotIsValidGraph(t *testing.T) {
+	listeners := []scan.Listener{
+		{Port: 3000, PID: 20, PPID: 10, User: "dev", Command: `we"ird\name`, Address: "127.0.0.1:3000"},
+		{Port: 3001, PID: 20, PPID: 10, Command: `we"ird\name`, Address: "[::1]:3001"},
+		{Port: 5432, PID: 30, PPID: 1, Command: "postgres", Address: "0.0.0.0:5432"},
+		{Port: 9000, Address: "*:9000"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeListDot(&buf, listeners); err != nil {
+		t.Fatalf("writeListDot: %v", err)
+	}
+	out := buf.String()
+
+	// Every statement is a node or edge whose IDs and labels are well-formed
+	// DOT strings: no bare quotes, escapes only for \\, \" and \n.
+	quoted := `"(?:[^"\\\n]|\\[\\"n])*"`
+	attrs := `\[[a-z]+=(?:` + quoted + `|[a-z]+)(?:, [a-z]+=(?:` + quoted + `|[a-z]+))*\]`
+	stmt := regexp.MustCompile(`^\t(?:rankdir=LR|node ` + attrs + `|` + quoted + ` ` + attrs + `|` + quoted + ` -> ` + quoted + ` ` + attrs + `);$`)
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if lines[0] != "digraph fp {" || lines[len(lines)-1] != "}" {
+		t.Fatalf("expected a digraph block, got:\n%s", out)
+	}
+	for _, line := range lines[1 : len(lines)-1] {
+		if !stmt.MatchString(line) {
+			t.Fatalf("invalid DOT statement %q in:\n%s", line, out)
+		}
+	}
+
+	for _, want := range []string{
+		`"pid:20" [shape=box, label="we\"ird\\name\npid 20\ndev"];`,
+		`"pid:10" [shape=box, style=dashed, label="pid 10"];`,
+		`"port:9000" [shape=ellipse, label="9000\n*:9000"];`,
+		`"pid:20" -> "port:3001" [label="listens on"];`,
+		`"pid:10" -> "pid:20" [label="parent of", style=dashed];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %s in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `"pid:1"`) {
+		t.Fatalf("init should not be drawn as a parent:\n%s", out)
+	}
+}
+
+func TestPortComparisonRange(t *testing.T) {
+	cases := []struct {
+		lt, gt, lte, gte int
+		want             ports.Range
+	}{
+		{lt: 1024, want: ports.Range{Start: 1, End: 1023}},
+		{gt: 8000, want: ports.Range{Start: 8001, End: 65535}},
+		{lte: 1024, want: ports.Range{Start: 1, End: 1024}},
+		{gte: 8000, want: ports.Range{Start: 8000, End: 65535}},
+		{gte: 3000, lt: 4000, want: ports.Range{Start: 3000, End: 3999}},
+		{gt: 3000, lte: 4000, gte: 3500, lt: 3600, want: ports.Range{Start: 3500, End: 3599}},
+		{gt: 3000, lt: 3002, want: ports.Range{Start: 3001, End: 3001}},
+	}
+	for _, tc := range cases {
+		got, ok, err := portComparisonRange(tc.lt, tc.gt, tc.lte, tc.gte)
+		if err != nil || !ok || got != tc.want {
+			t.Errorf("lt=%d gt=%d lte=%d gte=%d: got %v ok=%v err=%v, want %v", tc.lt, tc.gt, tc.lte, tc.gte, got, ok, err, tc.want)
+		}
+	}
+
+	if _, ok, err := portComparisonRange(0, 0, 0, 0); ok || err != nil {
+		t.Fatalf("expected no filter when unset, got ok=%v err=%v", ok, err)
+	}
+	for _, bad := range [][4]int{{1, 0, 0, 0}, {0, 65535, 0, 0}, {0, 0, 70000, 0}, {0, 3000, 3000, 0}, {0, 0, 0, -1}} {
+		if _, _, err := portComparisonRange(bad[0], bad[1], bad[2], bad[3]); err == nil {
+			t.Errorf("expected error for lt/gt/lte/gte %v", bad)
+		}
+	}
+}
+
+func TestListPortComparisonFilters(t *testing.T) {
+	stubListeners(t, func() []scan.Listener {
+		return []scan.Listener{{Port: 22, PID: 1}, {Port: 80, PID: 2}, {Port: 3000, PID: 3}, {Port: 8080, PID: 4}}
+	})
+	origLT, origGTE := listPortLT, listPortGTE
+	t.Cleanup(func() { listPortLT, listPortGTE = origLT, origGTE })
+
+	listPortLT, listPortGTE = 1024, 0
+	listeners, _, err := collectListeners(context.Background(), "", 0)
+	if err != nil || len(listeners) != 2 || listeners[0].Port != 22 || listeners[1].Port != 80 {
+		t.Fatalf("--port-lt 1024: got %+v (err=%v)", listeners, err)
+	}
+
+	listPortLT, listPortGTE = 8081, 80
+	listeners, _, err = collectListeners(context.Background(), "", 0)
+	if err != nil || len(listeners) != 3 || listeners[0].Port != 80 || listeners[2].Port != 8080 {
+		t.Fatalf("--port-gte 80 --port-lt 8081: got %+v (err=%v)", listeners, err)
+	}
+}
+
+func TestListOnlyMineKeepsCurrentUser(t *testing.T) {
+	me := currentUsername()
+	if me == "" {
+		t.Skip("current user unknown")
+	}
+	stubListeners(t, func() []scan.Listener {
+		return []scan.Listener{
+			{Port: 3000, PID: 1, User: me},
+			{Port: 5432, PID: 2, User: "postgres-" + me},
+			{Port: 8080, PID: 3, User: me},
+		}
+	})
+	origMine, origUser := listOnlyMine, listUser
+	t.Cleanup(func() { listOnlyMine, listUser = origMine, origUser })
+
+	listOnlyMine, listUser = true, ""
+	listeners, _, err := collectListeners(context.Background(), "", 0)
+	if err != nil {
+		t.Fatalf("collectListeners: %v", err)
+	}
+	if len(listeners) != 2 || listeners[0].Port != 3000 || listeners[1].Port != 8080 {
+		t.Fatalf("expected only %s's listeners, got %+v", me, listeners)
+	}
+
+	listOnlyMine, listUser = false, "postgres-"+me
+	listeners, _, err = collectListeners(context.Background(), "", 0)
+	if err != nil || len(listeners) != 1 || listeners[0].Port != 5432 {
+		t.Fatalf("--user: got %+v (err=%v)", listeners, err)
+	}
+}
+
+func TestGroupListenersByExe(t *testing.T) {
+	listeners := []scan.Listener{
+		{Port: 3000, PID: 1, Command: "node", Executable: "/usr/bin/node"},
+		{Port: 3001, PID: 2, Command: "node", Executable: "/home/dev/.nvm/versions/node/v22/bin/node"},
+		{Port: 3002, PID: 3, Command: "node"},
+		{Port: 3003, PID: 4, Command: "node", Executable: "/usr/bin/node"},
+	}
+
+	keys, groups := groupListeners(listeners, "exe", false)
+	wantKeys := []string{"/home/dev/.nvm/versions/node/v22/bin/node", "/usr/bin/node", unknownGroup}
+	if !slices.Equal(keys, wantKeys) {
+		t.Fatalf("expected groups %v, got %v", wantKeys, keys)
+	}
+	if g := groups["/usr/bin/node"]; len(g) != 2 || g[0].Port != 3000 || g[1].Port != 3003 {
+		t.Fatalf("unexpected /usr/bin/node group %+v", g)
+	}
+	if g := groups[unknownGroup]; len(g) != 1 || g[0].PID != 3 {
+		t.Fatalf("expected the exe-less listener under %s, got %+v", unknownGroup, g)
+	}
+
+	if keys, _ := groupListeners(listeners, "command", false); !slices.Equal(keys, []string{"node"}) {
+		t.Fatalf("expected one command group, got %v", keys)
+	}
+}
+
+func TestCommandCaseFolding(t *testing.T) {
+	listeners := []scan.Listener{
+		{Port: 8000, PID: 1, Command: "Python"},
+		{Port: 8001, PID: 2, Command: "python"},
+		{Port: 3000, PID: 3, Command: "node"},
+	}
+
+	keys, groups := groupListeners(listeners, "command", false)
+	if !slices.Equal(keys, []string{"node", "python"}) || len(groups["python"]) != 2 {
+		t.Fatalf("expected Python and python grouped together, got %v", keys)
+	}
+	if !matchesFilter(listeners[0], "PYTHON", false) || !matchesFilter(listeners[1], "Python", false) {
+		t.Fatalf("expected filters to ignore case by default")
+	}
+
+	keys, _ = groupListeners(listeners, "command", true)
+	if !slices.Equal(keys, []string{"Python", "node", "python"}) {
+		t.Fatalf("expected separate groups with --case-sensitive, got %v", keys)
+	}
+	if matchesFilter(listeners[1], "Python", true) || !matchesFilter(listeners[0], "Python", true) {
+		t.Fatalf("expected --case-sensitive filters to match exact case only")
+	}
+}
+
+func TestListExcludeCommand(t *testing.T) {
+	stubListeners(t, func() []scan.Listener {
+		return []scan.Listener{
+			{Port: 3000, PID: 1, Command: "node", Proto: "tcp"},
+			{Port: 8000, PID: 2, Command: "Python", Proto: "tcp"},
+		}
+	})
+	listExcludeCommands = []string{"python"}
+	t.Cleanup(func() { listExcludeCommands = nil })
+
+	listeners, _, err := collectListeners(context.Background(), "", 0)
+	if err != nil {
+		t.Fatalf("collectListeners: %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].Command != "node" {
+		t.Fatalf("expected only node left, got %+v", listeners)
+	}
+}
+
+func TestListProtoSelectsScans(t *testing.T) {
+	stubListeners(t, func() []scan.Listener {
+		return []scan.Listener{{Port: 3000, PID: 1, User: "dev", Proto: "tcp"}}
+	})
+	origUDP, origProto := listUDPListeners, listProto
+	t.Cleanup(func() { listUDPListeners, listProto = origUDP, origProto })
+	listUDPListeners = func(context.Context) ([]scan.Listener, error) {
+		return []scan.Listener{{Port: 53, PID: 2, User: "dev", Proto: "udp"}}, nil
+	}
+
+	for proto, want := range map[string][]string{
+		"tcp": {"3000"},
+		"udp": {"53/udp"},
+		"all": {"53/udp", "3000"},
+	} {
+		listProto = proto
+		listeners, _, err := collectListeners(context.Background(), "", 0)
+		if err != nil {
+			t.Fatalf("--proto %s: %v", proto, err)
+		}
+		var got []string
+		for _, l := range listeners {
+			got = append(got, portLabel(l))
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("--proto %s: got %v, want %v", proto, got, want)
+		}
+	}
+	if err := validateProto("sctp"); err == nil {
+		t.Fatalf("expected sctp to be rejected")
+	}
+}
+
+func TestBacklogSaturatedAtThreshold(t *testing.T) {
+	cases := []struct {
+		backlog *scan.Backlog
+		pct     int
+		want    bool
+	}{
+		{&scan.Backlog{Queued: 8, Max: 10}, 80, true}, // exactly at the threshold
+		{&scan.Backlog{Queued: 7, Max: 10}, 80, false},
+		{&scan.Backlog{Queued: 8, Max: 10}, 81, false},
+		{&scan.Backlog{Queued: 5, Max: 4}, 100, true}, // Linux admits max+1
+		{&scan.Backlog{Queued: 0, Max: 0}, 1, false},
+		{nil, 1, false}, // no ss data
+	}
+	for _, tc := range cases {
+		l := scan.Listener{Port: 3000, Backlog: tc.backlog}
+		if got := backlogSaturated(l, tc.pct); got != tc.want {
+			t.Fatalf("backlogSaturated(%+v, %d) = %v, want %v", tc.backlog, tc.pct, got, tc.want)
+		}
+	}
+}
+
+func TestCountConnectionsPerListener(t *testing.T) {
+	orig := listConnections
+	t.Cleanup(func() { listConnections = orig })
+	scans := map[int]int{}
+	listConnections = func(_ context.Context, port int) ([]scan.Connection, error) {
+		scans[port]++
+		switch port {
+		case 3000:
+			return []scan.Connection{
+				{Local: "127.0.0.1:3000", Remote: "127.0.0.1:50001", PID: 10},
+				{Local: "127.0.0.1:3000", Remote: "127.0.0.1:50002", PID: 10},
+				{Local: "127.0.0.1:3000", Remote: "127.0.0.1:50003", PID: 11},
+			}, nil
+		case 8080:
+			return []scan.Connection{{Local: "[::1]:8080", Remote: "[::1]:40000"}}, nil
+		}
+		return nil, nil
+	}
+
+	listeners := []scan.Listener{
+		{Port: 3000, PID: 10, Proto: "tcp"},
+		{Port: 3000, PID: 11, Proto: "tcp"}, // SO_REUSEPORT sibling
+		{Port: 8080, PID: 20, Proto: "tcp"}, // connection PID unknown
+		{Port: 9000, PID: 30, Proto: "tcp"},
+		{Port: 53, PID: 40, Proto: "udp"},
+	}
+	if err := countConnections(context.Background(), listeners); err != nil {
+		t.Fatalf("countConnections: %v", err)
+	}
+	for i, want := range []int{2, 1, 1, 0} {
+		if got := listeners[i].ConnectionCount; got == nil || *got != want {
+			t.Fatalf("listener %d (port %d): got %v, want %d", i, listeners[i].Port, got, want)
+		}
+	}
+	if listeners[4].ConnectionCount != nil {
+		t.Fatalf("udp listener shouldn't be counted, got %d", *listeners[4].ConnectionCount)
+	}
+	if scans[3000] != 1 || scans[53] != 0 {
+		t.Fatalf("expected one scan per TCP port, got %v", scans)
+	}
+	if got := connectionsNote(listeners[0]); got != "\tconns=2" {
+		t.Fatalf("connectionsNote = %q", got)
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(listeners[3]); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"connection_count":0`) {
+		t.Fatalf("expected connection_count in JSON, got %s", buf.String())
+	}
+}
+
+func TestWriteScanExplanation(t *testing.T) {
+	orig := explainScan
+	t.Cleanup(func() { explainScan = orig })
+	explainScan = func(udp bool) ([]scan.ScanPlan, error) {
+		if udp {
+			return []scan.ScanPlan{{Backend: "ss", Command: []string{"ss", "-lunpH"}}}, nil
+		}
+		return []scan.ScanPlan{
+			{Backend: "lsof", Command: []string{"lsof", "-nP", "-iTCP", "-sTCP:LISTEN"}},
+			{Backend: "ss", Command: []string{"ss", "-ltnpH"}},
+		}, nil
+	}
+
+	cases := []struct {
+		proto        string
+		ignoreErrors bool
+		want         string
+	}{
+		{"tcp", false, "INFO would run: lsof -nP -iTCP -sTCP:LISTEN\nINFO   if that finds nothing: ss -ltnpH\n"},
+		{"tcp", true, "INFO would run: lsof -nP -iTCP -sTCP:LISTEN\nINFO would also run: ss -ltnpH\n"},
+		{"udp", true, "INFO would run: ss -lunpH\n"},
+	}
+	for _, tc := range cases {
+		e, err := explainListScan(tc.proto, tc.ignoreErrors)
+		if err != nil {
+			t.Fatalf("explainListScan(%s): %v", tc.proto, err)
+		}
+		var buf bytes.Buffer
+		writeScanExplanation(termenv.NewOutput(&buf, termenv.WithProfile(termenv.Ascii)), e)
+		if buf.String() != tc.want {
+			t.Fatalf("proto %s, ignore-errors %v: got %q, want %q", tc.proto, tc.ignoreErrors, buf.String(), tc.want)
+		}
+	}
+}
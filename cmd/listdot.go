@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+// writeListDot renders list --format dot: a Graphviz digraph with a box per
+// process, an ellipse per port, "listens on" edges from process to port and
+// dashed "parent of" edges from each parent process (by PPID, so it needs
+// enrichment) to its child. Parents that aren't listening themselves appear
+// as bare PIDs; init (PID 1) is left out, since every daemon would hang off
+// it. Pipe it to `dot -Tpng`.
+func writeListDot(w io.Writer, listeners []scan.Listener) error {
+	procs := map[int]scan.Listener{}
+	addrs := map[int][]string{}
+	edges := map[[2]int]bool{}
+	for _, l := range listeners {
+		if _, ok := addrs[l.Port]; !ok {
+			addrs[l.Port] = nil
+		}
+		if l.Address != "" && !slices.Contains(addrs[l.Port], l.Address) {
+			addrs[l.Port] = append(addrs[l.Port], l.Address)
+		}
+		if l.PID <= 0 {
+			continue
+		}
+		if _, ok := procs[l.PID]; !ok {
+			procs[l.PID] = l
+		}
+		edges[[2]int{l.PID, l.Port}] = true
+	}
+
+	parents := map[int]bool{}
+	for _, p := range procs {
+		if p.PPID > 1 {
+			parents[p.PPID] = true
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph fp {\n\trankdir=LR;\n\tnode [fontname=\"monospace\"];\n")
+	for _, pid := range slices.Sorted(maps.Keys(procs)) {
+		p := procs[pid]
+		label := fmt.Sprintf("%s\npid %d", p.Command, pid)
+		if p.User != "" {
+			label += "\n" + p.User
+		}
+		fmt.Fprintf(&b, "\t%s [shape=box, label=%s];\n", dotQuote("pid:"+strconv.Itoa(pid)), dotQuote(label))
+	}
+	for _, pid := range slices.Sorted(maps.Keys(parents)) {
+		if _, ok := procs[pid]; !ok {
+			fmt.Fprintf(&b, "\t%s [shape=box, style=dashed, label=%s];\n", dotQuote("pid:"+strconv.Itoa(pid)), dotQuote(fmt.Sprintf("pid %d", pid)))
+		}
+	}
+	for _, port := range slices.Sorted(maps.Keys(addrs)) {
+		label := strconv.Itoa(port)
+		if len(addrs[port]) > 0 {
+			label += "\n" + strings.Join(addrs[port], "\n")
+		}
+		fmt.Fprintf(&b, "\t%s [shape=ellipse, label=%s];\n", dotQuote("port:"+strconv.Itoa(port)), dotQuote(label))
+	}
+	for _, pid := range slices.Sorted(maps.Keys(procs)) {
+		for _, port := range slices.Sorted(maps.Keys(addrs)) {
+			if edges[[2]int{pid, port}] {
+				fmt.Fprintf(&b, "\t%s -> %s [label=\"listens on\"];\n", dotQuote("pid:"+strconv.Itoa(pid)), dotQuote("port:"+strconv.Itoa(port)))
+			}
+		}
+	}
+	for _, pid := range slices.Sorted(maps.Keys(procs)) {
+		if ppid := procs[pid].PPID; parents[ppid] {
+			fmt.Fprintf(&b, "\t%s -> %s [label=\"parent of\", style=dashed];\n", dotQuote("pid:"+strconv.Itoa(ppid)), dotQuote("pid:"+strconv.Itoa(pid)))
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote returns s as a DOT double-quoted string. Newlines become \n,
+// which Graphviz renders as centered line breaks.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "")
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listenBind     string
+	listenDuration time.Duration
+)
+
+var listenCmd = &cobra.Command{
+	Use:   "listen <port>",
+	Short: "Open a test TCP listener on a port",
+	Long: `Open a test TCP listener on a port until --duration elapses or fp is
+interrupted. Connections are accepted and closed immediately.
+
+--bind picks the address: 127.0.0.1 (default), 0.0.0.0 for an externally
+reachable listener, or ::1 / [::1] for IPv6. Port 0 lets the OS choose.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		port := 0
+		if args[0] != "0" {
+			var err error
+			if port, err = parsePortArg(args[0]); err != nil {
+				return err
+			}
+		}
+		addr, err := listenAddress(listenBind, port)
+		if err != nil {
+			return err
+		}
+
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer ln.Close()
+		go acceptAndClose(ln)
+
+		bound := ln.Addr().(*net.TCPAddr)
+		if jsonOutput {
+			if err := writeJSON(os.Stdout, map[string]any{
+				"address": bound.String(),
+				"port":    bound.Port,
+				"pid":     os.Getpid(),
+			}); err != nil {
+				return err
+			}
+		} else {
+			fmt.Fprintf(ui.Stderr(), "%s listening on %s (pid %d)\n", ui.Brand(ui.Stderr(), "fp:"), bound, os.Getpid())
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if listenDuration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, listenDuration)
+			defer cancel()
+		}
+		<-ctx.Done()
+		return nil
+	},
+}
+
+// listenAddress validates bind as an IP literal, with or without IPv6
+// brackets, and joins it with port.
+func listenAddress(bind string, port int) (string, error) {
+	host := bind
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("invalid --bind %q (expected an IP address such as 127.0.0.1, 0.0.0.0 or ::1)", bind)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+func acceptAndClose(ln net.Listener) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_ = c.Close()
+	}
+}
+
+func init() {
+	listenCmd.Flags().StringVar(&listenBind, "bind", "127.0.0.1", "Address to bind (IPv4 or IPv6 literal)")
+	listenCmd.Flags().DurationVar(&listenDuration, "duration", 0, "Close the listener after this long (0 = until interrupted)")
+	rootCmd.AddCommand(listenCmd)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+func TestListenAddress(t *testing.T) {
+	cases := []struct {
+		bind string
+		want string
+	}{
+		{"127.0.0.1", "127.0.0.1:8080"},
+		{"0.0.0.0", "0.0.0.0:8080"},
+		{"::1", "[::1]:8080"},
+		{"[::1]", "[::1]:8080"},
+	}
+	for _, tc := range cases {
+		got, err := listenAddress(tc.bind, 8080)
+		if err != nil || got != tc.want {
+			t.Errorf("listenAddress(%q) = %q, %v; want %q", tc.bind, got, err, tc.want)
+		}
+	}
+	for _, bad := range []string{"", "localhost", "1.2.3", "[::1"} {
+		if _, err := listenAddress(bad, 8080); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestListenIPv6VisibleToScan(t *testing.T) {
+	addr, err := listenAddress("[::1]", 0)
+	if err != nil {
+		t.Fatalf("listenAddress: %v", err)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	listeners, err := scan.ListTCPListeners(context.Background())
+	if err != nil {
+		t.Skipf("no scan backend: %v", err)
+	}
+	for _, l := range listeners {
+		if l.Port == port {
+			if l.Address != "[::1]:"+itoa(port) {
+				t.Fatalf("expected IPv6 address, got %q", l.Address)
+			}
+			return
+		}
+	}
+	t.Fatalf("listener on [::1]:%d not found in scan", port)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
+)
+
+// listGroupKeys are the values accepted by list --by; "" means no grouping.
+var listGroupKeys = []string{"", "command", "user", "exe"}
+
+// unknownGroup collects listeners with no value for the --by key.
+const unknownGroup = "(unknown)"
+
+// groupListeners buckets listeners by the --by key, keeping each group in
+// list order. Keys are sorted, with unknownGroup last. Commands are folded
+// like filters are, so the group is named by its lowercased command unless
+// caseSensitive is set.
+func groupListeners(listeners []scan.Listener, by string, caseSensitive bool) ([]string, map[string][]scan.Listener) {
+	groups := make(map[string][]scan.Listener)
+	for _, l := range listeners {
+		var key string
+		switch by {
+		case "command":
+			key = foldCommand(l.Command, caseSensitive)
+		case "user":
+			key = l.User
+		case "exe":
+			key = l.Executable
+		}
+		if key == "" {
+			key = unknownGroup
+		}
+		groups[key] = append(groups[key], l)
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		if k != unknownGroup {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	if _, ok := groups[unknownGroup]; ok {
+		keys = append(keys, unknownGroup)
+	}
+	return keys, groups
+}
+
+// renderGrouped prints list --by output: a heading per group, or in JSON an
+// object mapping each group key to its listeners.
+func renderGrouped(listeners []scan.Listener, by string, caseSensitive, asJSON bool) error {
+	keys, groups := groupListeners(listeners, by, caseSensitive)
+	if asJSON {
+		return writeJSON(os.Stdout, groups)
+	}
+	out := ui.Stdout()
+	for i, k := range keys {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%s %s\n", ui.Header(out, k), ui.Muted(out, fmt.Sprintf("(%d)", len(groups[k]))))
+		for _, l := range groups[k] {
+			fmt.Fprintf(out, "  %s\t%d\t%s\t%s\t%s\n", ui.PortClass(out, portLabel(l), ports.Classify(l.Port)), l.PID, l.User, l.Command, l.Address)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"html/template"
+	"io"
+	"strconv"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+// listHTML renders list --format html: a self-contained table fragment with
+// inline styles, so it survives being pasted into a wiki or email. Values
+// are escaped by html/template.
+var listHTML = template.Must(template.New("list").Parse(`{{if .Document}}<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>fp list</title></head>
+<body>
+{{end}}<table style="border-collapse:collapse;font-family:monospace">
+<thead><tr>{{range .Columns}}<th style="border:1px solid #ccc;padding:2px 6px;text-align:left">{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td style="border:1px solid #ccc;padding:2px 6px">{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{if .Document}}</body>
+</html>
+{{end}}`))
+
+// writeListHTML uses the same columns as the table output; with verbose the
+// full command line is shown, since there's no terminal width to fit.
+func writeListHTML(w io.Writer, listeners []scan.Listener, verbose, document bool) error {
+	data := struct {
+		Document bool
+		Columns  []string
+		Rows     [][]string
+	}{Document: document}
+
+	if verbose {
+		data.Columns = []string{"PORT", "PID", "USER", "EXE"}
+	} else {
+		data.Columns = []string{"PORT", "PID", "USER", "COMMAND", "ADDR"}
+	}
+	for _, l := range listeners {
+		row := []string{strconv.Itoa(l.Port), strconv.Itoa(l.PID), l.User}
+		if verbose {
+			exe := l.CommandLine
+			if exe == "" {
+				exe = l.Command
+			}
+			row = append(row, exe)
+		} else {
+			addr := l.Address
+			if l.Hostname != "" {
+				addr += " (" + l.Hostname + ")"
+			}
+			row = append(row, l.Command, addr)
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	return listHTML.Execute(w, data)
+}
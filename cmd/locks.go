@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/lock"
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var locksGC bool
+
+var locksCmd = &cobra.Command{
+	Use:   "locks",
+	Short: "List fp port locks and reservations",
+	Long: `List fp port locks and reservations.
+
+STATUS is "held" for a live lock, "expired" for a reservation held past its
+TTL, and "stale" for a lock file nobody holds. With --gc, stale files are
+removed and holders of expired reservations are asked to exit (SIGTERM).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := lock.List()
+		if err != nil {
+			return err
+		}
+		if locksGC {
+			entries, err = lock.Sweep()
+			if err != nil {
+				return err
+			}
+		}
+
+		if jsonOutput {
+			views := make([]lockView, 0, len(entries))
+			for _, e := range entries {
+				views = append(views, newLockView(e))
+			}
+			return writeJSON(os.Stdout, views)
+		}
+
+		out := ui.Stdout()
+		if locksGC {
+			if len(entries) == 0 {
+				fmt.Fprintf(out, "%s nothing to clean up\n", ui.LabelOK(out))
+			}
+			for _, e := range entries {
+				fmt.Fprintf(out, "%s released port %d (%s)\n", ui.LabelInfo(out), e.Port, e.Status())
+			}
+			return nil
+		}
+
+		fmt.Fprintf(out, "%s\n", ui.Header(out, "PORT\tSTATUS\tPID\tEXPIRES\tLABELS"))
+		for _, e := range entries {
+			status := e.Status()
+			switch status {
+			case "held":
+				status = ui.Success(out, status)
+			case "expired":
+				status = ui.Warning(out, status)
+			default:
+				status = ui.Muted(out, status)
+			}
+			expires := "-"
+			if !e.Info.Expires.IsZero() {
+				expires = e.Info.Expires.Local().Format(time.DateTime)
+			}
+			labels := "-"
+			if len(e.Info.Labels) > 0 {
+				labels = strings.Join(e.Info.Labels, ",")
+			}
+			fmt.Fprintf(out, "%s\t%s\t%d\t%s\t%s\n", ui.Emphasis(out, fmt.Sprintf("%d", e.Port)), status, e.Info.PID, expires, labels)
+		}
+		return nil
+	},
+}
+
+type lockView struct {
+	Port    int        `json:"port"`
+	Status  string     `json:"status"`
+	PID     int        `json:"pid,omitempty"`
+	Created *time.Time `json:"created,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+	Labels  []string   `json:"labels,omitempty"`
+}
+
+func newLockView(e lock.Entry) lockView {
+	v := lockView{Port: e.Port, Status: e.Status(), PID: e.Info.PID, Labels: e.Info.Labels}
+	if !e.Info.Created.IsZero() {
+		v.Created = &e.Info.Created
+	}
+	if !e.Info.Expires.IsZero() {
+		v.Expires = &e.Info.Expires
+	}
+	return v
+}
+
+var locksExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print live reservations as JSON for locks import",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reservations, err := lock.Export()
+		if err != nil {
+			return err
+		}
+		return writeJSON(os.Stdout, reservations)
+	},
+}
+
+var locksImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Re-acquire reservations from locks export",
+	Long: `Re-acquire reservations written by "fp locks export", from a file or stdin.
+
+Each port is locked with its remaining TTL. Ports that are already reserved,
+in use, or past their expiry are reported as conflicts; the rest are still
+acquired. Like reserve, the locks are held until they expire or fp is
+interrupted.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in := cmd.InOrStdin()
+		if len(args) == 1 {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		reservations, err := readReservations(in)
+		if err != nil {
+			return err
+		}
+
+		handles, conflicts, err := lock.Import(reservations)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			for _, h := range handles {
+				_ = h.Close()
+			}
+		}()
+
+		acquired := make([]int, 0, len(handles))
+		for _, h := range handles {
+			acquired = append(acquired, h.Port())
+		}
+		if jsonOutput {
+			if conflicts == nil {
+				conflicts = []lock.Conflict{}
+			}
+			if err := writeJSON(os.Stdout, map[string]any{"acquired": acquired, "conflicts": conflicts}); err != nil {
+				return err
+			}
+		} else {
+			for _, p := range acquired {
+				fmt.Fprintf(ui.Stderr(), "%s reserved port %d\n", ui.LabelOK(ui.Stderr()), p)
+			}
+			for _, c := range conflicts {
+				fmt.Fprintf(ui.Stderr(), "%s port %d: %s\n", ui.LabelWarn(ui.Stderr()), c.Port, c.Reason)
+			}
+		}
+		if len(handles) == 0 {
+			if len(conflicts) > 0 {
+				return fmt.Errorf("could not re-acquire any of %d reservation(s)", len(conflicts))
+			}
+			return nil
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		holdReservations(ctx, handles, reservations)
+		return nil
+	},
+}
+
+// readReservations accepts locks export output, optionally wrapped by
+// --host-meta.
+func readReservations(r io.Reader) ([]lock.Reservation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var reservations []lock.Reservation
+	if err := json.Unmarshal(data, &reservations); err == nil {
+		return reservations, nil
+	}
+	var wrapped struct {
+		Data []lock.Reservation `json:"data"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("parse reservations: %w", err)
+	}
+	return wrapped.Data, nil
+}
+
+// holdReservations releases each lock at its expiry and returns once all
+// have expired or ctx is done. Locks without an expiry are held until ctx
+// is done.
+func holdReservations(ctx context.Context, handles []*lock.Handle, reservations []lock.Reservation) {
+	expires := make(map[int]time.Time, len(reservations))
+	for _, r := range reservations {
+		expires[r.Port] = r.Expires
+	}
+	pending := slices.Clone(handles)
+	sort.SliceStable(pending, func(i, j int) bool {
+		a, b := expires[pending[i].Port()], expires[pending[j].Port()]
+		return !a.IsZero() && (b.IsZero() || a.Before(b))
+	})
+
+	for len(pending) > 0 {
+		next := expires[pending[0].Port()]
+		if next.IsZero() {
+			<-ctx.Done()
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		_ = pending[0].Close()
+		pending = pending[1:]
+	}
+}
+
+func init() {
+	locksCmd.Flags().BoolVar(&locksGC, "gc", false, "Remove stale locks and release expired reservations")
+	locksCmd.AddCommand(locksExportCmd)
+	locksCmd.AddCommand(locksImportCmd)
+	rootCmd.AddCommand(locksCmd)
+}
@@ -2,34 +2,122 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
-	"fp/internal/ports"
-	"fp/internal/scan"
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 var (
-	pickPrefer []int
-	pickRange  string
+	pickPrefer     []int
+	pickRange      string
+	pickFormat     string
+	pickVars       []string
+	pickCandidates string
+	pickFrom       int
+	pickStrict     bool
+	pickHold       bool
+	pickLabels     []string
+	pickProbeWait  time.Duration
+	pickCount      int
+	pickSorted     bool
 )
 
 var pickCmd = &cobra.Command{
 	Use:   "pick",
 	Short: "Pick a free TCP port (best-effort)",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		probe := ports.DefaultProbeOptions()
+		probe.Timeout = pickProbeWait
 		r, err := ports.ParseRange(pickRange)
 		if err != nil {
 			return err
 		}
 
-		chosen, err := ports.PickTCPPort(pickPrefer, r)
-		if err != nil {
+		format := pickFormat
+		if jsonOutput {
+			format = "json"
+		}
+		if format != "text" && format != "json" && format != "env" {
+			return fmt.Errorf("invalid format %q (expected text, json, or env)", pickFormat)
+		}
+
+		if pickFrom != 0 && pickCandidates != "" {
+			return fmt.Errorf("--from and --candidates are mutually exclusive")
+		}
+		if pickCount < 1 {
+			return fmt.Errorf("--count must be at least 1")
+		}
+		if pickCount > 1 && (pickFrom != 0 || pickCandidates != "" || pickHold) {
+			return fmt.Errorf("--count can't be combined with --from, --candidates or --hold")
+		}
+		if pickSorted && pickCount == 1 {
+			return fmt.Errorf("--sorted needs --count")
+		}
+		if len(pickLabels) > 0 && !pickHold {
+			return fmt.Errorf("--label needs --hold")
+		}
+		if err := validateLabels(pickLabels); err != nil {
 			return err
 		}
 
-		if jsonOutput {
-			return scan.WriteJSON(os.Stdout, map[string]int{"port": chosen})
+		var chosen int
+		if pickFrom != 0 {
+			chosen, err = ports.PickFrom(pickFrom, probe)
+			if err != nil {
+				return err
+			}
+		} else if pickCandidates != "" {
+			candidates, err := readCandidates(cmd.InOrStdin(), pickCandidates)
+			if err != nil {
+				return err
+			}
+			chosen, err = ports.PickFromCandidates(candidates, probe)
+			if err != nil {
+				return err
+			}
+		} else {
+			if cmd.Flags().Changed("prefer") {
+				warnings, err := checkPreferInRange(pickPrefer, r, pickStrict)
+				if err != nil {
+					return err
+				}
+				for _, w := range warnings {
+					fmt.Fprintf(ui.Stderr(), "%s %s\n", ui.LabelWarn(ui.Stderr()), w)
+				}
+			}
+			prefer := preferredPorts(pickPrefer, r, cmd.Flags().Changed("prefer"))
+			if pickCount > 1 {
+				return pickMany(format, prefer, r, probe)
+			}
+			chosen, err = ports.PickTCPPort(prefer, r, probe)
+			if err != nil {
+				return err
+			}
+		}
+
+		if pickHold {
+			pid, err := startHold(chosen, pickLabels)
+			if err != nil {
+				return fmt.Errorf("hold port %d: %w", chosen, err)
+			}
+			if format == "json" {
+				return writeJSON(os.Stdout, map[string]int{"port": chosen, "pid": pid})
+			}
+			fmt.Fprintf(ui.Stderr(), "%s holding port %d in pid %d; fp release %d to free it\n", ui.Brand(ui.Stderr(), "fp:"), chosen, pid, chosen)
+		}
+
+		switch format {
+		case "json":
+			return writeJSON(os.Stdout, map[string]int{"port": chosen})
+		case "env":
+			return writeEnvAssignments(os.Stdout, pickVars, []int{chosen})
 		}
 
 		fmt.Fprintf(os.Stdout, "%d\n", chosen)
@@ -40,4 +128,130 @@ var pickCmd = &cobra.Command{
 func init() {
 	pickCmd.Flags().IntSliceVar(&pickPrefer, "prefer", []int{3000}, "Preferred ports (tries in order; 0 means OS-assigned)")
 	pickCmd.Flags().StringVar(&pickRange, "range", "3000-3999", "Port range to search (inclusive)")
+	pickCmd.Flags().StringVar(&pickFormat, "format", "text", "Output format (text, json, env)")
+	pickCmd.Flags().StringSliceVar(&pickVars, "var", []string{"FREEPORT_PORT"}, "Variable name(s) for --format env")
+	pickCmd.Flags().BoolVar(&pickStrict, "strict", false, "Reject --prefer ports outside --range instead of warning")
+	pickCmd.Flags().BoolVar(&pickHold, "hold", false, "Keep the port locked by a background process until fp release")
+	pickCmd.Flags().StringArrayVar(&pickLabels, "label", nil, "With --hold, tag the lock (repeatable)")
+	pickCmd.Flags().IntVar(&pickFrom, "from", 0, "Pick the lowest free port at or above this one (ignores --prefer/--range)")
+	pickCmd.Flags().DurationVar(&pickProbeWait, "probe-timeout", ports.DefaultProbeOptions().Timeout, "Skip a port whose bind probe takes longer than this (0 waits forever)")
+	pickCmd.Flags().IntVar(&pickCount, "count", 1, "Pick this many distinct ports: free --prefer ports in order, then range ports ascending")
+	pickCmd.Flags().BoolVar(&pickSorted, "sorted", false, "With --count, return the ports in ascending order instead")
+	pickCmd.Flags().StringVar(&pickCandidates, "candidates", "", "Ordered ports/ranges to try instead of --prefer/--range (\"-\" reads stdin)")
+}
+
+// checkPreferInRange flags preferred ports outside the range. They are still
+// tried first by default, since that's long-standing behavior, but it is
+// usually a typo; strict turns the warning into an error. 0 (OS-assigned)
+// is never out of range.
+func checkPreferInRange(prefer []int, r ports.Range, strict bool) ([]string, error) {
+	var warnings []string
+	for _, p := range prefer {
+		if p == 0 || r.Contains(p) {
+			continue
+		}
+		if strict {
+			return nil, fmt.Errorf("preferred port %d is outside range %d-%d", p, r.Start, r.End)
+		}
+		warnings = append(warnings, fmt.Sprintf("preferred port %d is outside range %d-%d; trying it anyway (use --strict to reject)", p, r.Start, r.End))
+	}
+	return warnings, nil
+}
+
+// readCandidates parses spec, or stdin when spec is "-".
+func readCandidates(stdin io.Reader, spec string) ([]int, error) {
+	if spec == "-" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("read candidates: %w", err)
+		}
+		spec = string(data)
+	}
+	return ports.ParseCandidates(spec)
+}
+
+// preferredPorts is the --prefer list pick tries before the range. An
+// explicit --prefer is used as given; the default is dropped where it lies
+// outside r, so --range 4000-4010 doesn't pick the default 3000.
+func preferredPorts(prefer []int, r ports.Range, explicit bool) []int {
+	if explicit {
+		return prefer
+	}
+	var inRange []int
+	for _, p := range prefer {
+		if p == 0 || r.Contains(p) {
+			inRange = append(inRange, p)
+		}
+	}
+	return inRange
+}
+
+// pickMany handles pick --count: free preferred ports in --prefer order, then
+// range ports ascending, or all ascending with --sorted.
+func pickMany(format string, prefer []int, r ports.Range, probe ports.ProbeOptions) error {
+	picked, err := ports.PickTCPPorts(prefer, r, pickCount, probe)
+	if err != nil {
+		return err
+	}
+	if pickSorted {
+		slices.Sort(picked)
+	}
+	switch format {
+	case "json":
+		return writeJSON(os.Stdout, map[string][]int{"ports": picked})
+	case "env":
+		return writeEnvAssignments(os.Stdout, pickVars, picked)
+	}
+	for _, p := range picked {
+		fmt.Fprintf(os.Stdout, "%d\n", p)
+	}
+	return nil
+}
+
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// writeEnvAssignments prints one NAME=value line per port, suitable for
+// `eval "$(fp pick --format env)"`. A single name with several ports is
+// suffixed _1.._N; otherwise names and ports are paired in order.
+func writeEnvAssignments(w io.Writer, names []string, values []int) error {
+	if len(names) == 0 {
+		return fmt.Errorf("missing variable name for env output")
+	}
+	if len(names) != 1 && len(names) != len(values) {
+		return fmt.Errorf("got %d variable names for %d ports", len(names), len(values))
+	}
+	for i, v := range values {
+		name := names[0]
+		if len(names) > 1 {
+			name = names[i]
+		} else if len(values) > 1 {
+			name = fmt.Sprintf("%s_%d", name, i+1)
+		}
+		if !envVarName.MatchString(name) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", name, shellQuote(fmt.Sprintf("%d", v))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellQuote returns s unchanged when it only contains shell-safe characters
+// and single-quotes it otherwise.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-.,:/@%+=", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mtreilly/freeport/internal/ports"
+)
+
+func TestWriteEnvAssignmentsSinglePort(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeEnvAssignments(&buf, []string{"FREEPORT_PORT"}, []int{3000}); err != nil {
+		t.Fatalf("writeEnvAssignments: %v", err)
+	}
+	if got, want := buf.String(), "FREEPORT_PORT=3000\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestWriteEnvAssignmentsMultiPort(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeEnvAssignments(&buf, []string{"FREEPORT_PORT"}, []int{3000, 3001}); err != nil {
+		t.Fatalf("writeEnvAssignments: %v", err)
+	}
+	if got, want := buf.String(), "FREEPORT_PORT_1=3000\nFREEPORT_PORT_2=3001\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	buf.Reset()
+	if err := writeEnvAssignments(&buf, []string{"WEB_PORT", "API_PORT"}, []int{3000, 3001}); err != nil {
+		t.Fatalf("writeEnvAssignments: %v", err)
+	}
+	if got, want := buf.String(), "WEB_PORT=3000\nAPI_PORT=3001\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestWriteEnvAssignmentsRejectsBadNames(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeEnvAssignments(&buf, []string{"1BAD"}, []int{3000}); err == nil {
+		t.Fatalf("expected invalid name error")
+	}
+	if err := writeEnvAssignments(&buf, []string{"A", "B", "C"}, []int{3000, 3001}); err == nil {
+		t.Fatalf("expected mismatch error")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	cases := map[string]string{
+		"3000":    "3000",
+		"":        "''",
+		"a b":     "'a b'",
+		"it's":    `'it'\''s'`,
+		"$(evil)": "'$(evil)'",
+	}
+	for in, want := range cases {
+		if got := shellQuote(in); got != want {
+			t.Fatalf("shellQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestReadCandidatesFromStdin(t *testing.T) {
+	got, err := readCandidates(strings.NewReader("3000-3002,4000\n"), "-")
+	if err != nil {
+		t.Fatalf("readCandidates: %v", err)
+	}
+	if want := []int{3000, 3001, 3002, 4000}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestCheckPreferInRange(t *testing.T) {
+	r := ports.Range{Start: 3000, End: 3999}
+
+	warnings, err := checkPreferInRange([]int{3000, 9000, 0}, r, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "preferred port 9000 is outside range 3000-3999") {
+		t.Fatalf("expected one warning for 9000, got %v", warnings)
+	}
+
+	if _, err := checkPreferInRange([]int{9000}, r, true); err == nil || !strings.Contains(err.Error(), "9000") {
+		t.Fatalf("expected strict error naming 9000, got %v", err)
+	}
+	if warnings, err := checkPreferInRange([]int{3500, 0}, r, true); err != nil || len(warnings) != 0 {
+		t.Fatalf("expected in-range prefers to pass strict mode, got %v %v", warnings, err)
+	}
+}
+
+func TestPreferredPortsDropsDefaultOutsideRange(t *testing.T) {
+	r := ports.Range{Start: 4000, End: 4010}
+	cases := []struct {
+		name     string
+		prefer   []int
+		explicit bool
+		want     []int
+	}{
+		{"default outside range", []int{3000}, false, nil},
+		{"default inside range", []int{4005}, false, []int{4005}},
+		{"default OS-assigned", []int{0}, false, []int{0}},
+		{"explicit outside range", []int{3000}, true, []int{3000}},
+	}
+	for _, tc := range cases {
+		if got := preferredPorts(tc.prefer, r, tc.explicit); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
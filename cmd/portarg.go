@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/mtreilly/freeport/internal/config"
+)
+
+// Lookups behind parsePortArg; tests swap them out.
+var (
+	loadConfig    = config.Load
+	loadRegistry  = config.LoadRegistry
+	lookupService = func(name string) (int, error) { return net.LookupPort("tcp", name) }
+)
+
+// aliasSection is the config section mapping names to ports, e.g. web = 3000.
+const aliasSection = "aliases"
+
+// parsePortArg resolves a command's port argument. Numbers win, then aliases
+// from the config file, then the project's freeport.ports registry, then
+// service names from /etc/services, so users can override a system service
+// name with their own alias.
+func parsePortArg(s string) (int, error) {
+	if port, err := strconv.Atoi(s); err == nil {
+		if port < 1 || port > 65535 {
+			return 0, fmt.Errorf("invalid port: %q", s)
+		}
+		return port, nil
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return 0, err
+	}
+	if v, ok := cfg.Section(aliasSection)[s]; ok {
+		port, err := strconv.Atoi(v)
+		if err != nil || port < 1 || port > 65535 {
+			return 0, fmt.Errorf("alias %q: invalid port %q", s, v)
+		}
+		return port, nil
+	}
+
+	reg, err := loadRegistry()
+	if err != nil {
+		return 0, err
+	}
+	if e, ok := reg.Lookup(s); ok {
+		return e.Port, nil
+	}
+
+	if port, err := lookupService(s); err == nil && port > 0 {
+		return port, nil
+	}
+	return 0, fmt.Errorf("invalid port: %q", s)
+}
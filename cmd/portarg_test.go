@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mtreilly/freeport/internal/config"
+)
+
+func stubPortArgLookups(t *testing.T, cfg string, services map[string]int) {
+	t.Helper()
+	origConfig, origRegistry, origService := loadConfig, loadRegistry, lookupService
+	loadConfig = func() (*config.Config, error) { return config.Parse(strings.NewReader(cfg)) }
+	loadRegistry = func() (*config.Registry, error) { return &config.Registry{}, nil }
+	lookupService = func(name string) (int, error) {
+		if port, ok := services[name]; ok {
+			return port, nil
+		}
+		return 0, errors.New("unknown service")
+	}
+	t.Cleanup(func() { loadConfig, loadRegistry, lookupService = origConfig, origRegistry, origService })
+}
+
+func TestParsePortArgPrecedence(t *testing.T) {
+	stubPortArgLookups(t, "[aliases]\nweb = 3000\nhttp = 8080\n4000 = 5000\n", map[string]int{"http": 80, "https": 443})
+
+	cases := []struct {
+		in   string
+		want int
+	}{
+		{"4000", 4000}, // numeric beats an alias of the same name
+		{"web", 3000},  // alias
+		{"http", 8080}, // alias beats /etc/services
+		{"https", 443}, // service name
+	}
+	for _, tc := range cases {
+		got, err := parsePortArg(tc.in)
+		if err != nil || got != tc.want {
+			t.Fatalf("parsePortArg(%q) = %d, %v; want %d", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestParsePortArgErrors(t *testing.T) {
+	stubPortArgLookups(t, "[aliases]\nbroken = nope\n", nil)
+
+	for _, in := range []string{"0", "70000", "nosuchname"} {
+		if _, err := parsePortArg(in); err == nil || !strings.Contains(err.Error(), "invalid port") {
+			t.Fatalf("parsePortArg(%q): expected invalid port error, got %v", in, err)
+		}
+	}
+	if _, err := parsePortArg("broken"); err == nil || !strings.Contains(err.Error(), `alias "broken"`) {
+		t.Fatalf("expected alias error, got %v", err)
+	}
+}
+
+func TestParsePortArgUsesRegistry(t *testing.T) {
+	stubPortArgLookups(t, "[aliases]\nweb = 3000\n", map[string]int{"postgresql": 5432})
+	loadRegistry = func() (*config.Registry, error) {
+		return config.ParseRegistry(strings.NewReader("web = 8000\napi = 4000\npostgresql = 6543\n"))
+	}
+
+	for in, want := range map[string]int{"web": 3000, "api": 4000, "postgresql": 6543} {
+		if got, err := parsePortArg(in); err != nil || got != want {
+			t.Fatalf("parsePortArg(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// Hidden --profile and --trace diagnostics, for performance bug reports.
+var (
+	profilePath string
+	tracePath   string
+)
+
+// stopProfiling flushes whatever startProfiling began. It is safe to call
+// more than once.
+var stopProfiling = func() {}
+
+// startProfiling begins a CPU profile and an execution trace when asked to.
+// Both run until exit, which stops them from Execute or exit, since commands
+// that set their own exit status never reach cobra's post-run hooks.
+func startProfiling() error {
+	var stops []func()
+	stopProfiling = func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+		stops = nil
+	}
+	if profilePath != "" {
+		f, err := os.Create(profilePath)
+		if err != nil {
+			return fmt.Errorf("--profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return fmt.Errorf("--profile: %w", err)
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+	if tracePath != "" {
+		f, err := os.Create(tracePath)
+		if err != nil {
+			stopProfiling()
+			return fmt.Errorf("--trace: %w", err)
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stopProfiling()
+			return fmt.Errorf("--trace: %w", err)
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+	return nil
+}
+
+// exit ends fp with code once profiles are written out. Commands use it in
+// place of os.Exit.
+func exit(code int) {
+	stopProfiling()
+	os.Exit(code)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+// protoChoices are the values list and who accept for --proto.
+var protoChoices = []string{"tcp", "udp", "all"}
+
+func validateProto(proto string) error {
+	if !slices.Contains(protoChoices, proto) {
+		return fmt.Errorf("invalid --proto %q (expected %s)", proto, strings.Join(protoChoices, ", "))
+	}
+	return nil
+}
+
+// listenersForProto runs tcp for TCP listeners and/or the UDP scan. UDP has
+// no listen state; its "listeners" are bound, unconnected sockets.
+func listenersForProto(ctx context.Context, proto string, tcp func(context.Context) ([]scan.Listener, error)) ([]scan.Listener, error) {
+	var out []scan.Listener
+	if proto != "udp" {
+		listeners, err := tcp(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, listeners...)
+	}
+	if proto != "tcp" {
+		listeners, err := listUDPListeners(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("udp: %w", err)
+		}
+		out = append(out, listeners...)
+	}
+	return out, nil
+}
+
+// portLabel is the PORT column text: the number, with "/udp" for UDP so
+// mixed --proto all output stays unambiguous.
+func portLabel(l scan.Listener) string {
+	if l.Proto == "udp" {
+		return strconv.Itoa(l.Port) + "/udp"
+	}
+	return strconv.Itoa(l.Port)
+}
+
+// noListenersText is who's explanation for a free port under --proto.
+func noListenersText(proto string) string {
+	switch proto {
+	case "udp":
+		return "no bound UDP sockets found"
+	case "all":
+		return "no TCP listeners or bound UDP sockets found"
+	}
+	return "no TCP listeners found"
+}
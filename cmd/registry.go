@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mtreilly/freeport/internal/config"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/muesli/termenv"
+)
+
+// registryStatus is one freeport.ports entry checked against a scan: "free"
+// when nothing listens, "ok" when every listener matches the expected
+// command (or the entry names none), "conflict" otherwise.
+type registryStatus struct {
+	config.RegistryEntry
+	Status  string          `json:"status"`
+	Holders []scan.Listener `json:"holders,omitempty"`
+}
+
+// checkRegistry scans once and classifies each registry entry. An entry
+// without an expected command only reserves its port, so any holder is
+// fine. Holders are enriched first: lsof truncates command names, and the
+// executable or command line is often what names the service.
+func checkRegistry(ctx context.Context, reg *config.Registry) ([]registryStatus, error) {
+	all, err := listTCPListeners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var listeners []scan.Listener
+	for _, l := range all {
+		if slices.ContainsFunc(reg.Entries, func(e config.RegistryEntry) bool { return e.Port == l.Port }) {
+			listeners = append(listeners, l)
+		}
+	}
+	enrichProcessInfo(ctx, listeners)
+	out := make([]registryStatus, 0, len(reg.Entries))
+	for _, e := range reg.Entries {
+		st := registryStatus{RegistryEntry: e, Status: "free"}
+		for _, l := range listeners {
+			if l.Port != e.Port {
+				continue
+			}
+			st.Holders = append(st.Holders, l)
+			if st.Status != "conflict" {
+				st.Status = "ok"
+				if !registryCommandMatches(e.Command, l) {
+					st.Status = "conflict"
+				}
+			}
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// registryCommandMatches reports whether l is what the registry expects on
+// its port: no name is expected, or the expected name appears,
+// case-insensitively, in the command, the executable's base name or the
+// command line.
+func registryCommandMatches(expected string, l scan.Listener) bool {
+	if expected == "" {
+		return true
+	}
+	want := strings.ToLower(expected)
+	for _, have := range []string{l.Command, filepath.Base(l.Executable), l.CommandLine} {
+		if have != "" && have != "." && strings.Contains(strings.ToLower(have), want) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeRegistryStatus prints one line per registry entry.
+func writeRegistryStatus(w *termenv.Output, statuses []registryStatus) {
+	for _, st := range statuses {
+		status := ui.Success(w, st.Status)
+		if st.Status == "conflict" {
+			status = ui.Warning(w, st.Status)
+		}
+		line := fmt.Sprintf("%-12s %5d  %s", st.Name, st.Port, status)
+		var held []string
+		for _, l := range st.Holders {
+			held = append(held, fmt.Sprintf("%s (pid %d)", l.Command, l.PID))
+		}
+		if len(held) > 0 {
+			line += "  " + strings.Join(held, ", ")
+		}
+		if st.Status == "conflict" && st.Command != "" {
+			line += ui.Muted(w, fmt.Sprintf("; expected %s", st.Command))
+		}
+		fmt.Fprintln(w, line)
+	}
+}
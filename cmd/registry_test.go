@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mtreilly/freeport/internal/config"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/muesli/termenv"
+)
+
+func TestCheckRegistry(t *testing.T) {
+	stubListeners(t, func() []scan.Listener {
+		return []scan.Listener{
+			{Port: 3000, PID: 10, Command: "node"},
+			{Port: 4000, PID: 11, Command: "python3"},
+			{Port: 5432, PID: 12, Command: "postgres"},
+			{Port: 6379, PID: 13, Command: "docker-pr", CommandLine: "/usr/bin/docker-proxy -host-port 6379"},
+			{Port: 7000, PID: 14, Command: "my-long-s"},
+			{Port: 9000, PID: 15, Command: "unrelated"},
+		}
+	})
+	// Enrichment supplies what lsof's 9-character command cuts off; only
+	// registry ports are looked up.
+	orig := enrichProcessInfo
+	t.Cleanup(func() { enrichProcessInfo = orig })
+	enrichProcessInfo = func(_ context.Context, ls []scan.Listener) {
+		for i := range ls {
+			if ls[i].PID == 15 {
+				t.Errorf("enriched pid 15, which holds no registry port")
+			}
+			if ls[i].PID == 14 {
+				ls[i].Executable = "/opt/bin/my-long-service"
+			}
+		}
+	}
+	reg, err := config.ParseRegistry(strings.NewReader(`
+web   = 3000 node
+api   = 4000 Node
+db    = 5432
+cache = 6379 docker-proxy
+queue = 5672 rabbitmq
+long  = 7000 my-long-service
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := checkRegistry(context.Background(), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, st := range statuses {
+		got[st.Name] = st.Status
+	}
+	want := map[string]string{"web": "ok", "api": "conflict", "db": "ok", "cache": "ok", "queue": "free", "long": "ok"}
+	for name, status := range want {
+		if got[name] != status {
+			t.Fatalf("%s: got %q, want %q (all: %v)", name, got[name], status, got)
+		}
+	}
+
+	var out bytes.Buffer
+	writeRegistryStatus(termenv.NewOutput(&out), statuses)
+	if !strings.Contains(out.String(), "python3 (pid 11); expected Node") {
+		t.Fatalf("conflict line missing holder and expectation:\n%s", out.String())
+	}
+}
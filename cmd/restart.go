@@ -0,0 +1,49 @@
+package cmd
+
+import "time"
+
+// restartPolicy limits restarts to max within a sliding window, the usual
+// supervisor crash-loop guard. The last max restart times are kept in a
+// ring buffer; a run that stays up longer than the window resets it.
+type restartPolicy struct {
+	max    int
+	window time.Duration
+	now    func() time.Time
+
+	times []time.Time
+	next  int
+	count int
+}
+
+func newRestartPolicy(max int, window time.Duration) *restartPolicy {
+	if max < 0 {
+		max = 0
+	}
+	return &restartPolicy{max: max, window: window, now: time.Now, times: make([]time.Time, max)}
+}
+
+// Allow records a restart after a run that lasted uptime and reports whether
+// it fits the budget. A max of 0 means unlimited restarts.
+func (p *restartPolicy) Allow(uptime time.Duration) bool {
+	if p.max == 0 {
+		return true
+	}
+	if p.window > 0 && uptime > p.window {
+		p.count, p.next = 0, 0
+	}
+
+	now := p.now()
+	if p.count == p.max {
+		oldest := p.times[p.next]
+		if p.window <= 0 || now.Sub(oldest) < p.window {
+			return false
+		}
+	}
+
+	p.times[p.next] = now
+	p.next = (p.next + 1) % p.max
+	if p.count < p.max {
+		p.count++
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestRestartPolicyWindowedCount(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	p := newRestartPolicy(3, time.Minute)
+	p.now = clock.Now
+
+	for i := 0; i < 3; i++ {
+		if !p.Allow(time.Second) {
+			t.Fatalf("restart %d should be allowed", i+1)
+		}
+		clock.Advance(5 * time.Second)
+	}
+	if p.Allow(time.Second) {
+		t.Fatalf("4th restart within the window should be refused")
+	}
+
+	// Once the oldest restart ages out of the window, one more is allowed.
+	clock.Advance(47 * time.Second)
+	if !p.Allow(time.Second) {
+		t.Fatalf("expected restart once the oldest aged out of the window")
+	}
+	if p.Allow(time.Second) {
+		t.Fatalf("expected the window to be full again")
+	}
+}
+
+func TestRestartPolicyResetsAfterLongUptime(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	p := newRestartPolicy(2, time.Minute)
+	p.now = clock.Now
+
+	p.Allow(time.Second)
+	p.Allow(time.Second)
+	if p.Allow(time.Second) {
+		t.Fatalf("expected budget to be exhausted")
+	}
+	if !p.Allow(2 * time.Minute) {
+		t.Fatalf("expected a run longer than the window to reset the counter")
+	}
+}
+
+func TestRestartPolicyUnlimited(t *testing.T) {
+	p := newRestartPolicy(0, time.Minute)
+	for i := 0; i < 100; i++ {
+		if !p.Allow(0) {
+			t.Fatalf("expected unlimited restarts with max=0")
+		}
+	}
+}
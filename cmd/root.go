@@ -1,33 +1,111 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"syscall"
 
-	"fp/internal/ui"
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/mtreilly/freeport/pkg/freeport"
 	"github.com/spf13/cobra"
 )
 
 var jsonOutput bool
 var noColor bool
+var plainOutput bool
+
+// dumpRaw is the hidden --dump-raw debugging flag on list and doctor.
+var dumpRaw bool
+
+// Scanner entry points used by commands; tests swap them out. The port
+// check goes through the public pkg/freeport API, so the CLI runs what
+// library users get; the rest need the full scan.Listener.
+var (
+	listTCPListeners       = scan.ListTCPListeners
+	listTCPListenersAll    = scan.ListTCPListenersAll
+	listUDPListeners       = scan.ListUDPListeners
+	listTCPListenersOnPort = scan.ListTCPListenersOnPort
+	queryTCPPort           = scan.QueryTCPPort
+	hasTCPListenerOnPort   = freeport.HasTCPListenerOnPort
+	listConnections        = scan.ListConnections
+	probeTCPPort           = ports.ProbeTCP
+	probeStatus            = ports.ProbeStatus
+	connectTCP             = ports.ConnectTCP
+	enrichProcessInfo      = scan.EnrichListenersWithProcessInfo
+
+	// signalProcess is syscall.Kill, for kill and run --on-conflict kill.
+	signalProcess = syscall.Kill
+)
 
 var rootCmd = &cobra.Command{
 	Use:   "fp",
 	Short: "Local dev port helpers (list/who/kill/pick/run)",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		ui.Configure(noColor)
-		return nil
+		ui.Configure(noColor, plainOutput)
+		if err := validateJSONCase(jsonCase); err != nil {
+			return err
+		}
+		scan.Warn = func(msg string) {
+			fmt.Fprintf(ui.Stderr(), "%s %s\n", ui.LabelWarn(ui.Stderr()), msg)
+		}
+		if dumpRaw {
+			scan.RawOutput = os.Stderr
+		}
+		return startProfiling()
 	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	args, warnings, err := withEnvArgs(rootCmd, os.Args[1:], os.Getenv(argsEnv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(ui.Stderr(), "%s %s\n", ui.LabelWarn(ui.Stderr()), w)
+	}
+	invocationArgs = args
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	stopProfiling()
+	if err != nil {
+		var status exitStatusError
+		if errors.As(err, &status) {
+			os.Exit(status.Code)
+		}
 		os.Exit(1)
 	}
 }
 
+// exitStatusError makes Execute exit with Code rather than 1. Commands
+// returning it silence cobra's error and usage output themselves.
+type exitStatusError struct {
+	Code int
+}
+
+func (e exitStatusError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// addDumpRawFlag registers the hidden --dump-raw flag on cmd.
+func addDumpRawFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&dumpRaw, "dump-raw", false, "Debug: print the scan tool's raw output to stderr before parsing")
+	_ = cmd.Flags().MarkHidden("dump-raw")
+}
+
 func init() {
 	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output JSON")
+	rootCmd.PersistentFlags().BoolVar(&hostMeta, "host-meta", false, "Wrap JSON output as {host, scanned_at, data} for fleet aggregation")
+	rootCmd.PersistentFlags().StringVar(&jsonCase, "json-case", jsonCaseSnake, "JSON key style: snake (command_line) or camel (commandLine)")
 	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable ANSI colors")
+	rootCmd.PersistentFlags().StringVar(&profilePath, "profile", "", "Debug: write a CPU profile of the command to this file (go tool pprof)")
+	rootCmd.PersistentFlags().StringVar(&tracePath, "trace", "", "Debug: write an execution trace of the command to this file (go tool trace)")
+	_ = rootCmd.PersistentFlags().MarkHidden("profile")
+	_ = rootCmd.PersistentFlags().MarkHidden("trace")
+	rootCmd.PersistentFlags().BoolVar(&plainOutput, "plain", false, "ASCII-only output with no colors or escape sequences (implied by TERM=dumb)")
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(whoCmd)
 	rootCmd.AddCommand(killCmd)
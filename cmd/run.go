@@ -1,20 +1,39 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
-	"fp/internal/lock"
-	"fp/internal/ports"
-	"fp/internal/ui"
+	"github.com/mattn/go-isatty"
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 var (
-	runPrefer []int
-	runRange  string
-	runEnvVar string
+	runPrefer        []int
+	runRange         string
+	runEnvVar        string
+	runRestart       bool
+	runMaxRestarts   int
+	runRestartWindow time.Duration
+	runExec          bool
+	runActivate      bool
+	runLabels        []string
+	runOnConflict    string
+	runReplace       bool
+	runQuiet         bool
+	runPrintPort     bool
+	runBanner        string
+	runWaitStable    time.Duration
 )
 
 var runCmd = &cobra.Command{
@@ -30,33 +49,272 @@ var runCmd = &cobra.Command{
 			return fmt.Errorf("missing command after --")
 		}
 
+		if runExec && runRestart {
+			return fmt.Errorf("--exec and --restart are mutually exclusive")
+		}
+		if runExec && runActivate {
+			return fmt.Errorf("--exec and --activate are mutually exclusive")
+		}
+		if err := validateBannerFlags(); err != nil {
+			return err
+		}
+
 		r, err := ports.ParseRange(runRange)
 		if err != nil {
 			return err
 		}
 
 		commandArgs := args[dash:]
+		if err := preflightCommand(commandArgs[0]); err != nil {
+			return err
+		}
+		if err := validateLabels(runLabels); err != nil {
+			return err
+		}
+		var protected []string
+		switch runOnConflict {
+		case conflictFallback, conflictFail:
+		case conflictKill:
+			if protected, err = protectedUsers(nil, false); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid --on-conflict %q (want %s, %s or %s)", runOnConflict, conflictFallback, conflictFail, conflictKill)
+		}
 
-		selectedPort, lockHandle, err := lock.PickAndLockTCPPort(runPrefer, r)
+		conflict := runOnConflict
+		if runReplace {
+			if cmd.Flags().Changed("on-conflict") {
+				return fmt.Errorf("--replace and --on-conflict are mutually exclusive")
+			}
+			if len(runPrefer) != 1 || runPrefer[0] < 1 || runPrefer[0] > 65535 {
+				return fmt.Errorf("--replace needs exactly one --prefer port")
+			}
+			if protected, err = protectedUsers(nil, false); err != nil {
+				return err
+			}
+			conflict = conflictReplace
+		}
+
+		if runWaitStable > 0 && (conflict == conflictKill || conflict == conflictReplace) {
+			return fmt.Errorf("--wait-stable works with --on-conflict fallback or fail, not %s", conflict)
+		}
+
+		selectedPort, lockHandle, err := pickStableRunPort(conflict, runPrefer, r, protected, runWaitStable)
 		if err != nil {
 			return err
 		}
 		defer lockHandle.Close()
+		if len(runLabels) > 0 {
+			if err := lockHandle.SetLabels(runLabels); err != nil {
+				return err
+			}
+		}
 
-		fmt.Fprintf(ui.Stderr(), "%s using port %d\n", ui.Brand(ui.Stderr(), "fp:"), selectedPort)
+		if err := writeRunBanner(os.Stderr, selectedPort, runEnvVar); err != nil {
+			return err
+		}
 
-		child := exec.Command(commandArgs[0], commandArgs[1:]...)
-		child.Stdin = os.Stdin
-		child.Stdout = os.Stdout
-		child.Stderr = os.Stderr
-		child.Env = append(os.Environ(), fmt.Sprintf("%s=%d", runEnvVar, selectedPort))
+		env := append(os.Environ(), fmt.Sprintf("%s=%d", runEnvVar, selectedPort))
+		if runExec {
+			// The lock fd is inherited by the new image rather than closed.
+			if err := lockHandle.Inherit(); err != nil {
+				return fmt.Errorf("keep port lock across exec: %w", err)
+			}
+			return execCommand(commandArgs, env)
+		}
+
+		var socket *os.File
+		if runActivate {
+			// Bound once and kept open, so restarts reuse the same socket.
+			socket, err = bindActivationSocket(selectedPort)
+			if err != nil {
+				return err
+			}
+			defer socket.Close()
+		}
 
-		return child.Run()
+		// A child on a terminal stays in fp's process group. In a group of
+		// its own it would be a background job there: stopped by SIGTTIN
+		// the moment it read the terminal, and out of Ctrl-Z's reach.
+		interactive := isatty.IsTerminal(os.Stdin.Fd())
+		policy := newRestartPolicy(runMaxRestarts, runRestartWindow)
+		for {
+			var child *exec.Cmd
+			if socket != nil {
+				child = activationCommand(commandArgs, env, socket)
+			} else {
+				child = exec.Command(commandArgs[0], commandArgs[1:]...)
+				child.Env = env
+			}
+			child.Stdin = os.Stdin
+			child.Stdout = os.Stdout
+			child.Stderr = os.Stderr
+			if !interactive {
+				child.SysProcAttr = groupAttr()
+			}
+
+			started := time.Now()
+			relayed, err := runRelayingSignals(child, !interactive)
+			var exitErr *exec.ExitError
+			if err == nil || relayed || !runRestart || !errors.As(err, &exitErr) {
+				return childExitStatus(cmd, commandArgs[0], err)
+			}
+			if !policy.Allow(time.Since(started)) {
+				return fmt.Errorf("restart loop detected: %d restarts within %s", runMaxRestarts, runRestartWindow)
+			}
+			fmt.Fprintf(ui.Stderr(), "%s command exited (%v); restarting\n", ui.Brand(ui.Stderr(), "fp:"), err)
+		}
 	},
 }
 
+// relayedSignals are passed on from fp to run's child.
+var relayedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// runRelayingSignals runs child, forwarding relayedSignals to its process
+// group meanwhile, so Ctrl-C or a service manager's TERM stops the whole
+// tree instead of orphaning it with the port. fp stays alive until the
+// child exits and only then releases the port lock. relayed reports whether
+// any signal was forwarded, which rules out a --restart.
+//
+// A child that isn't grouped shares fp's foreground group, and the terminal
+// already delivers Ctrl-C to both; only TERM and HUP are passed on, to the
+// child itself.
+func runRelayingSignals(child *exec.Cmd, grouped bool) (relayed bool, err error) {
+	// Catch signals before the child exists, so an early one isn't fatal
+	// to fp alone.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, relayedSignals...)
+	defer signal.Stop(sigs)
+	if err := child.Start(); err != nil {
+		return false, err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- child.Wait() }()
+	for {
+		select {
+		case sig := <-sigs:
+			relayed = true
+			if !grouped && sig == os.Interrupt {
+				continue
+			}
+			var err error
+			if grouped {
+				err = signalGroup(child.Process.Pid, sig)
+			} else {
+				err = child.Process.Signal(sig)
+			}
+			if err != nil && !errors.Is(err, syscall.ESRCH) && !errors.Is(err, os.ErrProcessDone) {
+				fmt.Fprintf(ui.Stderr(), "%s can't forward %v to pid %d: %v\n", ui.LabelWarn(ui.Stderr()), sig, child.Process.Pid, err)
+			}
+		case err := <-done:
+			return relayed, err
+		}
+	}
+}
+
+// childExitStatus makes run exit the way its command did: with the same
+// code, or 128+N after signal N, as a shell reports it. A one-line note
+// replaces cobra's error and usage output. Anything other than an exit
+// means the command never ran, and is reported as such.
+func childExitStatus(cmd *cobra.Command, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("can't start %q: %w", name, err)
+	}
+	code := exitErr.ExitCode()
+	note := fmt.Sprintf("command exited with status %d", code)
+	if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		code = 128 + int(ws.Signal())
+		note = fmt.Sprintf("command killed by %s; exiting %d", describeSignal(ws.Signal()), code)
+	}
+	cmd.SilenceErrors, cmd.SilenceUsage = true, true
+	if !runQuiet && !jsonOutput {
+		fmt.Fprintf(ui.Stderr(), "%s %s\n", ui.Brand(ui.Stderr(), "fp:"), note)
+	}
+	return exitStatusError{Code: code}
+}
+
+// preflightCommand fails fast on a command that can't be run, before a port
+// is picked and locked for it. Paths are checked directly; bare names are
+// looked up in PATH.
+func preflightCommand(name string) error {
+	if !strings.Contains(name, "/") {
+		if _, err := exec.LookPath(name); err != nil {
+			return fmt.Errorf("command not found: %q", name)
+		}
+		return nil
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return fmt.Errorf("command not found: %q", name)
+	}
+	if info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("command is not executable: %q", name)
+	}
+	return nil
+}
+
 func init() {
 	runCmd.Flags().IntSliceVar(&runPrefer, "prefer", []int{3000}, "Preferred ports (tries in order)")
 	runCmd.Flags().StringVar(&runRange, "range", "3000-3999", "Port range to search (inclusive)")
 	runCmd.Flags().StringVar(&runEnvVar, "env", "PORT", "Environment variable name to set")
+	runCmd.Flags().BoolVar(&runRestart, "restart", false, "Restart the command when it exits non-zero")
+	runCmd.Flags().IntVar(&runMaxRestarts, "max-restarts", 5, "With --restart, max restarts within --restart-window (0 = unlimited)")
+	runCmd.Flags().BoolVar(&runExec, "exec", false, "Replace fp with the command instead of running it as a child (Unix only)")
+	runCmd.Flags().BoolVar(&runActivate, "activate", false, "Bind the port and pass it as fd 3 via systemd socket activation (LISTEN_FDS)")
+	runCmd.Flags().StringVar(&runOnConflict, "on-conflict", conflictFallback, "When no preferred port is free: fallback (search --range), fail, or kill (evict your own process from the first preferred port)")
+	runCmd.Flags().BoolVar(&runReplace, "replace", false, "Take exactly the --prefer port, killing your own process on it if needed; fail if it can't be reclaimed")
+	runCmd.Flags().DurationVar(&runWaitStable, "wait-stable", 0, "After locking, wait this long and re-probe; pick another port if it was taken (adds this much startup latency)")
+	runCmd.Flags().BoolVar(&runQuiet, "quiet", false, "Don't print the banner announcing the chosen port")
+	runCmd.Flags().BoolVar(&runPrintPort, "print-port", false, "Print only the port number on stderr instead of the banner")
+	runCmd.Flags().StringVar(&runBanner, "banner", "", "Banner template for stderr; {port} and {env} are replaced")
+	runCmd.Flags().StringArrayVar(&runLabels, "label", nil, "Tag the port lock, shown by fp locks (repeatable)")
+	runCmd.Flags().DurationVar(&runRestartWindow, "restart-window", time.Minute, "Sliding window for --max-restarts")
+}
+
+// runBannerLine is the structured banner run prints with --json.
+type runBannerLine struct {
+	Port int    `json:"port"`
+	Env  string `json:"env"`
+}
+
+func validateBannerFlags() error {
+	set := 0
+	for _, on := range []bool{runQuiet, runPrintPort, runBanner != ""} {
+		if on {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("--quiet, --print-port and --banner are mutually exclusive")
+	}
+	if jsonOutput && (runPrintPort || runBanner != "") {
+		return fmt.Errorf("--json can't be combined with --print-port or --banner")
+	}
+	return nil
+}
+
+// writeRunBanner announces the chosen port on w, which is stderr so the
+// child keeps stdout to itself. --json writes one JSON line, --print-port
+// just the number, and --banner a custom template.
+func writeRunBanner(w io.Writer, port int, env string) error {
+	switch {
+	case runQuiet:
+		return nil
+	case jsonOutput:
+		return writeJSONLine(w, runBannerLine{Port: port, Env: env})
+	case runPrintPort:
+		_, err := fmt.Fprintln(w, port)
+		return err
+	case runBanner != "":
+		_, err := fmt.Fprintln(w, strings.NewReplacer("{port}", strconv.Itoa(port), "{env}", env).Replace(runBanner))
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s using port %d\n", ui.Brand(ui.Stderr(), "fp:"), port)
+	return err
 }
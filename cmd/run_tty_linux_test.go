@@ -0,0 +1,100 @@
+//go:build linux
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY returns the master and slave ends of a new pseudo-terminal.
+func openPTY(t *testing.T) (*os.File, *os.File) {
+	t.Helper()
+	ptm, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("no pty: %v", err)
+	}
+	t.Cleanup(func() { ptm.Close() })
+	if err := unix.IoctlSetPointerInt(int(ptm.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatalf("unlock pty: %v", err)
+	}
+	n, err := unix.IoctlGetInt(int(ptm.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		t.Fatalf("pty number: %v", err)
+	}
+	pts, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("open pty slave: %v", err)
+	}
+	return ptm, pts
+}
+
+func TestRunChildReadsTerminal(t *testing.T) {
+	bin := buildCLI(t)
+	ptm, pts := openPTY(t)
+
+	// fp leads a session with the pty as its controlling terminal, as it
+	// would from an interactive shell; the child then reads that terminal.
+	c := exec.Command(bin, "run", "--quiet", "--", "/bin/sh", "-c", "read line; echo \"got:$line\"")
+	c.Stdin, c.Stdout, c.Stderr = pts, pts, pts
+	c.Env = append(os.Environ(), "TERM=dumb") // no color queries competing for the input
+	c.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	if err := c.Start(); err != nil {
+		t.Fatalf("start fp run: %v", err)
+	}
+	pts.Close()
+
+	var mu sync.Mutex
+	var out bytes.Buffer
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := ptm.Read(buf)
+			mu.Lock()
+			out.Write(buf[:n])
+			mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	if _, err := ptm.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("write to pty: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("fp run: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		c.Process.Kill()
+		mu.Lock()
+		defer mu.Unlock()
+		t.Fatalf("child reading the terminal never finished (stopped by SIGTTIN?); output %q", out.String())
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		got := out.String()
+		mu.Unlock()
+		if strings.Contains(got, "got:hello") {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the child to echo its input, got %q", got)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
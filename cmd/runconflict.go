@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/lock"
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
+)
+
+// run --on-conflict policies for a busy preferred port.
+const (
+	conflictFallback = "fallback"
+	conflictFail     = "fail"
+	conflictKill     = "kill"
+
+	// conflictReplace is run --replace: reclaim exactly the preferred port.
+	conflictReplace = "replace"
+)
+
+// conflictKillTimeout is how long --on-conflict kill waits after SIGTERM
+// before escalating to SIGKILL, and again after SIGKILL before giving up.
+const conflictKillTimeout = 2 * time.Second
+
+// conflictPollWait is how often --on-conflict kill checks the port.
+var conflictPollWait = 100 * time.Millisecond
+
+// pickRunPort picks and locks the port for run under an --on-conflict
+// policy. fallback searches the range when no preferred port is free; fail
+// refuses to; kill evicts whatever holds the first preferred port, subject
+// to kill's ownership and protected-user checks, and takes it; replace does
+// the same for the first preferred port only, without trying the others.
+func pickRunPort(policy string, prefer []int, r ports.Range, protected []string) (int, *lock.Handle, error) {
+	if policy == conflictFallback {
+		return lock.PickAndLockTCPPort(prefer, r)
+	}
+	if policy == conflictReplace && len(prefer) > 0 {
+		h, killed, err := reclaimPort(prefer[0], protected)
+		if err != nil {
+			return 0, nil, err
+		}
+		reportEvicted(prefer[0], killed)
+		return prefer[0], h, nil
+	}
+	if len(prefer) == 0 {
+		return 0, nil, fmt.Errorf("--on-conflict %s needs a --prefer port", policy)
+	}
+	var errs []error
+	for _, p := range prefer {
+		h, err := lock.LockTCPPort(p, 0)
+		if err == nil {
+			return p, h, nil
+		}
+		errs = append(errs, err)
+	}
+	if policy == conflictFail {
+		return 0, nil, fmt.Errorf("no preferred port is free (--on-conflict fail): %w", errors.Join(errs...))
+	}
+
+	port := prefer[0]
+	killed, err := evictPort(port, currentUsername(), protected, "--on-conflict kill")
+	if err != nil {
+		return 0, nil, err
+	}
+	reportEvicted(port, killed)
+	h, err := lock.LockTCPPort(port, 0)
+	if err != nil {
+		return 0, nil, err
+	}
+	return port, h, nil
+}
+
+// reclaimPort takes exactly port for run --replace: it locks the port if it
+// is free, and otherwise evicts the occupant under the same checks as
+// --on-conflict kill and then locks it. It never falls back to another port.
+// The evicted listeners are returned so the caller can report them.
+func reclaimPort(port int, protected []string) (*lock.Handle, []scan.Listener, error) {
+	if h, err := lock.LockTCPPort(port, 0); err == nil {
+		return h, nil, nil
+	}
+	killed, err := evictPort(port, currentUsername(), protected, "--replace")
+	if err != nil {
+		return nil, nil, fmt.Errorf("reclaim port %d: %w", port, err)
+	}
+	h, err := lock.LockTCPPort(port, 0)
+	if err != nil {
+		return nil, killed, fmt.Errorf("reclaim port %d: %w", port, err)
+	}
+	return h, killed, nil
+}
+
+// reportEvicted tells the user which processes were killed to free port.
+func reportEvicted(port int, killed []scan.Listener) {
+	for _, l := range killed {
+		fmt.Fprintf(ui.Stderr(), "%s killed pid %d (%s) to free port %d\n", ui.Brand(ui.Stderr(), "fp:"), l.PID, l.Command, port)
+	}
+}
+
+// evictPort signals every process listening on port with SIGTERM, then
+// SIGKILL, until the port frees, and returns the listeners it signaled.
+// Nothing is signaled if any target fails the ownership checks kill applies
+// without --force; the refusal names flag, the option that asked to evict.
+func evictPort(port int, current string, protected []string, flag string) ([]scan.Listener, error) {
+	ctx := context.Background()
+	listeners, err := listTCPListenersOnPort(ctx, port)
+	if err != nil {
+		return nil, err
+	}
+	var targets []scan.Listener
+	seen := make(map[int]bool)
+	for _, l := range listeners {
+		if l.Port != port || l.PID <= 0 || seen[l.PID] {
+			continue
+		}
+		seen[l.PID] = true
+		if checkKillSafety([]scan.Listener{l}, current, protected) != nil {
+			return nil, fmt.Errorf("port %d is held by pid %d (%s) owned by %q; %s only evicts your own unprotected processes", port, l.PID, l.Command, l.User, flag)
+		}
+		targets = append(targets, l)
+	}
+	if len(targets) == 0 {
+		// Nothing visible to signal (another fp lock, or a listener the scan
+		// can't attribute); let the lock attempt report why.
+		return nil, nil
+	}
+
+	for _, step := range signalPlan([]syscall.Signal{syscall.SIGTERM}, conflictKillTimeout) {
+		for _, t := range targets {
+			if err := signalProcess(t.PID, step.Signal); err != nil && !errors.Is(err, syscall.ESRCH) {
+				return nil, fmt.Errorf("signal pid %d: %w", t.PID, err)
+			}
+		}
+		wait := step.Wait
+		if wait == 0 {
+			wait = conflictKillTimeout
+		}
+		if waitPortFree(ctx, port, wait) {
+			return targets, nil
+		}
+	}
+	return nil, fmt.Errorf("port %d still busy after SIGKILL", port)
+}
+
+// stableRunAttempts bounds how many ports --wait-stable tries before
+// giving up.
+const stableRunAttempts = 5
+
+// stablePause waits out the --wait-stable window. Tests replace it.
+var stablePause = time.Sleep
+
+// pickStableRunPort is pickRunPort plus the --wait-stable double check: after
+// locking, it waits, re-probes the port, and picks again if something that
+// doesn't use fp's locks took it meanwhile. Rejected ports stay locked until
+// a port is settled on, so the next pick can't land on them again.
+func pickStableRunPort(policy string, prefer []int, r ports.Range, protected []string, wait time.Duration) (int, *lock.Handle, error) {
+	port, h, err := pickRunPort(policy, prefer, r, protected)
+	if err != nil || wait <= 0 {
+		return port, h, err
+	}
+	var rejected []*lock.Handle
+	defer func() {
+		for _, old := range rejected {
+			old.Close()
+		}
+	}()
+	for attempt := 1; ; attempt++ {
+		stablePause(wait)
+		if free, err := probeTCPPort(port); err == nil && free {
+			return port, h, nil
+		}
+		rejected = append(rejected, h)
+		if attempt == stableRunAttempts {
+			return 0, nil, fmt.Errorf("no port stayed free for %s after %d tries", wait, attempt)
+		}
+		fmt.Fprintf(ui.Stderr(), "%s port %d was taken during --wait-stable; picking another\n", ui.LabelWarn(ui.Stderr()), port)
+		if port, h, err = pickRunPort(policy, prefer, r, protected); err != nil {
+			return 0, nil, err
+		}
+	}
+}
+
+// waitPortFree polls until nothing listens on port or wait elapses.
+func waitPortFree(ctx context.Context, port int, wait time.Duration) bool {
+	deadline := time.Now().Add(wait)
+	for {
+		if busy, err := hasTCPListenerOnPort(ctx, port); err == nil && !busy {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(conflictPollWait)
+	}
+}
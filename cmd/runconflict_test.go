@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+// occupyPort listens on a loopback port for the test and returns it.
+func occupyPort(t *testing.T) (int, net.Listener) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().(*net.TCPAddr).Port, ln
+}
+
+// freePort returns a loopback port that was free a moment ago.
+func freePort(t *testing.T) int {
+	t.Helper()
+	port, ln := occupyPort(t)
+	ln.Close()
+	return port
+}
+
+// stubConflict isolates the lock directory and replaces the port scan,
+// liveness check and signaler: the fake listener on port is owned by owner
+// and goes away once it is sent any signal.
+func stubConflict(t *testing.T, port int, owner string, ln net.Listener) *atomic.Int32 {
+	t.Helper()
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	t.Setenv("HOME", cache)
+
+	var signals atomic.Int32
+	origScan, origHas, origKill := listTCPListenersOnPort, hasTCPListenerOnPort, signalProcess
+	listTCPListenersOnPort = func(context.Context, int) ([]scan.Listener, error) {
+		return []scan.Listener{{Port: port, PID: 4242, User: owner, Command: "node"}}, nil
+	}
+	hasTCPListenerOnPort = func(context.Context, int) (bool, error) {
+		return signals.Load() == 0, nil
+	}
+	signalProcess = func(pid int, sig syscall.Signal) error {
+		if pid != 4242 || sig != syscall.SIGTERM {
+			t.Errorf("unexpected signal %v to pid %d", sig, pid)
+		}
+		signals.Add(1)
+		ln.Close()
+		return nil
+	}
+	t.Cleanup(func() {
+		listTCPListenersOnPort, hasTCPListenerOnPort, signalProcess = origScan, origHas, origKill
+	})
+	return &signals
+}
+
+func TestPickRunPortOnConflict(t *testing.T) {
+	t.Run("fallback", func(t *testing.T) {
+		busy, ln := occupyPort(t)
+		signals := stubConflict(t, busy, currentUsername(), ln)
+		spare := freePort(t)
+		port, h, err := pickRunPort(conflictFallback, []int{busy}, ports.Range{Start: spare, End: spare}, nil)
+		if err != nil {
+			t.Fatalf("fallback: %v", err)
+		}
+		defer h.Close()
+		if port != spare || signals.Load() != 0 {
+			t.Fatalf("expected range port %d without signaling, got %d (%d signals)", spare, port, signals.Load())
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		busy, ln := occupyPort(t)
+		signals := stubConflict(t, busy, currentUsername(), ln)
+		spare := freePort(t)
+		_, _, err := pickRunPort(conflictFail, []int{busy}, ports.Range{Start: spare, End: spare}, nil)
+		if err == nil || !strings.Contains(err.Error(), "no preferred port is free") {
+			t.Fatalf("expected fail policy error, got %v", err)
+		}
+		if signals.Load() != 0 {
+			t.Fatalf("fail policy must not signal, sent %d", signals.Load())
+		}
+	})
+
+	t.Run("fail uses a later free preferred port", func(t *testing.T) {
+		busy, ln := occupyPort(t)
+		stubConflict(t, busy, currentUsername(), ln)
+		next := freePort(t)
+		port, h, err := pickRunPort(conflictFail, []int{busy, next}, ports.Range{Start: 1, End: 1}, nil)
+		if err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+		defer h.Close()
+		if port != next {
+			t.Fatalf("expected second preferred port %d, got %d", next, port)
+		}
+	})
+
+	t.Run("kill", func(t *testing.T) {
+		busy, ln := occupyPort(t)
+		signals := stubConflict(t, busy, currentUsername(), ln)
+		port, h, err := pickRunPort(conflictKill, []int{busy}, ports.Range{Start: 1, End: 1}, nil)
+		if err != nil {
+			t.Fatalf("kill: %v", err)
+		}
+		defer h.Close()
+		if port != busy || signals.Load() != 1 {
+			t.Fatalf("expected to evict and take port %d, got %d (%d signals)", busy, port, signals.Load())
+		}
+	})
+
+	t.Run("kill refuses other users", func(t *testing.T) {
+		busy, ln := occupyPort(t)
+		signals := stubConflict(t, busy, "someone-else", ln)
+		_, _, err := pickRunPort(conflictKill, []int{busy}, ports.Range{Start: 1, End: 1}, nil)
+		if err == nil || !strings.Contains(err.Error(), "someone-else") || !strings.Contains(err.Error(), "--on-conflict kill only evicts") {
+			t.Fatalf("expected ownership refusal, got %v", err)
+		}
+		if signals.Load() != 0 {
+			t.Fatalf("refused eviction must not signal, sent %d", signals.Load())
+		}
+	})
+
+	t.Run("kill refuses protected users", func(t *testing.T) {
+		busy, ln := occupyPort(t)
+		me := currentUsername()
+		signals := stubConflict(t, busy, me, ln)
+		_, _, err := pickRunPort(conflictKill, []int{busy}, ports.Range{Start: 1, End: 1}, []string{me})
+		if err == nil || signals.Load() != 0 {
+			t.Fatalf("expected protected-user refusal without signaling, got %v (%d signals)", err, signals.Load())
+		}
+	})
+}
+
+func TestReclaimPort(t *testing.T) {
+	t.Run("evicts the occupant and locks the exact port", func(t *testing.T) {
+		busy, ln := occupyPort(t)
+		signals := stubConflict(t, busy, currentUsername(), ln)
+		h, killed, err := reclaimPort(busy, nil)
+		if err != nil {
+			t.Fatalf("reclaim: %v", err)
+		}
+		defer h.Close()
+		if signals.Load() != 1 || len(killed) != 1 || killed[0].PID != 4242 {
+			t.Fatalf("expected pid 4242 killed once, got %+v (%d signals)", killed, signals.Load())
+		}
+	})
+
+	t.Run("free port is taken without signaling", func(t *testing.T) {
+		port := freePort(t)
+		signals := stubConflict(t, port, currentUsername(), nil)
+		h, killed, err := reclaimPort(port, nil)
+		if err != nil {
+			t.Fatalf("reclaim: %v", err)
+		}
+		defer h.Close()
+		if len(killed) != 0 || signals.Load() != 0 {
+			t.Fatalf("expected nothing killed, got %+v (%d signals)", killed, signals.Load())
+		}
+	})
+
+	t.Run("replace never falls back to another preferred port", func(t *testing.T) {
+		busy, ln := occupyPort(t)
+		signals := stubConflict(t, busy, "someone-else", ln)
+		next := freePort(t)
+		_, _, err := pickRunPort(conflictReplace, []int{busy, next}, ports.Range{Start: next, End: next}, nil)
+		if err == nil || !strings.Contains(err.Error(), "reclaim port") || !strings.Contains(err.Error(), "--replace only evicts") {
+			t.Fatalf("expected reclaim failure naming --replace, got %v", err)
+		}
+		if signals.Load() != 0 {
+			t.Fatalf("refused reclaim must not signal, sent %d", signals.Load())
+		}
+	})
+}
+
+func TestPickStableRunPortReselectsTakenPort(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	t.Setenv("HOME", cache)
+
+	first, second := freePort(t), freePort(t)
+	origProbe, origPause := probeTCPPort, stablePause
+	defer func() { probeTCPPort, stablePause = origProbe, origPause }()
+	pauses := 0
+	stablePause = func(time.Duration) { pauses++ }
+	// Something grabs the first port while fp waits out the window.
+	probeTCPPort = func(port int) (bool, error) { return port != first, nil }
+
+	port, h, err := pickStableRunPort(conflictFallback, []int{first}, ports.Range{Start: second, End: second}, nil, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("pickStableRunPort: %v", err)
+	}
+	defer h.Close()
+	if port != second || pauses != 2 {
+		t.Fatalf("expected reselection to %d after 2 windows, got %d after %d", second, port, pauses)
+	}
+
+	// Nothing stays free: give up after the attempt limit.
+	probeTCPPort = func(int) (bool, error) { return false, nil }
+	h.Close()
+	_, _, err = pickStableRunPort(conflictFallback, nil, ports.Range{Start: 20000, End: 21000}, nil, time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "after 5 tries") {
+		t.Fatalf("expected to give up after the attempt limit, got %v", err)
+	}
+}
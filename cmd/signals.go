@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// signalInfo describes one entry of supportedSignals for fp signals.
+type signalInfo struct {
+	Name        string `json:"name"`
+	Short       string `json:"short"`
+	Number      int    `json:"number"`
+	Description string `json:"description"`
+}
+
+func signalTable() []signalInfo {
+	out := make([]signalInfo, 0, len(supportedSignals))
+	for _, s := range supportedSignals {
+		out = append(out, signalInfo{
+			Name:        signalName(s.Signal),
+			Short:       s.Name,
+			Number:      int(s.Signal),
+			Description: s.Signal.String(),
+		})
+	}
+	return out
+}
+
+var signalsCmd = &cobra.Command{
+	Use:   "signals",
+	Short: "List the signals kill --signal accepts on this platform",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		table := signalTable()
+		if jsonOutput {
+			return writeJSON(os.Stdout, table)
+		}
+		out := ui.Stdout()
+		fmt.Fprintf(out, "%s\n", ui.Header(out, "NAME\tNUMBER\tDESCRIPTION"))
+		for _, s := range table {
+			fmt.Fprintf(out, "%s\t%d\t%s\n", ui.Emphasis(out, s.Short), s.Number, s.Description)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(signalsCmd)
+}
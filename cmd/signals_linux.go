@@ -0,0 +1,5 @@
+package cmd
+
+// maxSignal bounds numeric signals: Linux numbers them up to 64, the last of
+// its real-time signals.
+const maxSignal = 64
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package cmd
+
+// maxSignal bounds numeric signals: macOS and the BSDs define 1-31.
+const maxSignal = 31
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"encoding/json"
+	"syscall"
+	"testing"
+)
+
+func TestSignalTableListsCommonSignals(t *testing.T) {
+	table := signalTable()
+	byShort := make(map[string]signalInfo)
+	for _, s := range table {
+		byShort[s.Short] = s
+	}
+	for short, sig := range map[string]syscall.Signal{"TERM": syscall.SIGTERM, "INT": syscall.SIGINT, "KILL": syscall.SIGKILL, "HUP": syscall.SIGHUP} {
+		s, ok := byShort[short]
+		if !ok {
+			t.Fatalf("expected %s in %+v", short, table)
+		}
+		if s.Name != "SIG"+short || s.Number != int(sig) || s.Description == "" {
+			t.Fatalf("unexpected entry %+v", s)
+		}
+		if parsed, err := parseSignal(s.Name); err != nil || parsed != sig {
+			t.Fatalf("listed signal %s doesn't parse back: %v (err=%v)", s.Name, parsed, err)
+		}
+	}
+
+	data, err := json.Marshal(table)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	for _, entry := range decoded {
+		if len(entry) != 4 {
+			t.Fatalf("expected name, short, number and description, got %v", entry)
+		}
+		for _, key := range []string{"name", "short", "description"} {
+			if _, ok := entry[key].(string); !ok {
+				t.Fatalf("expected string %q in %v", key, entry)
+			}
+		}
+		if _, ok := entry["number"].(float64); !ok {
+			t.Fatalf("expected numeric number in %v", entry)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+// timeBoundLayouts are the absolute forms accepted by --started-after and
+// --started-before. Layouts without a zone are read in local time.
+var timeBoundLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTimeBound parses an absolute time, a clock time today ("09:30"), or
+// a duration relative to now ("2h" or "2h ago" both mean two hours before
+// now).
+func parseTimeBound(s string, now time.Time) (time.Time, error) {
+	v := strings.TrimSpace(s)
+	if d, err := time.ParseDuration(strings.TrimSpace(strings.TrimSuffix(v, "ago"))); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("invalid time %q: duration must not be negative", s)
+		}
+		return now.Add(-d), nil
+	}
+	for _, layout := range timeBoundLayouts {
+		if t, err := time.ParseInLocation(layout, v, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.ParseInLocation(layout, v, now.Location()); err == nil {
+			y, m, d := now.Date()
+			return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, now.Location()), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (expected RFC 3339, YYYY-MM-DD[ HH:MM[:SS]], HH:MM, or a duration like 2h)", s)
+}
+
+// filterStarted keeps listeners whose process started within the bounds;
+// a zero bound is open. Listeners with an unknown start time are dropped,
+// since they can't be shown to match.
+func filterStarted(listeners []scan.Listener, after, before time.Time) []scan.Listener {
+	filtered := listeners[:0]
+	for _, l := range listeners {
+		if l.Started.IsZero() {
+			continue
+		}
+		if !after.IsZero() && !l.Started.After(after) {
+			continue
+		}
+		if !before.IsZero() && !l.Started.Before(before) {
+			continue
+		}
+		filtered = append(filtered, l)
+	}
+	return filtered
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+func TestParseTimeBound(t *testing.T) {
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2026, time.October, 16, 12, 0, 0, 0, zone)
+	cases := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2h", now.Add(-2 * time.Hour)},
+		{"90m ago", now.Add(-90 * time.Minute)},
+		{"2026-10-16T08:00:00Z", time.Date(2026, time.October, 16, 10, 0, 0, 0, zone)},
+		{"2026-10-16 09:15", time.Date(2026, time.October, 16, 9, 15, 0, 0, zone)},
+		{"2026-10-15", time.Date(2026, time.October, 15, 0, 0, 0, 0, zone)},
+		{"09:30", time.Date(2026, time.October, 16, 9, 30, 0, 0, zone)},
+	}
+	for _, tc := range cases {
+		got, err := parseTimeBound(tc.in, now)
+		if err != nil {
+			t.Fatalf("parseTimeBound(%q): %v", tc.in, err)
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("parseTimeBound(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+
+	for _, bad := range []string{"", "yesterday", "-2h", "2026-13-01"} {
+		if _, err := parseTimeBound(bad, now); err == nil {
+			t.Errorf("parseTimeBound(%q): expected error", bad)
+		}
+	}
+}
+
+func TestFilterStarted(t *testing.T) {
+	base := time.Date(2026, time.October, 16, 12, 0, 0, 0, time.UTC)
+	listeners := func() []scan.Listener {
+		return []scan.Listener{
+			{Port: 3000, Started: base.Add(-3 * time.Hour)},
+			{Port: 3001, Started: base.Add(-time.Hour)},
+			{Port: 3002, Started: base},
+			{Port: 3003},
+		}
+	}
+	ports := func(ls []scan.Listener) []int {
+		var out []int
+		for _, l := range ls {
+			out = append(out, l.Port)
+		}
+		return out
+	}
+
+	if got := ports(filterStarted(listeners(), base.Add(-2*time.Hour), time.Time{})); !slices.Equal(got, []int{3001, 3002}) {
+		t.Fatalf("after only: %v", got)
+	}
+	if got := ports(filterStarted(listeners(), time.Time{}, base.Add(-30*time.Minute))); !slices.Equal(got, []int{3000, 3001}) {
+		t.Fatalf("before only: %v", got)
+	}
+	if got := ports(filterStarted(listeners(), base.Add(-2*time.Hour), base)); !slices.Equal(got, []int{3001}) {
+		t.Fatalf("both bounds (exclusive): %v", got)
+	}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// invocationArgs are the arguments fp is running with, after FREEPORT_ARGS
+// is applied. kill --sudo re-runs them, since sudo drops the environment.
+var invocationArgs []string
+
+// sudoRerun runs fp again under sudo with args, connected to this terminal,
+// and returns its exit code. Tests replace it.
+var sudoRerun = func(args []string) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 1, err
+	}
+	child := exec.Command("sudo", append([]string{"--", exe}, args...)...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	err = child.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, fmt.Errorf("sudo: %w", err)
+	}
+	return 0, nil
+}
+
+// withoutFlag drops every --name and --name=value from args, stopping at
+// "--" so the command after it is passed through untouched.
+func withoutFlag(args []string, name string) []string {
+	out := make([]string, 0, len(args))
+	for i, a := range args {
+		if a == "--" {
+			return append(out, args[i:]...)
+		}
+		if a == "--"+name || strings.HasPrefix(a, "--"+name+"=") {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// sudoCommandLine is args as a command line to paste after "sudo".
+func sudoCommandLine(args []string) string {
+	return "sudo " + filepath.Base(os.Args[0]) + " " + shellJoin(withoutFlag(args, "sudo"))
+}
+
+// killPermissionError explains an EPERM that got past checkKillSafety,
+// typically a setuid process or one whose owner ps couldn't report.
+func killPermissionError(pid int, command string, args []string) error {
+	return fmt.Errorf("permission denied signaling pid %d (%s); it may be setuid or owned by another user. Try: %s (or add --sudo)", pid, command, sudoCommandLine(args))
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
+	"github.com/muesli/termenv"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchTickInterval time.Duration
+	watchOnPort       int
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [filter]",
+	Short: "Redraw the listener table every interval, marking changes",
+	Long: `Redraw the listener table every --interval until Ctrl-C.
+
+Listeners that appeared since the previous refresh are marked "+", ones now
+held by a different process are marked "~", and the ones that went away are
+shown once more, marked "-". The filter argument matches like list's. With
+--json, each refresh is one JSON line:
+{"time", "listeners", "added", "removed", "changed"}.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var filter string
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		if watchOnPort < 0 || watchOnPort > 65535 {
+			return fmt.Errorf("invalid --port %d", watchOnPort)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return watchListeners(ctx, filter, watchOnPort, clampWatchInterval(watchTickInterval))
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchTickInterval, "interval", 2*time.Second, "Refresh interval")
+	watchCmd.Flags().IntVar(&watchOnPort, "port", 0, "Only this port")
+	rootCmd.AddCommand(watchCmd)
+}
+
+// minWatchInterval keeps --watch from hammering lsof/ss.
+const minWatchInterval = 250 * time.Millisecond
+
+// watchStats describes how a watch loop kept up with its interval.
+type watchStats struct {
+	Scans    int
+	Skipped  int
+	LastScan time.Duration
+}
+
+// EffectiveInterval is the average time between scan starts, accounting for
+// ticks skipped while a slow scan was still running.
+func (s watchStats) EffectiveInterval(interval time.Duration) time.Duration {
+	if s.Scans == 0 {
+		return interval
+	}
+	return interval * time.Duration(s.Scans+s.Skipped) / time.Duration(s.Scans)
+}
+
+// watchLoop runs fn on every interval tick until ctx is done. Scans never
+// overlap: ticks that fire while fn is still running are skipped rather than
+// queued, and counted in the stats passed to the next call.
+func watchLoop(ctx context.Context, interval time.Duration, fn func(context.Context, watchStats) error) error {
+	var stats watchStats
+	next := time.Now()
+	for {
+		start := time.Now()
+		if err := fn(ctx, stats); err != nil {
+			return err
+		}
+		stats.Scans++
+		stats.LastScan = time.Since(start)
+
+		next = next.Add(interval)
+		for !next.After(time.Now()) {
+			next = next.Add(interval)
+			stats.Skipped++
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+func clampWatchInterval(interval time.Duration) time.Duration {
+	if interval < minWatchInterval {
+		fmt.Fprintf(ui.Stderr(), "%s interval %s too short; using %s\n", ui.LabelWarn(ui.Stderr()), interval, minWatchInterval)
+		return minWatchInterval
+	}
+	return interval
+}
+
+func watchList(ctx context.Context, filter string, hook *changeHook) error {
+	interval := clampWatchInterval(listInterval)
+	return watchLoop(ctx, interval, func(ctx context.Context, stats watchStats) error {
+		listeners, backends, err := collectListeners(ctx, filter, listPort)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		// The hook runs after rendering so the screen clear doesn't hide
+		// its output.
+		defer observeChange(hook, listeners)
+		if jsonOutput {
+			return writeJSONLine(os.Stdout, listeners)
+		}
+		if !ui.Plain() {
+			ui.Stdout().ClearScreen()
+		}
+		if err := renderListeners(listeners, backends); err != nil {
+			return err
+		}
+		footer := fmt.Sprintf("every %s (effective %s, last scan %s, %d skipped)",
+			interval, stats.EffectiveInterval(interval).Round(time.Millisecond),
+			stats.LastScan.Round(time.Millisecond), stats.Skipped)
+		fmt.Fprintf(ui.Stdout(), "\n%s\n", ui.Muted(ui.Stdout(), footer))
+		return nil
+	})
+}
+
+// watchTick is one refresh of fp watch in JSON mode.
+type watchTick struct {
+	Time      time.Time        `json:"time"`
+	Listeners []scan.Listener  `json:"listeners"`
+	Added     []scan.Listener  `json:"added"`
+	Removed   []scan.Listener  `json:"removed"`
+	Changed   []listenerChange `json:"changed"`
+}
+
+// watchListeners is fp watch: list --watch with changes since the previous
+// refresh marked, keeping only port when it is non-zero. The first refresh
+// has nothing to compare with.
+func watchListeners(ctx context.Context, filter string, port int, interval time.Duration) error {
+	var prev []scan.Listener
+	return watchLoop(ctx, interval, func(ctx context.Context, stats watchStats) error {
+		listeners, _, err := collectListeners(ctx, filter, port)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		d := listenerDiff{Added: []scan.Listener{}, Removed: []scan.Listener{}, Changed: []listenerChange{}}
+		if stats.Scans > 0 {
+			d = diffListeners(prev, listeners)
+		}
+		prev = listeners
+		if jsonOutput {
+			if listeners == nil {
+				listeners = []scan.Listener{}
+			}
+			return writeJSONLine(os.Stdout, watchTick{Time: time.Now(), Listeners: listeners, Added: d.Added, Removed: d.Removed, Changed: d.Changed})
+		}
+		out := ui.Stdout()
+		if !ui.Plain() {
+			out.ClearScreen()
+		}
+		writeWatchTable(out, listeners, d)
+		footer := fmt.Sprintf("every %s, %s; +%d ~%d -%d", interval, time.Now().Format(time.TimeOnly), len(d.Added), len(d.Changed), len(d.Removed))
+		fmt.Fprintf(out, "\n%s\n", ui.Muted(out, footer))
+		return nil
+	})
+}
+
+// writeWatchTable is list's table with a leading change marker: "+" for
+// listeners in d.Added, "~" for sockets in d.Changed, and the d.Removed ones
+// appended with "-".
+func writeWatchTable(out *termenv.Output, listeners []scan.Listener, d listenerDiff) {
+	added := make(map[string]bool, len(d.Added))
+	for _, l := range d.Added {
+		added[l.Key()] = true
+	}
+	changed := make(map[string]bool, len(d.Changed))
+	for _, c := range d.Changed {
+		changed[c.Key] = true
+	}
+	row := func(mark string, l scan.Listener) {
+		port := ui.PortClass(out, portLabel(l), ports.Classify(l.Port))
+		fmt.Fprintf(out, "%s %s\t%d\t%s\t%s\t%s\n", mark, port, l.PID, l.User, ui.Emphasis(out, l.Command), l.Address)
+	}
+	fmt.Fprintf(out, "%s\n", ui.Header(out, "  PORT\tPID\tUSER\tCOMMAND\tADDR"))
+	for _, l := range listeners {
+		mark := " "
+		switch {
+		case added[l.Key()]:
+			mark = ui.Success(out, "+")
+		case changed[l.Key()]:
+			mark = ui.Warning(out, "~")
+		}
+		row(mark, l)
+	}
+	for _, l := range d.Removed {
+		fmt.Fprintf(out, "%s %s\n", ui.Warning(out, "-"), ui.Muted(out, fmt.Sprintf("%s\t%d\t%s\t%s\t%s", portLabel(l), l.PID, l.User, l.Command, l.Address)))
+	}
+}
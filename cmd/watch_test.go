@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/muesli/termenv"
+)
+
+func TestWatchLoopNeverOverlapsSlowScans(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+
+	var running, maxRunning int32
+	var last watchStats
+	err := watchLoop(ctx, 10*time.Millisecond, func(ctx context.Context, stats watchStats) error {
+		n := atomic.AddInt32(&running, 1)
+		if n > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, n)
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		last = stats
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("watchLoop: %v", err)
+	}
+
+	if maxRunning != 1 {
+		t.Fatalf("expected scans to never overlap, saw %d concurrent", maxRunning)
+	}
+	if last.Scans == 0 || last.Skipped == 0 {
+		t.Fatalf("expected skipped ticks with a slow scan, got %+v", last)
+	}
+	if got := last.EffectiveInterval(10 * time.Millisecond); got < 40*time.Millisecond {
+		t.Fatalf("expected effective interval to reflect slow scans, got %s", got)
+	}
+}
+
+func TestClampWatchInterval(t *testing.T) {
+	if got := clampWatchInterval(10 * time.Millisecond); got != minWatchInterval {
+		t.Fatalf("expected clamp to %s, got %s", minWatchInterval, got)
+	}
+	if got := clampWatchInterval(time.Second); got != time.Second {
+		t.Fatalf("expected 1s unchanged, got %s", got)
+	}
+}
+
+func TestWriteWatchTableMarksChanges(t *testing.T) {
+	before := []scan.Listener{
+		{Port: 3000, PID: 1, Command: "node", Address: "127.0.0.1:3000"},
+		{Port: 5432, PID: 2, Command: "postgres", Address: "127.0.0.1:5432"},
+		{Port: 6379, PID: 4, Command: "redis", Address: "127.0.0.1:6379"},
+	}
+	after := []scan.Listener{
+		{Port: 3000, PID: 1, Command: "node", Address: "127.0.0.1:3000"},
+		{Port: 6379, PID: 5, Command: "redis", Address: "127.0.0.1:6379"},
+		{Port: 8080, PID: 3, Command: "python", Address: "127.0.0.1:8080"},
+	}
+
+	var buf bytes.Buffer
+	writeWatchTable(termenv.NewOutput(&buf, termenv.WithProfile(termenv.Ascii)), after, diffListeners(before, after))
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	want := []string{
+		"  PORT\tPID\tUSER\tCOMMAND\tADDR",
+		"  3000\t1\t\tnode\t127.0.0.1:3000",
+		"~ 6379\t5\t\tredis\t127.0.0.1:6379",
+		"+ 8080\t3\t\tpython\t127.0.0.1:8080",
+		"- 5432\t2\t\tpostgres\t127.0.0.1:5432",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected table:\n%s\nwant:\n%s", buf.String(), strings.Join(want, "\n"))
+	}
+}
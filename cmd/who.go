@@ -3,11 +3,19 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
-	"fp/internal/scan"
-	"fp/internal/ui"
+	"github.com/mtreilly/freeport/internal/ports"
+	"github.com/mtreilly/freeport/internal/scan"
+	"github.com/mtreilly/freeport/internal/ui"
 	"github.com/spf13/cobra"
 )
 
@@ -16,63 +24,514 @@ var whoCmd = &cobra.Command{
 	Short: "Show what is listening on a port",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		port, err := strconv.Atoi(args[0])
-		if err != nil || port < 1 || port > 65535 {
-			return fmt.Errorf("invalid port: %q", args[0])
+		port, err := parsePortArg(args[0])
+		if err != nil {
+			return err
 		}
 
-		listeners, err := scan.ListTCPListeners(context.Background())
-		if err != nil {
+		if err := validateProto(whoProto); err != nil {
 			return err
 		}
+		if whoProto != "tcp" && (whoProbe || whoRelated || whoWatch) {
+			return fmt.Errorf("--proto %s can't be combined with --probe, --related or --watch", whoProto)
+		}
 
-		var matches []scan.Listener
-		for _, l := range listeners {
-			if l.Port == port {
-				matches = append(matches, l)
-			}
+		if whoCheck && (whoProbe || whoRelated || whoWatch) {
+			return fmt.Errorf("--check can't be combined with --probe, --related or --watch")
 		}
 
-		scan.EnrichListenersWithProcessInfo(context.Background(), matches)
+		if whoProbe {
+			return probeWho(port)
+		}
 
-		if jsonOutput {
-			return scan.WriteJSON(os.Stdout, matches)
+		if whoMem && (whoRelated || whoWatch) {
+			return fmt.Errorf("--mem can't be combined with --related or --watch")
 		}
 
-		if len(matches) == 0 {
-			fmt.Fprintf(ui.Stdout(), "port %d: %s (no TCP listeners found)\n", port, ui.Success(ui.Stdout(), "free"))
-			return nil
+		if whoRelated {
+			if whoFast || whoWatch {
+				return fmt.Errorf("--related can't be combined with --fast or --watch")
+			}
+			return relatedWho(port)
 		}
 
-		suffix := "listeners"
-		if len(matches) == 1 {
-			suffix = "listener"
+		if whoWatch {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return watchPort(ctx, port)
 		}
-		fmt.Fprintf(ui.Stdout(), "%s %s\n", ui.Header(ui.Stdout(), fmt.Sprintf("port %d", port)), ui.Muted(ui.Stdout(), fmt.Sprintf("(%d %s)", len(matches), suffix)))
-		for _, m := range matches {
-			fmt.Fprintf(ui.Stdout(), "  %s %d\n", ui.Info(ui.Stdout(), "pid:"), m.PID)
-			if m.PPID > 0 {
-				fmt.Fprintf(ui.Stdout(), "  %s %d\n", ui.Info(ui.Stdout(), "ppid:"), m.PPID)
-			}
-			if m.User != "" {
-				fmt.Fprintf(ui.Stdout(), "  %s %s\n", ui.Info(ui.Stdout(), "user:"), m.User)
+
+		// --fast skips the full-scan fallback and all enrichment, leaving
+		// just what the scoped lsof/ss query reports.
+		query := listTCPListenersOnPort
+		if whoFast {
+			query = queryTCPPort
+		}
+		matches, err := listenersForProto(context.Background(), whoProto, func(ctx context.Context) ([]scan.Listener, error) {
+			return query(ctx, port)
+		})
+		if err != nil {
+			return err
+		}
+		if whoProto != "tcp" {
+			// UDP has no port-scoped query; keep the full scan's sockets on port.
+			matches = slices.DeleteFunc(matches, func(l scan.Listener) bool { return l.Port != port })
+		}
+
+		if !whoFast {
+			enrichProcessInfo(context.Background(), matches)
+		}
+		if whoResolveExe && !whoFast {
+			scan.ResolveExecutables(matches)
+		}
+		if whoResolve && !whoFast {
+			scan.ResolveHostnames(context.Background(), net.DefaultResolver, matches, resolveTimeout)
+		}
+		if whoMineJobs && len(matches) > 0 {
+			if err := markShellJobs(context.Background(), matches, os.Getppid()); err != nil {
+				fmt.Fprintf(ui.Stderr(), "%s can't check for shell jobs: %v\n", ui.LabelWarn(ui.Stderr()), err)
 			}
-			if m.Command != "" {
-				fmt.Fprintf(ui.Stdout(), "  %s %s\n", ui.Info(ui.Stdout(), "cmd:"), ui.Emphasis(ui.Stdout(), m.Command))
+		}
+		if whoMem && len(matches) > 0 {
+			if err := scan.AddSocketMem(context.Background(), port, matches); err != nil {
+				fmt.Fprintf(ui.Stderr(), "%s socket memory unavailable: %v\n", ui.LabelWarn(ui.Stderr()), err)
 			}
-			if m.CommandLine != "" {
-				fmt.Fprintf(ui.Stdout(), "  %s %q\n", ui.Info(ui.Stdout(), "args:"), m.CommandLine)
+		}
+
+		if err := writeWhoResult(port, matches); err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			if code := notFoundExitCode(whoCheck, whoExitZero); code != 0 {
+				exit(code)
 			}
-			if m.Executable != "" {
-				fmt.Fprintf(ui.Stdout(), "  %s %q\n", ui.Info(ui.Stdout(), "exe:"), m.Executable)
+		}
+		return nil
+	},
+}
+
+// notFoundExitCode is the exit status for a port with nothing on it: 1 under
+// --check, else 0. --exit-zero-on-not-found wins over everything, for
+// scripts that treat an idle port as success whatever else they pass.
+func notFoundExitCode(check, exitZero bool) int {
+	if check && !exitZero {
+		return 1
+	}
+	return 0
+}
+
+// writeWhoResult prints who's listeners on port in the selected format.
+func writeWhoResult(port int, matches []scan.Listener) error {
+	if whoJSONL {
+		return writeListenersJSONL(os.Stdout, matches)
+	}
+	if jsonOutput {
+		if whoSummary {
+			return writeJSON(os.Stdout, map[string]any{
+				"listeners": matches,
+				"summary":   summarizeListeners(matches),
+			})
+		}
+		return writeJSON(os.Stdout, matches)
+	}
+
+	if len(matches) == 0 {
+		fmt.Fprintf(ui.Stdout(), "port %d: %s (%s)\n", port, ui.Success(ui.Stdout(), "free"), noListenersText(whoProto))
+		return nil
+	}
+
+	suffix := "listeners"
+	if len(matches) == 1 {
+		suffix = "listener"
+	}
+	fmt.Fprintf(ui.Stdout(), "%s %s\n", ui.Header(ui.Stdout(), fmt.Sprintf("port %d", port)), ui.Muted(ui.Stdout(), fmt.Sprintf("(%d %s)", len(matches), suffix)))
+	for _, m := range matches {
+		fmt.Fprintf(ui.Stdout(), "  %s %d\n", ui.Info(ui.Stdout(), "pid:"), m.PID)
+		if whoProto != "tcp" {
+			fmt.Fprintf(ui.Stdout(), "  %s %s\n", ui.Info(ui.Stdout(), "proto:"), m.Proto)
+		}
+		if m.PPID > 0 {
+			fmt.Fprintf(ui.Stdout(), "  %s %d\n", ui.Info(ui.Stdout(), "ppid:"), m.PPID)
+		}
+		if m.User != "" {
+			fmt.Fprintf(ui.Stdout(), "  %s %s\n", ui.Info(ui.Stdout(), "user:"), m.User)
+		}
+		if m.Command != "" {
+			fmt.Fprintf(ui.Stdout(), "  %s %s\n", ui.Info(ui.Stdout(), "cmd:"), ui.Emphasis(ui.Stdout(), m.Command))
+		}
+		if m.CommandLine != "" {
+			fmt.Fprintf(ui.Stdout(), "  %s %q\n", ui.Info(ui.Stdout(), "args:"), m.CommandLine)
+		}
+		if m.Executable != "" {
+			exe := fmt.Sprintf("%q", m.Executable)
+			if m.ExecutableDeleted {
+				exe += " " + ui.Warning(ui.Stdout(), "(deleted: running a binary that has since been replaced or removed; restart it)")
 			}
-			if m.CWD != "" {
-				fmt.Fprintf(ui.Stdout(), "  %s %q\n", ui.Info(ui.Stdout(), "cwd:"), m.CWD)
+			fmt.Fprintf(ui.Stdout(), "  %s %s\n", ui.Info(ui.Stdout(), "exe:"), exe)
+		}
+		if m.CWD != "" {
+			fmt.Fprintf(ui.Stdout(), "  %s %q\n", ui.Info(ui.Stdout(), "cwd:"), m.CWD)
+		}
+		if m.Address != "" {
+			fmt.Fprintf(ui.Stdout(), "  %s %s\n", ui.Info(ui.Stdout(), "addr:"), m.Address)
+		}
+		if m.Forwarding != "" {
+			fmt.Fprintf(ui.Stdout(), "  %s %s %s\n", ui.Info(ui.Stdout(), "forwarding:"), ui.Symbols().Arrow, m.Forwarding)
+		}
+		if m.Hostname != "" {
+			fmt.Fprintf(ui.Stdout(), "  %s %s\n", ui.Info(ui.Stdout(), "host:"), m.Hostname)
+		}
+		if m.ShellJob {
+			fmt.Fprintf(ui.Stdout(), "  %s %s\n", ui.Info(ui.Stdout(), "job:"), ui.Emphasis(ui.Stdout(), "your background process"))
+		}
+		if whoMem {
+			fmt.Fprintf(ui.Stdout(), "  %s %s\n", ui.Info(ui.Stdout(), "mem:"), formatSocketMem(m.Mem))
+		}
+	}
+	if whoSummary {
+		fmt.Fprintf(ui.Stdout(), "%s\n", ui.Muted(ui.Stdout(), summarizeListeners(matches).String(time.Now())))
+	}
+	return nil
+}
+
+var (
+	whoJSONL      bool
+	whoResolve    bool
+	whoWatch      bool
+	whoInterval   time.Duration
+	whoProbe      bool
+	whoFast       bool
+	whoRelated    bool
+	whoSummary    bool
+	whoMem        bool
+	whoMineJobs   bool
+	whoProto      string
+	whoCheck      bool
+	whoResolveExe bool
+	whoExitZero   bool
+)
+
+func init() {
+	whoCmd.Flags().BoolVar(&whoJSONL, "jsonl", false, "Output one compact JSON object per listener")
+	whoCmd.Flags().BoolVar(&whoResolve, "resolve", false, "Reverse-resolve the bind address to a hostname")
+	whoCmd.Flags().BoolVar(&whoWatch, "watch", false, "Print a line each time the port's occupant changes")
+	whoCmd.Flags().BoolVar(&whoProbe, "probe", false, "Classify the port by bind/connect only, without lsof/ss (no pid/command)")
+	whoCmd.Flags().BoolVar(&whoFast, "fast", false, "Query only this port and skip process enrichment (lower latency, fewer details)")
+	whoCmd.Flags().BoolVar(&whoRelated, "related", false, "Also show every other port held by the process(es) on this port")
+	whoCmd.Flags().BoolVar(&whoSummary, "summary", false, "Finish with a line of totals: processes, users, commands, uptime range")
+	whoCmd.Flags().BoolVar(&whoMem, "mem", false, "Show socket buffer sizes and memory use (needs ss)")
+	whoCmd.Flags().BoolVar(&whoMineJobs, "mine-jobs", false, "Mark listeners started from the shell that ran fp (its background jobs)")
+	whoCmd.Flags().StringVar(&whoProto, "proto", "tcp", "Protocol: "+strings.Join(protoChoices, ", ")+" (UDP shows bound, unconnected sockets)")
+	whoCmd.Flags().BoolVar(&whoResolveExe, "resolve-exe", false, "Show the executable's canonical path with symlinks resolved")
+	whoCmd.Flags().BoolVar(&whoCheck, "check", false, "Exit 1 when nothing is listening on the port")
+	whoCmd.Flags().BoolVar(&whoExitZero, "exit-zero-on-not-found", false, "Always exit 0 when nothing is listening, even with --check (lenient scripting)")
+	whoCmd.Flags().DurationVar(&whoInterval, "interval", time.Second, "Poll interval for --watch")
+}
+
+// processParents is the PID-to-parent map used by --mine-jobs. Tests
+// replace it.
+var processParents = scan.ProcessParents
+
+// markShellJobs sets ShellJob on listeners descended from shell. The shell's
+// job table isn't readable from outside it, so being a descendant of the
+// shell that launched fp stands in for being one of its jobs.
+func markShellJobs(ctx context.Context, listeners []scan.Listener, shell int) error {
+	parents, err := processParents(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range listeners {
+		listeners[i].ShellJob = listeners[i].PID > 0 && scan.IsDescendant(listeners[i].PID, shell, parents)
+	}
+	return nil
+}
+
+// formatSocketMem renders the buffer sizes and queue use for who --mem.
+func formatSocketMem(mem *scan.SocketMem) string {
+	if mem == nil {
+		return "unknown (ss -m not available)"
+	}
+	return fmt.Sprintf("rcvbuf %d, sndbuf %d, rmem %d, wmem %d, backlog %d, drops %d",
+		mem.RcvBuf, mem.SndBuf, mem.RmemAlloc, mem.WmemAlloc, mem.Backlog, mem.Drops)
+}
+
+// listenerSummary aggregates who's matches for --summary. Oldest and Newest
+// are process start times and are zero when none are known.
+type listenerSummary struct {
+	Processes int       `json:"processes"`
+	Users     []string  `json:"users"`
+	Commands  []string  `json:"commands"`
+	Oldest    time.Time `json:"oldest_started,omitzero"`
+	Newest    time.Time `json:"newest_started,omitzero"`
+}
+
+func summarizeListeners(listeners []scan.Listener) listenerSummary {
+	s := listenerSummary{Users: []string{}, Commands: []string{}}
+	pids := make(map[int]bool)
+	for _, l := range listeners {
+		if l.PID > 0 && !pids[l.PID] {
+			pids[l.PID] = true
+			s.Processes++
+		}
+		if l.User != "" && !slices.Contains(s.Users, l.User) {
+			s.Users = append(s.Users, l.User)
+		}
+		if l.Command != "" && !slices.Contains(s.Commands, l.Command) {
+			s.Commands = append(s.Commands, l.Command)
+		}
+		if l.Started.IsZero() {
+			continue
+		}
+		if s.Oldest.IsZero() || l.Started.Before(s.Oldest) {
+			s.Oldest = l.Started
+		}
+		if s.Newest.IsZero() || l.Started.After(s.Newest) {
+			s.Newest = l.Started
+		}
+	}
+	slices.Sort(s.Users)
+	slices.Sort(s.Commands)
+	return s
+}
+
+// String renders the summary as one line, e.g.
+// "3 processes, 2 users, 1 command, up 5m-2h".
+func (s listenerSummary) String(now time.Time) string {
+	count := func(n int, one, many string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, one)
+		}
+		return fmt.Sprintf("%d %s", n, many)
+	}
+	line := strings.Join([]string{
+		count(s.Processes, "process", "processes"),
+		count(len(s.Users), "user", "users"),
+		count(len(s.Commands), "command", "commands"),
+	}, ", ")
+	if !s.Oldest.IsZero() {
+		oldest, newest := formatAge(now.Sub(s.Oldest)), formatAge(now.Sub(s.Newest))
+		if oldest == newest {
+			line += ", up " + oldest
+		} else {
+			line += ", up " + newest + "-" + oldest
+		}
+	}
+	return line
+}
+
+// formatAge renders d at the coarsest useful unit: "45s", "12m", "3h5m",
+// "2d4h".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		h, m := int(d.Hours()), int(d.Minutes())%60
+		if m == 0 {
+			return fmt.Sprintf("%dh", h)
+		}
+		return fmt.Sprintf("%dh%dm", h, m)
+	default:
+		days, h := int(d.Hours())/24, int(d.Hours())%24
+		if h == 0 {
+			return fmt.Sprintf("%dd", days)
+		}
+		return fmt.Sprintf("%dd%dh", days, h)
+	}
+}
+
+// pidPorts is one process and every port it listens on, for who --related.
+type pidPorts struct {
+	PID     int    `json:"pid"`
+	User    string `json:"user,omitempty"`
+	Command string `json:"command,omitempty"`
+	Ports   []int  `json:"ports"`
+}
+
+// relatedPorts indexes listeners by PID and returns, for each process on
+// port, all the ports it listens on, sorted.
+func relatedPorts(listeners []scan.Listener, port int) []pidPorts {
+	byPID := make(map[int]*pidPorts)
+	var order []int
+	for _, l := range listeners {
+		if l.PID <= 0 {
+			continue
+		}
+		g := byPID[l.PID]
+		if g == nil {
+			g = &pidPorts{PID: l.PID, User: l.User, Command: l.Command}
+			byPID[l.PID] = g
+		}
+		if !slices.Contains(g.Ports, l.Port) {
+			g.Ports = append(g.Ports, l.Port)
+		}
+		if l.Port == port && !slices.Contains(order, l.PID) {
+			order = append(order, l.PID)
+		}
+	}
+	slices.Sort(order)
+	groups := make([]pidPorts, 0, len(order))
+	for _, pid := range order {
+		g := byPID[pid]
+		slices.Sort(g.Ports)
+		groups = append(groups, *g)
+	}
+	return groups
+}
+
+// relatedWho prints the full port footprint of whatever holds port. It
+// needs a full scan, since the other ports are by definition not this one.
+func relatedWho(port int) error {
+	listeners, err := listTCPListeners(context.Background())
+	if err != nil {
+		return err
+	}
+	groups := relatedPorts(listeners, port)
+	if whoJSONL {
+		for _, g := range groups {
+			if err := writeJSONLine(os.Stdout, g); err != nil {
+				return err
 			}
-			if m.Address != "" {
-				fmt.Fprintf(ui.Stdout(), "  %s %s\n", ui.Info(ui.Stdout(), "addr:"), m.Address)
+		}
+		return nil
+	}
+	if jsonOutput {
+		return writeJSON(os.Stdout, groups)
+	}
+
+	out := ui.Stdout()
+	if len(groups) == 0 {
+		fmt.Fprintf(out, "port %d: %s (no TCP listeners found)\n", port, ui.Success(out, "free"))
+		return nil
+	}
+	for _, g := range groups {
+		fmt.Fprintf(out, "%s", ui.Header(out, fmt.Sprintf("pid %d", g.PID)))
+		if g.Command != "" {
+			fmt.Fprintf(out, " %s", ui.Emphasis(out, g.Command))
+		}
+		if g.User != "" {
+			fmt.Fprintf(out, " %s", ui.Muted(out, "("+g.User+")"))
+		}
+		fmt.Fprintln(out)
+		list := make([]string, len(g.Ports))
+		for i, p := range g.Ports {
+			list[i] = strconv.Itoa(p)
+		}
+		fmt.Fprintf(out, "  %s %s\n", ui.Info(out, "ports:"), strings.Join(list, ", "))
+	}
+	return nil
+}
+
+// probeWho is the minimal-dependency who: it works anywhere but can't say
+// which process holds the port.
+func probeWho(port int) error {
+	status, err := probeStatus(port)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return writeJSON(os.Stdout, map[string]any{
+			"port":   port,
+			"status": status,
+		})
+	}
+	styled := ui.Warning(ui.Stdout(), status)
+	if status == ports.StatusFree {
+		styled = ui.Success(ui.Stdout(), status)
+	}
+	fmt.Fprintf(ui.Stdout(), "port %d: %s\n", port, styled)
+	return nil
+}
+
+func writeListenersJSONL(w io.Writer, listeners []scan.Listener) error {
+	for _, l := range listeners {
+		if err := writeJSONLine(w, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// occupantEvent is emitted by who --watch when a port's listener set changes.
+type occupantEvent struct {
+	Time      time.Time       `json:"time"`
+	Port      int             `json:"port"`
+	Status    string          `json:"status"` // free, taken, or changed
+	Listeners []scan.Listener `json:"listeners"`
+}
+
+// portWatcher polls one port and reports transitions between polls.
+type portWatcher struct {
+	port    int
+	now     func() time.Time
+	polled  bool
+	last    string
+	lastLen int
+}
+
+// Poll scans once and returns an event if the occupant differs from the
+// previous poll. The first poll always reports the initial state.
+func (w *portWatcher) Poll(ctx context.Context) (occupantEvent, bool, error) {
+	listeners, err := listTCPListeners(ctx)
+	if err != nil {
+		return occupantEvent{}, false, err
+	}
+	matches := []scan.Listener{}
+	for _, l := range listeners {
+		if l.Port == w.port {
+			matches = append(matches, l)
+		}
+	}
+
+	sig := owners(matches)
+	if w.polled && sig == w.last {
+		return occupantEvent{}, false, nil
+	}
+
+	status := "changed"
+	switch {
+	case len(matches) == 0:
+		status = "free"
+	case !w.polled || w.lastLen == 0:
+		status = "taken"
+	}
+	w.polled, w.last, w.lastLen = true, sig, len(matches)
+	return occupantEvent{Time: w.now(), Port: w.port, Status: status, Listeners: matches}, true, nil
+}
+
+func watchPort(ctx context.Context, port int) error {
+	w := &portWatcher{port: port, now: time.Now}
+	return watchLoop(ctx, clampWatchInterval(whoInterval), func(ctx context.Context, _ watchStats) error {
+		ev, changed, err := w.Poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
 			}
+			return err
 		}
+		if !changed {
+			return nil
+		}
+		if jsonOutput {
+			return writeJSONLine(os.Stdout, ev)
+		}
+		printOccupantEvent(ev)
 		return nil
-	},
+	})
+}
+
+func printOccupantEvent(ev occupantEvent) {
+	out := ui.Stdout()
+	stamp := ui.Muted(out, ev.Time.Format(time.RFC3339))
+	if ev.Status == "free" {
+		fmt.Fprintf(out, "%s port %d: %s\n", stamp, ev.Port, ui.Success(out, "free"))
+		return
+	}
+	var holders []string
+	for _, l := range ev.Listeners {
+		holders = append(holders, fmt.Sprintf("%s (pid %d)", l.Command, l.PID))
+	}
+	fmt.Fprintf(out, "%s port %d: %s by %s\n", stamp, ev.Port, ui.Warning(out, ev.Status), strings.Join(holders, ", "))
 }
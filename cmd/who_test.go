@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+func TestWriteListenersJSONLIsCompact(t *testing.T) {
+	listeners := []scan.Listener{
+		{Port: 3000, PID: 10, Command: "node", Proto: "tcp", Address: "*:3000"},
+		{Port: 3000, PID: 11, Command: "node", Proto: "tcp", Address: "[::1]:3000"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeListenersJSONL(&buf, listeners); err != nil {
+		t.Fatalf("writeListenersJSONL: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		if strings.Contains(line, "  ") || strings.Contains(line, "\t") {
+			t.Fatalf("expected compact line, got %q", line)
+		}
+		var got scan.Listener
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line is not valid JSON: %q: %v", line, err)
+		}
+		if got.Port != 3000 {
+			t.Fatalf("expected port field on each line, got %+v", got)
+		}
+	}
+}
+
+func TestPortWatcherEmitsOnlyOnTransitions(t *testing.T) {
+	node := scan.Listener{Port: 3000, PID: 10, Command: "node"}
+	python := scan.Listener{Port: 3000, PID: 20, Command: "python"}
+	other := scan.Listener{Port: 4000, PID: 30, Command: "redis"}
+	polls := [][]scan.Listener{
+		{other},
+		{other, node},
+		{node, other},
+		{python},
+		{python},
+		{},
+	}
+	i := 0
+	stubListeners(t, func() []scan.Listener {
+		ls := polls[i]
+		i++
+		return ls
+	})
+
+	w := &portWatcher{port: 3000, now: time.Now}
+	var statuses []string
+	for range polls {
+		ev, changed, err := w.Poll(context.Background())
+		if err != nil {
+			t.Fatalf("Poll: %v", err)
+		}
+		if changed {
+			statuses = append(statuses, ev.Status)
+		}
+	}
+
+	want := []string{"free", "taken", "changed", "free"}
+	if !slices.Equal(statuses, want) {
+		t.Fatalf("expected transitions %v, got %v", want, statuses)
+	}
+}
+
+// stubListeners replaces the TCP scanner with next, and the UDP scanner with
+// an empty one, for the duration of the test.
+func stubListeners(t *testing.T, next func() []scan.Listener) {
+	t.Helper()
+	orig, origUDP := listTCPListeners, listUDPListeners
+	listTCPListeners = func(context.Context) ([]scan.Listener, error) {
+		return next(), nil
+	}
+	listUDPListeners = func(context.Context) ([]scan.Listener, error) { return nil, nil }
+	t.Cleanup(func() { listTCPListeners, listUDPListeners = orig, origUDP })
+}
+
+func TestRelatedPortsGroupsByPID(t *testing.T) {
+	listeners := []scan.Listener{
+		{Port: 9229, PID: 42, Command: "node", User: "dev"},
+		{Port: 3000, PID: 42, Command: "node", User: "dev", Address: "127.0.0.1:3000"},
+		{Port: 3000, PID: 42, Command: "node", User: "dev", Address: "[::1]:3000"},
+		{Port: 3001, PID: 42, Command: "node", User: "dev"},
+		{Port: 5432, PID: 7, Command: "postgres"},
+		{Port: 3000, PID: 0},
+	}
+
+	groups := relatedPorts(listeners, 3001)
+	if len(groups) != 1 {
+		t.Fatalf("expected one process, got %+v", groups)
+	}
+	g := groups[0]
+	if g.PID != 42 || g.Command != "node" || g.User != "dev" || !slices.Equal(g.Ports, []int{3000, 3001, 9229}) {
+		t.Fatalf("unexpected group %+v", g)
+	}
+
+	data, err := json.Marshal(groups)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if want := `[{"pid":42,"user":"dev","command":"node","ports":[3000,3001,9229]}]`; string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+
+	if groups := relatedPorts(listeners, 8080); len(groups) != 0 {
+		t.Fatalf("expected no groups for a free port, got %+v", groups)
+	}
+}
+
+func TestSummarizeListenersCountsMultiProcessPort(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	listeners := []scan.Listener{
+		{Port: 3000, PID: 10, User: "dev", Command: "node", Started: now.Add(-2 * time.Hour)},
+		{Port: 3000, PID: 10, User: "dev", Command: "node", Started: now.Add(-2 * time.Hour)},
+		{Port: 3000, PID: 11, User: "dev", Command: "node", Started: now.Add(-5 * time.Minute)},
+		{Port: 3000, PID: 12, User: "root", Command: "nginx", Started: now.Add(-30 * time.Minute)},
+		{Port: 3000, PID: 0},
+	}
+
+	s := summarizeListeners(listeners)
+	if s.Processes != 3 || !slices.Equal(s.Users, []string{"dev", "root"}) || !slices.Equal(s.Commands, []string{"nginx", "node"}) {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+	if !s.Oldest.Equal(now.Add(-2*time.Hour)) || !s.Newest.Equal(now.Add(-5*time.Minute)) {
+		t.Fatalf("unexpected uptime range %v..%v", s.Oldest, s.Newest)
+	}
+	if got, want := s.String(now), "3 processes, 2 users, 2 commands, up 5m-2h"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	single := summarizeListeners(listeners[:1])
+	if got, want := single.String(now), "1 process, 1 user, 1 command, up 2h"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if got, want := summarizeListeners([]scan.Listener{{Port: 3000, PID: 7}}).String(now), "1 process, 0 users, 0 commands"; got != want {
+		t.Fatalf("expected %q without start times, got %q", want, got)
+	}
+}
+
+func TestFormatSocketMem(t *testing.T) {
+	got := formatSocketMem(&scan.SocketMem{RcvBuf: 131072, SndBuf: 16384, Drops: 2})
+	want := "rcvbuf 131072, sndbuf 16384, rmem 0, wmem 0, backlog 0, drops 2"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := formatSocketMem(nil); !strings.Contains(got, "unknown") {
+		t.Fatalf("expected unknown for missing skmem, got %q", got)
+	}
+}
+
+func TestMarkShellJobs(t *testing.T) {
+	orig := processParents
+	defer func() { processParents = orig }()
+	// shell 500 ran npm (510), which started node (520); 600 is unrelated.
+	processParents = func(context.Context) (map[int]int, error) {
+		return map[int]int{500: 1, 510: 500, 520: 510, 600: 1}, nil
+	}
+
+	listeners := []scan.Listener{{Port: 3000, PID: 520}, {Port: 3001, PID: 600}, {Port: 3002}}
+	if err := markShellJobs(context.Background(), listeners, 500); err != nil {
+		t.Fatalf("markShellJobs: %v", err)
+	}
+	if !listeners[0].ShellJob || listeners[1].ShellJob || listeners[2].ShellJob {
+		t.Fatalf("expected only pid 520 marked, got %+v", listeners)
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathEnv overrides the config file location.
+const PathEnv = "FREEPORT_CONFIG"
+
+// Config is fp's settings file: "key = value" lines, optionally grouped
+// under "[section]" headers. Lines starting with # are comments.
+type Config struct {
+	sections map[string]map[string]string
+}
+
+// Path returns the config file location, $FREEPORT_CONFIG or
+// <user config dir>/fp/config.
+func Path() (string, error) {
+	if p := os.Getenv(PathEnv); p != "" {
+		return p, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "fp", "config"), nil
+}
+
+// Load reads the config file. A missing file is an empty config.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return &Config{}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	c, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse reads config from r.
+func Parse(r io.Reader) (*Config, error) {
+	c := &Config{sections: map[string]map[string]string{}}
+	section := ""
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("line %d: empty key", lineNo)
+		}
+		if c.sections[section] == nil {
+			c.sections[section] = map[string]string{}
+		}
+		c.sections[section][key] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get returns a top-level value, or "" if unset.
+func (c *Config) Get(key string) string {
+	return c.Section("")[key]
+}
+
+// List returns a comma-separated top-level value with blanks dropped.
+func (c *Config) List(key string) []string {
+	var out []string
+	for _, v := range strings.Split(c.Get(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Section returns the keys under [name]; "" is the top level.
+func (c *Config) Section(name string) map[string]string {
+	if c == nil || c.sections == nil {
+		return nil
+	}
+	return c.sections[name]
+}
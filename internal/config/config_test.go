@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	c, err := Parse(strings.NewReader(`
+# fp settings
+protect_users = root, postgres,
+
+[aliases]
+web = 3000
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := c.List("protect_users"); !reflect.DeepEqual(got, []string{"root", "postgres"}) {
+		t.Fatalf("unexpected protect_users: %v", got)
+	}
+	if got := c.Section("aliases")["web"]; got != "3000" {
+		t.Fatalf("unexpected alias: %q", got)
+	}
+	if got := c.Get("web"); got != "" {
+		t.Fatalf("section keys leaked to top level: %q", got)
+	}
+}
+
+func TestParseRejectsBareLine(t *testing.T) {
+	if _, err := Parse(strings.NewReader("protect_users\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expected line error, got %v", err)
+	}
+}
+
+func TestLoadMissingFileIsEmpty(t *testing.T) {
+	t.Setenv(PathEnv, filepath.Join(t.TempDir(), "config"))
+	c, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if c.Get("protect_users") != "" || c.List("protect_users") != nil {
+		t.Fatalf("expected empty config")
+	}
+}
+
+func TestLoadFromEnvPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("protect_users = root\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(PathEnv, path)
+	c, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := c.List("protect_users"); !reflect.DeepEqual(got, []string{"root"}) {
+		t.Fatalf("unexpected protect_users: %v", got)
+	}
+}
+
+func TestParseRegistry(t *testing.T) {
+	r, err := ParseRegistry(strings.NewReader(`
+# ports for this repo
+web = 3000 node
+db  = 5432 postgres
+api = 4000
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []RegistryEntry{
+		{Name: "web", Port: 3000, Command: "node"},
+		{Name: "api", Port: 4000},
+		{Name: "db", Port: 5432, Command: "postgres"},
+	}
+	if !reflect.DeepEqual(r.Entries, want) {
+		t.Fatalf("unexpected entries: %+v", r.Entries)
+	}
+	if e, ok := r.Lookup("api"); !ok || e.Port != 4000 {
+		t.Fatalf("lookup api = %+v, %v", e, ok)
+	}
+	if _, ok := r.Lookup("cache"); ok {
+		t.Fatalf("lookup of unknown name succeeded")
+	}
+}
+
+func TestParseRegistryErrors(t *testing.T) {
+	for in, want := range map[string]string{
+		"web =\n":                  "missing port",
+		"web = http\n":             `invalid port "http"`,
+		"web = 70000\n":            "invalid port",
+		"web = 3000\napi = 3000\n": "api and web both claim port 3000",
+	} {
+		if _, err := ParseRegistry(strings.NewReader(in)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("ParseRegistry(%q): expected %q, got %v", in, want, err)
+		}
+	}
+}
+
+func TestFindRegistryWalksUp(t *testing.T) {
+	root := t.TempDir()
+	deep := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := FindRegistry(deep); err != nil || path != "" {
+		t.Fatalf("expected no registry, got %q, %v", path, err)
+	}
+	want := filepath.Join(root, RegistryFile)
+	if err := os.WriteFile(want, []byte("web = 3000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := FindRegistry(deep); err != nil || path != want {
+		t.Fatalf("FindRegistry = %q, %v; want %q", path, err, want)
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RegistryFile is the project port registry's name. It is looked for in the
+// working directory and each parent, so it can live at the repository root.
+const RegistryFile = "freeport.ports"
+
+// RegistryEntry assigns a service name a fixed port. Command, if set, is
+// what is expected to hold the port: a listener whose command, executable
+// or command line doesn't contain it is a conflict.
+type RegistryEntry struct {
+	Name    string `json:"name"`
+	Port    int    `json:"port"`
+	Command string `json:"command,omitempty"`
+}
+
+// Registry is a project's declared ports, in config file syntax with one
+// "name = port [command]" line per service:
+//
+//	web = 3000 node
+//	api = 4000
+//	db  = 5432 postgres
+type Registry struct {
+	Path    string
+	Entries []RegistryEntry // sorted by port
+}
+
+// Lookup returns the entry for name.
+func (r *Registry) Lookup(name string) (RegistryEntry, bool) {
+	if r == nil {
+		return RegistryEntry{}, false
+	}
+	for _, e := range r.Entries {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return RegistryEntry{}, false
+}
+
+// FindRegistry returns the nearest freeport.ports at or above dir, or "" if
+// there is none.
+func FindRegistry(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, RegistryFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadRegistry reads the nearest registry above the working directory. No
+// registry is an empty one with no Path.
+func LoadRegistry() (*Registry, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return &Registry{}, nil
+	}
+	path, err := FindRegistry(wd)
+	if err != nil || path == "" {
+		return &Registry{}, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := ParseRegistry(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	r.Path = path
+	return r, nil
+}
+
+// ParseRegistry reads registry entries from r. Sections aren't used; two
+// names claiming one port is an error, since that's always a mistake.
+func ParseRegistry(r io.Reader) (*Registry, error) {
+	c, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	reg := &Registry{}
+	owner := map[int]string{}
+	for name, value := range c.Section("") {
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%s: missing port", name)
+		}
+		port, err := strconv.Atoi(fields[0])
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("%s: invalid port %q", name, fields[0])
+		}
+		if other, dup := owner[port]; dup {
+			a, b := min(name, other), max(name, other)
+			return nil, fmt.Errorf("%s and %s both claim port %d", a, b, port)
+		}
+		owner[port] = name
+		reg.Entries = append(reg.Entries, RegistryEntry{Name: name, Port: port, Command: strings.Join(fields[1:], " ")})
+	}
+	sort.Slice(reg.Entries, func(i, j int) bool { return reg.Entries[i].Port < reg.Entries[j].Port })
+	return reg, nil
+}
@@ -1,25 +1,124 @@
 package lock
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
 
-	"fp/internal/ports"
+	"github.com/mtreilly/freeport/internal/ports"
 	"golang.org/x/sys/unix"
 )
 
 type Handle struct {
-	f *os.File
+	f    *os.File
+	port int
+	info Info
 }
 
+// Close releases the lock and removes its file, so the lock directory
+// doesn't fill up with one file per port ever used. The file is removed
+// while still locked; tryLockPortFile rechecks the path after locking, so a
+// process that opened the old file in between retries on a fresh one.
 func (h *Handle) Close() error {
 	if h == nil || h.f == nil {
 		return nil
 	}
+	_ = os.Remove(h.f.Name())
 	_ = unix.Flock(int(h.f.Fd()), unix.LOCK_UN)
-	return h.f.Close()
+	err := h.f.Close()
+	h.f = nil
+	return err
+}
+
+// Inherit keeps the lock across exec: the descriptor stays open in the new
+// process image, so the flock is held for as long as that process runs.
+func (h *Handle) Inherit() error {
+	_, err := unix.FcntlInt(h.f.Fd(), unix.F_SETFD, 0)
+	return err
+}
+
+// Port returns the port this handle holds.
+func (h *Handle) Port() int {
+	return h.port
+}
+
+// Info is the metadata fp writes into a lock file while holding it.
+type Info struct {
+	Port    int       `json:"port"`
+	PID     int       `json:"pid"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires,omitzero"`
+	Labels  []string  `json:"labels,omitempty"`
+	// Detached marks a lock held by a background "pick --hold" process,
+	// which "fp release" may signal.
+	Detached bool `json:"detached,omitempty"`
+}
+
+// MaxLabelLen caps each label, in characters, so a shared lock directory
+// can't be filled with arbitrary blobs.
+const MaxLabelLen = 64
+
+// ValidateLabel checks that label is non-empty UTF-8 of at most
+// MaxLabelLen characters.
+func ValidateLabel(label string) error {
+	switch {
+	case label == "":
+		return errors.New("label must not be empty")
+	case !utf8.ValidString(label):
+		return fmt.Errorf("label %q is not valid UTF-8", label)
+	case utf8.RuneCountInString(label) > MaxLabelLen:
+		return fmt.Errorf("label %q is longer than %d characters", label, MaxLabelLen)
+	}
+	return nil
+}
+
+// Expired reports whether the reservation has a TTL that has passed.
+func (i Info) Expired(now time.Time) bool {
+	return !i.Expires.IsZero() && now.After(i.Expires)
+}
+
+// WriteInfo replaces the lock file contents with info.
+func (h *Handle) WriteInfo(info Info) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := h.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := h.f.WriteAt(append(data, '\n'), 0); err != nil {
+		return err
+	}
+	h.info = info
+	return nil
+}
+
+// SetLabels records labels in the lock file alongside its other metadata.
+func (h *Handle) SetLabels(labels []string) error {
+	for _, l := range labels {
+		if err := ValidateLabel(l); err != nil {
+			return err
+		}
+	}
+	info := h.info
+	info.Labels = labels
+	return h.WriteInfo(info)
+}
+
+// SetDetached marks the lock as held by a background hold process.
+func (h *Handle) SetDetached() error {
+	info := h.info
+	info.Detached = true
+	return h.WriteInfo(info)
 }
 
 func PickAndLockTCPPort(prefer []int, r ports.Range) (int, *Handle, error) {
@@ -37,6 +136,7 @@ func PickAndLockTCPPort(prefer []int, r ports.Range) (int, *Handle, error) {
 			_ = h.Close()
 			return 0, nil, false
 		}
+		_ = h.WriteInfo(Info{Port: p, PID: os.Getpid(), Created: time.Now()})
 		return p, h, true
 	}
 
@@ -56,6 +156,322 @@ func PickAndLockTCPPort(prefer []int, r ports.Range) (int, *Handle, error) {
 	return 0, nil, fmt.Errorf("no free TCP port found in %d-%d", r.Start, r.End)
 }
 
+// Reasons LockTCPPort can't take a port.
+var (
+	ErrReserved = errors.New("already reserved")
+	ErrInUse    = errors.New("in use")
+)
+
+// LockTCPPort locks a specific port, failing if another fp process holds it
+// or something is already listening on it. A non-zero ttl is recorded as the
+// reservation's expiry.
+func LockTCPPort(port int, ttl time.Duration) (*Handle, error) {
+	if port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", port)
+	}
+	dir, err := lockDir()
+	if err != nil {
+		return nil, err
+	}
+	return lockPortIn(dir, port, ttl)
+}
+
+func lockPortIn(dir string, port int, ttl time.Duration) (*Handle, error) {
+	h, err := tryLockPortFile(dir, port)
+	if err != nil {
+		return nil, fmt.Errorf("port %d is %w", port, ErrReserved)
+	}
+	if !portsPickProbe(port) {
+		_ = h.Close()
+		return nil, fmt.Errorf("port %d is %w", port, ErrInUse)
+	}
+	now := time.Now()
+	info := Info{Port: port, PID: os.Getpid(), Created: now}
+	if ttl > 0 {
+		info.Expires = now.Add(ttl)
+	}
+	if err := h.WriteInfo(info); err != nil {
+		_ = h.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// Reservation is a held port as recorded by Export.
+type Reservation struct {
+	Port    int       `json:"port"`
+	Expires time.Time `json:"expires,omitzero"`
+	Labels  []string  `json:"labels,omitempty"`
+}
+
+// Conflict is a reservation Import couldn't re-acquire.
+type Conflict struct {
+	Port   int    `json:"port"`
+	Reason string `json:"reason"`
+}
+
+// Export returns the live reservations in the lock directory: held locks
+// that haven't outlived their TTL.
+func Export() ([]Reservation, error) {
+	dir, err := lockDir()
+	if err != nil {
+		return nil, err
+	}
+	return exportFrom(dir, time.Now())
+}
+
+func exportFrom(dir string, now time.Time) ([]Reservation, error) {
+	entries, err := listEntries(dir, now)
+	if err != nil {
+		return nil, err
+	}
+	reservations := []Reservation{}
+	for _, e := range entries {
+		if e.Status() != "held" {
+			continue
+		}
+		reservations = append(reservations, Reservation{Port: e.Port, Expires: e.Info.Expires, Labels: e.Info.Labels})
+	}
+	return reservations, nil
+}
+
+// Import re-acquires each reservation, keeping its remaining TTL. Locks only
+// last as long as the returned handles stay open, so the caller must hold
+// them. Reservations that can't be taken, or have already expired, are
+// returned as conflicts; the rest are still acquired.
+func Import(reservations []Reservation) ([]*Handle, []Conflict, error) {
+	dir, err := lockDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	handles, conflicts := importInto(dir, reservations, time.Now())
+	return handles, conflicts, nil
+}
+
+func importInto(dir string, reservations []Reservation, now time.Time) ([]*Handle, []Conflict) {
+	var handles []*Handle
+	var conflicts []Conflict
+	for _, r := range reservations {
+		if r.Port < 1 || r.Port > 65535 {
+			conflicts = append(conflicts, Conflict{Port: r.Port, Reason: "invalid port"})
+			continue
+		}
+		var ttl time.Duration
+		if !r.Expires.IsZero() {
+			ttl = r.Expires.Sub(now)
+			if ttl <= 0 {
+				conflicts = append(conflicts, Conflict{Port: r.Port, Reason: "expired"})
+				continue
+			}
+		}
+		h, err := lockPortIn(dir, r.Port, ttl)
+		if err != nil {
+			reason := err.Error()
+			for _, known := range []error{ErrReserved, ErrInUse} {
+				if errors.Is(err, known) {
+					reason = known.Error()
+				}
+			}
+			conflicts = append(conflicts, Conflict{Port: r.Port, Reason: reason})
+			continue
+		}
+		if len(r.Labels) > 0 {
+			if err := h.SetLabels(r.Labels); err != nil {
+				_ = h.Close()
+				conflicts = append(conflicts, Conflict{Port: r.Port, Reason: err.Error()})
+				continue
+			}
+		}
+		handles = append(handles, h)
+	}
+	return handles, conflicts
+}
+
+// Entry describes one lock file.
+type Entry struct {
+	Port    int    `json:"port"`
+	Path    string `json:"path"`
+	Held    bool   `json:"held"`
+	Expired bool   `json:"expired"`
+	Info    Info   `json:"info"`
+}
+
+// Status is "held", "expired" (held past its TTL), or "stale" (not held).
+func (e Entry) Status() string {
+	switch {
+	case e.Held && e.Expired:
+		return "expired"
+	case e.Held:
+		return "held"
+	}
+	return "stale"
+}
+
+// Releasable reports whether gc may clean the entry up: either nobody holds
+// the lock, or the reservation outlived its TTL, whatever PID still has it.
+func (e Entry) Releasable() bool {
+	return !e.Held || e.Expired
+}
+
+// List returns every lock file in the lock directory, sorted by port.
+func List() ([]Entry, error) {
+	dir, err := lockDir()
+	if err != nil {
+		return nil, err
+	}
+	return listEntries(dir, time.Now())
+}
+
+// Lookup returns the lock file entry for port, if there is one.
+func Lookup(port int) (Entry, bool, error) {
+	entries, err := List()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	for _, e := range entries {
+		if e.Port == port {
+			return e, true, nil
+		}
+	}
+	return Entry{}, false, nil
+}
+
+// ErrNotHeld means there is no live lock to release.
+var ErrNotHeld = errors.New("not held")
+
+// Release asks the process holding port's lock to exit (SIGTERM) and waits
+// up to timeout for the lock to be dropped. Only detached holds are released
+// unless force is set, so a running "fp run" isn't stopped by accident. It
+// returns the PID that was signaled.
+func Release(port int, force bool, timeout time.Duration) (int, error) {
+	e, ok, err := Lookup(port)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("port %d is %w", port, ErrNotHeld)
+	}
+	return releaseEntry(e, force, timeout, syscall.Kill)
+}
+
+func releaseEntry(e Entry, force bool, timeout time.Duration, kill func(int, syscall.Signal) error) (int, error) {
+	if !e.Held {
+		return 0, fmt.Errorf("port %d is %w", e.Port, ErrNotHeld)
+	}
+	pid := e.Info.PID
+	if pid <= 0 {
+		return 0, fmt.Errorf("port %d: lock file has no holder pid", e.Port)
+	}
+	if !e.Info.Detached && !force {
+		return 0, fmt.Errorf("port %d is locked by pid %d, not by pick --hold (use --force to signal it anyway)", e.Port, pid)
+	}
+	if err := kill(pid, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return 0, fmt.Errorf("signal pid %d: %w", pid, err)
+	}
+	deadline := time.Now().Add(timeout)
+	for isHeld(e.Path) {
+		if time.Now().After(deadline) {
+			return pid, fmt.Errorf("port %d: pid %d still holds the lock after %s", e.Port, pid, timeout)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return pid, nil
+}
+
+// Sweep removes stale lock files and asks holders of expired reservations to
+// exit. It returns the entries it acted on.
+func Sweep() ([]Entry, error) {
+	entries, err := List()
+	if err != nil {
+		return nil, err
+	}
+	return sweepEntries(entries, syscall.Kill), nil
+}
+
+func sweepEntries(entries []Entry, kill func(int, syscall.Signal) error) []Entry {
+	var swept []Entry
+	for _, e := range entries {
+		if !e.Releasable() {
+			continue
+		}
+		if e.Held {
+			if e.Info.PID <= 0 || e.Info.PID == os.Getpid() {
+				continue
+			}
+			if err := kill(e.Info.PID, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+				continue
+			}
+		} else if !removeUnheld(e.Path) {
+			continue
+		}
+		swept = append(swept, e)
+	}
+	return swept
+}
+
+func listEntries(dir string, now time.Time) ([]Entry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".lock") {
+			continue
+		}
+		port, err := strconv.Atoi(strings.TrimSuffix(name, ".lock"))
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		e := Entry{Port: port, Path: path, Held: isHeld(path)}
+		if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+			_ = json.Unmarshal(data, &e.Info)
+		}
+		e.Expired = e.Info.Expired(now)
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Port < entries[j].Port })
+	return entries, nil
+}
+
+// removeUnheld removes the lock file at path if nobody holds it, reporting
+// whether it did. Like Handle.Close, it removes the file while holding the
+// lock itself, so a process that opened it meanwhile finds it gone once it
+// gets the lock and retries on a fresh file, instead of holding an orphan
+// alongside whoever locks the new one.
+func removeUnheld(path string) bool {
+	f, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		return false
+	}
+	defer unix.Flock(int(f.Fd()), unix.LOCK_UN)
+	if !sameFile(f, path) {
+		return false
+	}
+	return os.Remove(path) == nil
+}
+
+// isHeld tries a non-blocking flock on a fresh descriptor; failure means
+// some process (possibly this one) holds the lock.
+func isHeld(path string) bool {
+	f, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		return true
+	}
+	_ = unix.Flock(int(f.Fd()), unix.LOCK_UN)
+	return false
+}
+
 func lockDir() (string, error) {
 	base, err := os.UserCacheDir()
 	if err != nil || base == "" {
@@ -68,17 +484,36 @@ func lockDir() (string, error) {
 	return dir, nil
 }
 
+// tryLockPortFile takes port's lock file without blocking. A file can be
+// removed between open and flock, by Close or Sweep; locking that orphan
+// would not exclude anyone, so it retries until the locked file is still
+// the one at path.
 func tryLockPortFile(dir string, port int) (*Handle, error) {
 	path := filepath.Join(dir, fmt.Sprintf("%d.lock", port))
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
-	if err != nil {
-		return nil, err
-	}
-	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if sameFile(f, path) {
+			return &Handle{f: f, port: port}, nil
+		}
 		_ = f.Close()
-		return nil, err
 	}
-	return &Handle{f: f}, nil
+}
+
+// sameFile reports whether f is still the file at path.
+func sameFile(f *os.File, path string) bool {
+	held, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	return err == nil && os.SameFile(held, current)
 }
 
 // Duplicate of ports.probeTCP but kept local so PickAndLock can remain race-minimizing:
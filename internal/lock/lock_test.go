@@ -0,0 +1,402 @@
+package lock
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestListEntriesDetectsExpiry(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	writeLockFile(t, dir, 3000, `{"port":3000,"pid":11,"created":"2026-01-01T10:00:00Z","expires":"2026-01-01T11:00:00Z"}`)
+	writeLockFile(t, dir, 3001, `{"port":3001,"pid":12,"created":"2026-01-01T11:30:00Z","expires":"2026-01-01T13:00:00Z"}`)
+	writeLockFile(t, dir, 3002, `{"port":3002,"pid":13,"created":"2026-01-01T11:30:00Z"}`)
+	writeLockFile(t, dir, 3003, ``)
+
+	// Hold the expired and the unexpired reservations like a live process would.
+	for _, port := range []int{3000, 3001} {
+		h, err := tryLockPortFile(dir, port)
+		if err != nil {
+			t.Fatalf("lock %d: %v", port, err)
+		}
+		defer h.Close()
+	}
+
+	entries, err := listEntries(dir, now)
+	if err != nil {
+		t.Fatalf("listEntries: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %+v", entries)
+	}
+
+	want := []struct {
+		status     string
+		releasable bool
+	}{
+		{"expired", true}, // held by a stale PID but past its TTL
+		{"held", false},
+		{"stale", true},
+		{"stale", true},
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Status() != w.status || e.Releasable() != w.releasable {
+			t.Errorf("port %d: expected %s/releasable=%v, got %s/releasable=%v", e.Port, w.status, w.releasable, e.Status(), e.Releasable())
+		}
+	}
+	if entries[0].Info.PID != 11 {
+		t.Fatalf("expected pid from lock file, got %d", entries[0].Info.PID)
+	}
+}
+
+func TestInfoExpired(t *testing.T) {
+	now := time.Now()
+	if (Info{}).Expired(now) {
+		t.Fatalf("expected reservation without TTL to never expire")
+	}
+	if !(Info{Expires: now.Add(-time.Second)}).Expired(now) {
+		t.Fatalf("expected past expiry to be expired")
+	}
+	if (Info{Expires: now.Add(time.Second)}).Expired(now) {
+		t.Fatalf("expected future expiry to be live")
+	}
+}
+
+func TestSweepEntries(t *testing.T) {
+	dir := t.TempDir()
+	stale := writeLockFile(t, dir, 4000, ``)
+	entries := []Entry{
+		{Port: 4000, Path: stale},
+		{Port: 4001, Held: true, Expired: true, Info: Info{PID: 999999}},
+		{Port: 4002, Held: true, Info: Info{PID: 999998}},
+	}
+
+	var signaled []int
+	swept := sweepEntries(entries, func(pid int, sig syscall.Signal) error {
+		signaled = append(signaled, pid)
+		return nil
+	})
+
+	if len(swept) != 2 {
+		t.Fatalf("expected 2 swept entries, got %+v", swept)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected stale lock file to be removed")
+	}
+	if len(signaled) != 1 || signaled[0] != 999999 {
+		t.Fatalf("expected only the expired holder to be signaled, got %v", signaled)
+	}
+}
+
+func TestSweepNeverLeavesTwoHolders(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "3000.lock")
+
+	// A holder that locks between List and the sweep keeps its file.
+	h, err := tryLockPortFile(dir, 3000)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if swept := sweepEntries([]Entry{{Port: 3000, Path: path}}, nil); len(swept) != 0 {
+		t.Fatalf("expected a held lock to survive the sweep, swept %+v", swept)
+	}
+	if !sameFile(h.f, path) {
+		t.Fatalf("expected the holder's file to still be at %s", path)
+	}
+	h.Close()
+
+	// Sweeps racing lockers: at no point may two of them hold port 3000.
+	var holders, peak atomic.Int32
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				h, err := tryLockPortFile(dir, 3000)
+				if err != nil {
+					continue
+				}
+				n := holders.Add(1)
+				for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+				}
+				time.Sleep(50 * time.Microsecond)
+				holders.Add(-1)
+				h.Close()
+			}
+		}()
+	}
+	for range 2000 {
+		writeLockFile(t, dir, 3000, ``)
+		sweepEntries([]Entry{{Port: 3000, Path: path}}, nil)
+	}
+	close(stop)
+	wg.Wait()
+	if peak.Load() > 1 {
+		t.Fatalf("sweep let %d processes hold port 3000 at once", peak.Load())
+	}
+}
+
+func TestCloseRemovesLockFile(t *testing.T) {
+	dir := t.TempDir()
+	h, err := tryLockPortFile(dir, 3000)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	path := filepath.Join(dir, "3000.lock")
+
+	// A second opener that got the file before Close sees it vanish once it
+	// has the lock, and must not treat the orphan as a lock.
+	orphan, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer orphan.Close()
+
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected lock file to be removed on Close, stat err=%v", err)
+	}
+	if sameFile(orphan, path) {
+		t.Fatalf("expected removed file not to match its path")
+	}
+
+	h, err = tryLockPortFile(dir, 3000)
+	if err != nil {
+		t.Fatalf("relock: %v", err)
+	}
+	defer h.Close()
+	if !sameFile(h.f, path) || !isHeld(path) {
+		t.Fatalf("expected the relock to hold a fresh file at %s", path)
+	}
+}
+
+func writeLockFile(t *testing.T, dir string, port int, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, strconv.Itoa(port)+".lock")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write lock file: %v", err)
+	}
+	return path
+}
+
+func TestHandleInheritClearsCloseOnExec(t *testing.T) {
+	h, err := tryLockPortFile(t.TempDir(), 3000)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer h.Close()
+
+	flags, err := unix.FcntlInt(h.f.Fd(), unix.F_GETFD, 0)
+	if err != nil || flags&unix.FD_CLOEXEC == 0 {
+		t.Fatalf("expected close-on-exec before Inherit (flags=%d err=%v)", flags, err)
+	}
+	if err := h.Inherit(); err != nil {
+		t.Fatalf("Inherit: %v", err)
+	}
+	flags, err = unix.FcntlInt(h.f.Fd(), unix.F_GETFD, 0)
+	if err != nil || flags&unix.FD_CLOEXEC != 0 {
+		t.Fatalf("expected close-on-exec cleared (flags=%d err=%v)", flags, err)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	p1, p2 := freePort(t), freePort(t)
+
+	h1, err := lockPortIn(src, p1, time.Hour)
+	if err != nil {
+		t.Fatalf("lock %d: %v", p1, err)
+	}
+	defer h1.Close()
+	h2, err := lockPortIn(src, p2, 0)
+	if err != nil {
+		t.Fatalf("lock %d: %v", p2, err)
+	}
+	defer h2.Close()
+
+	exported, err := exportFrom(src, time.Now())
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if len(exported) != 2 {
+		t.Fatalf("expected 2 reservations, got %+v", exported)
+	}
+
+	handles, conflicts := importInto(dst, exported, time.Now())
+	defer func() {
+		for _, h := range handles {
+			h.Close()
+		}
+	}()
+	if len(conflicts) != 0 || len(handles) != 2 {
+		t.Fatalf("expected clean import, got %d handles, conflicts %+v", len(handles), conflicts)
+	}
+
+	reimported, err := exportFrom(dst, time.Now())
+	if err != nil {
+		t.Fatalf("export after import: %v", err)
+	}
+	if len(reimported) != 2 {
+		t.Fatalf("expected 2 reservations after import, got %+v", reimported)
+	}
+	for i := range exported {
+		if reimported[i].Port != exported[i].Port {
+			t.Fatalf("port mismatch: %+v vs %+v", reimported, exported)
+		}
+		// The remaining TTL carries over, give or take scheduling.
+		if d := reimported[i].Expires.Sub(exported[i].Expires); d < -time.Second || d > time.Second {
+			t.Fatalf("expiry drifted by %v for port %d", d, exported[i].Port)
+		}
+	}
+}
+
+func TestLabelsWrittenAndReadBack(t *testing.T) {
+	dir := t.TempDir()
+	port := freePort(t)
+
+	h, err := lockPortIn(dir, port, time.Hour)
+	if err != nil {
+		t.Fatalf("lockPortIn: %v", err)
+	}
+	defer h.Close()
+	labels := []string{"ci-job-42", "api · staging"}
+	if err := h.SetLabels(labels); err != nil {
+		t.Fatalf("SetLabels: %v", err)
+	}
+
+	entries, err := listEntries(dir, time.Now())
+	if err != nil {
+		t.Fatalf("listEntries: %v", err)
+	}
+	if len(entries) != 1 || !slices.Equal(entries[0].Info.Labels, labels) {
+		t.Fatalf("expected labels %q read back, got %+v", labels, entries)
+	}
+	if entries[0].Info.PID != os.Getpid() || entries[0].Info.Expires.IsZero() {
+		t.Fatalf("labels must not clobber pid/expiry, got %+v", entries[0].Info)
+	}
+
+	exported, err := exportFrom(dir, time.Now())
+	if err != nil {
+		t.Fatalf("exportFrom: %v", err)
+	}
+	if len(exported) != 1 || !slices.Equal(exported[0].Labels, labels) {
+		t.Fatalf("expected labels in export, got %+v", exported)
+	}
+}
+
+func TestReleaseEntryOnlySignalsDetachedHolds(t *testing.T) {
+	dir := t.TempDir()
+	port := freePort(t)
+	h, err := lockPortIn(dir, port, 0)
+	if err != nil {
+		t.Fatalf("lockPortIn: %v", err)
+	}
+	defer h.Close()
+
+	var signaled []int
+	kill := func(pid int, sig syscall.Signal) error {
+		signaled = append(signaled, pid)
+		return h.Close() // the holder exits on SIGTERM
+	}
+	entry := func() Entry {
+		entries, err := listEntries(dir, time.Now())
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("listEntries: %v %+v", err, entries)
+		}
+		return entries[0]
+	}
+
+	if _, err := releaseEntry(entry(), false, time.Second, kill); err == nil || len(signaled) != 0 {
+		t.Fatalf("expected a plain lock to be refused without force, got err=%v signaled=%v", err, signaled)
+	}
+	if err := h.SetDetached(); err != nil {
+		t.Fatalf("SetDetached: %v", err)
+	}
+	pid, err := releaseEntry(entry(), false, time.Second, kill)
+	if err != nil || pid != os.Getpid() || len(signaled) != 1 {
+		t.Fatalf("expected detached hold released, got pid=%d err=%v signaled=%v", pid, err, signaled)
+	}
+	// The holder's Close removed the lock file along with the lock.
+	if entries, err := listEntries(dir, time.Now()); err != nil || len(entries) != 0 {
+		t.Fatalf("expected no lock file once released, got %v %+v", err, entries)
+	}
+	if _, err := releaseEntry(Entry{Port: port}, false, time.Second, kill); !errors.Is(err, ErrNotHeld) {
+		t.Fatalf("expected ErrNotHeld once released, got %v", err)
+	}
+}
+
+func TestValidateLabel(t *testing.T) {
+	for _, ok := range []string{"job-1", "déploiement", strings.Repeat("é", MaxLabelLen)} {
+		if err := ValidateLabel(ok); err != nil {
+			t.Errorf("ValidateLabel(%q): %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "\xff\xfe", strings.Repeat("x", MaxLabelLen+1)} {
+		if err := ValidateLabel(bad); err == nil {
+			t.Errorf("ValidateLabel(%q): expected error", bad)
+		}
+	}
+}
+
+func TestImportReportsConflicts(t *testing.T) {
+	dir := t.TempDir()
+	held, ok := freePort(t), freePort(t)
+
+	h, err := lockPortIn(dir, held, 0)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer h.Close()
+
+	now := time.Now()
+	handles, conflicts := importInto(dir, []Reservation{
+		{Port: held},
+		{Port: ok},
+		{Port: ok + 1, Expires: now.Add(-time.Minute)},
+	}, now)
+	defer func() {
+		for _, h := range handles {
+			h.Close()
+		}
+	}()
+
+	if len(handles) != 1 || handles[0].Port() != ok {
+		t.Fatalf("expected only port %d to be acquired, got %d handles", ok, len(handles))
+	}
+	if len(conflicts) != 2 || conflicts[0].Port != held || conflicts[0].Reason != "already reserved" || conflicts[1].Reason != "expired" {
+		t.Fatalf("unexpected conflicts: %+v", conflicts)
+	}
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
@@ -1,10 +1,15 @@
 package ports
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type Range struct {
@@ -12,6 +17,11 @@ type Range struct {
 	End   int
 }
 
+// Contains reports whether port lies within the range.
+func (r Range) Contains(port int) bool {
+	return port >= r.Start && port <= r.End
+}
+
 func ParseRange(s string) (Range, error) {
 	parts := strings.Split(strings.TrimSpace(s), "-")
 	if len(parts) != 2 {
@@ -31,7 +41,274 @@ func ParseRange(s string) (Range, error) {
 	return Range{Start: start, End: end}, nil
 }
 
-func PickTCPPort(prefer []int, r Range) (int, error) {
+// ParseCandidates parses a comma- or whitespace-separated mix of ports and
+// ranges ("3000-3005,4000") into an ordered list without duplicates.
+func ParseCandidates(s string) ([]int, error) {
+	tokens := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty candidate list")
+	}
+
+	var out []int
+	seen := make(map[int]bool)
+	add := func(p int) {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	for _, tok := range tokens {
+		if strings.Contains(tok, "-") {
+			r, err := ParseRange(tok)
+			if err != nil {
+				return nil, err
+			}
+			for p := r.Start; p <= r.End; p++ {
+				add(p)
+			}
+			continue
+		}
+		p, err := strconv.Atoi(tok)
+		if err != nil || p < 1 || p > 65535 {
+			return nil, fmt.Errorf("invalid port %q in candidate list", tok)
+		}
+		add(p)
+	}
+	return out, nil
+}
+
+// PickFromCandidates returns the first free port from candidates, in order.
+func PickFromCandidates(candidates []int, opts ProbeOptions) (int, error) {
+	for _, p := range candidates {
+		ok, err := probeTCP(p, opts.Timeout)
+		if errors.Is(err, ErrProbeTimeout) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("no free TCP port among %d candidates", len(candidates))
+}
+
+// PickFrom returns the lowest free port at or above start.
+func PickFrom(start int, opts ProbeOptions) (int, error) {
+	if start < 1 || start > 65535 {
+		return 0, fmt.Errorf("invalid start port %d", start)
+	}
+	for p := start; p <= 65535; p++ {
+		ok, err := probeTCP(p, opts.Timeout)
+		if errors.Is(err, ErrProbeTimeout) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("no free TCP port found in %d-65535", start)
+}
+
+// Port classes reported by Classify, per the IANA ranges.
+const (
+	ClassPrivileged = "privileged" // 1-1023: binding needs root or CAP_NET_BIND_SERVICE
+	ClassRegistered = "registered" // 1024-49151
+	ClassDynamic    = "dynamic"    // 49152-65535: where the OS picks ephemeral ports
+)
+
+// Classify returns the class of port, or "" if it isn't a valid port.
+func Classify(port int) string {
+	switch {
+	case port < 1 || port > 65535:
+		return ""
+	case port < 1024:
+		return ClassPrivileged
+	case port < 49152:
+		return ClassRegistered
+	default:
+		return ClassDynamic
+	}
+}
+
+// Probe results reported by ProbeStatus.
+const (
+	StatusFree       = "free"
+	StatusListening  = "in-use (listening)"
+	StatusUnbindable = "unbindable"
+)
+
+// dialTimeout bounds the connect attempt in ProbeStatus.
+var dialTimeout = 500 * time.Millisecond
+
+// ProbeStatus classifies port without any external tools: free if it can be
+// bound on 127.0.0.1, listening if not but a connect succeeds, and
+// unbindable otherwise (reserved, privileged, or held without accepting).
+func ProbeStatus(port int) (string, error) {
+	free, err := probeTCP(port, DefaultProbeOptions().Timeout)
+	if err != nil {
+		return "", err
+	}
+	if free {
+		return StatusFree, nil
+	}
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), dialTimeout)
+	if err == nil {
+		_ = conn.Close()
+		return StatusListening, nil
+	}
+	return StatusUnbindable, nil
+}
+
+// dialTCP is swapped out in tests to observe the address a probe dials.
+var dialTCP = net.DialTimeout
+
+// ConnectTCP reports whether something accepts TCP connections on
+// host:port. A refused connection means nothing is listening; timeouts and
+// unreachable hosts are errors, since they say nothing about the port.
+func ConnectTCP(host string, port int) (bool, error) {
+	target, err := ConnectTarget(host, port)
+	if err != nil {
+		return false, err
+	}
+	conn, err := dialTCP("tcp", target, dialTimeout)
+	if err == nil {
+		_ = conn.Close()
+		return true, nil
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return false, nil
+	}
+	return false, err
+}
+
+// ConnectTarget builds the dial address for host and port. IPv6 zones
+// ("fe80::1%eth0") are kept, and brackets are optional. A link-local IPv6
+// address without a zone is rejected: the kernel can't tell which link it
+// is on, so the dial would fail with an unhelpful error.
+func ConnectTarget(host string, port int) (string, error) {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if addr.Is6() && addr.Zone() == "" && (addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast()) {
+			msg := fmt.Sprintf("link-local address %s needs a zone, e.g. %s%%eth0", host, host)
+			if names := interfaceNames(); len(names) > 0 {
+				msg += " (interfaces: " + strings.Join(names, ", ") + ")"
+			}
+			return "", errors.New(msg)
+		}
+		host = addr.String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// interfaceNames lists the up, non-loopback interfaces usable as zones.
+func interfaceNames() []string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagLoopback == 0 {
+			names = append(names, iface.Name)
+		}
+	}
+	return names
+}
+
+// BusyPorts probes every port in r and returns those that can't be bound.
+// Ports whose probe timed out are left out; see ScanRange.
+func BusyPorts(r Range, opts ProbeOptions) ([]int, error) {
+	busy, _, err := ScanRange(r, opts)
+	return busy, err
+}
+
+// ProbeOptions tune the bind probes behind picks and range scans. Callers
+// start from DefaultProbeOptions and change what they need.
+type ProbeOptions struct {
+	// Timeout bounds a single bind attempt. A probe that takes longer is
+	// abandoned with ErrProbeTimeout, so one stuck bind can't hang a whole
+	// scan; zero or less waits indefinitely.
+	Timeout time.Duration
+
+	// Concurrency caps how many ports ScanRange probes at once. Each probe
+	// in flight holds a file descriptor, so the cap keeps a wide range from
+	// exhausting them; 1 or less probes one port at a time.
+	Concurrency int
+}
+
+// DefaultProbeOptions are the probe settings fp uses unless told otherwise.
+func DefaultProbeOptions() ProbeOptions {
+	return ProbeOptions{Timeout: time.Second, Concurrency: 16}
+}
+
+// ScanRange probes every port in r and returns those that can't be bound,
+// and separately those whose probe exceeded opts.Timeout, which are neither
+// known free nor known busy. Both lists are in port order. Up to
+// opts.Concurrency probes run at once; the first hard error stops the rest.
+func ScanRange(r Range, opts ProbeOptions) (busy, indeterminate []int, err error) {
+	type outcome struct {
+		free bool
+		err  error
+	}
+	outcomes := make([]outcome, max(r.End-r.Start+1, 0))
+	queue := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range min(max(opts.Concurrency, 1), len(outcomes)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				mu.Lock()
+				failed := err != nil
+				mu.Unlock()
+				if failed {
+					continue
+				}
+				free, perr := probeTCP(p, opts.Timeout)
+				outcomes[p-r.Start] = outcome{free, perr}
+				if perr != nil && !errors.Is(perr, ErrProbeTimeout) {
+					mu.Lock()
+					if err == nil {
+						err = perr
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for p := r.Start; p <= r.End; p++ {
+		queue <- p
+	}
+	close(queue)
+	wg.Wait()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, o := range outcomes {
+		switch {
+		case o.err != nil:
+			indeterminate = append(indeterminate, r.Start+i)
+		case !o.free:
+			busy = append(busy, r.Start+i)
+		}
+	}
+	return busy, indeterminate, nil
+}
+
+// PickTCPPort returns the first free preferred port, else the lowest free
+// port in r. A preferred 0 asks the kernel for an ephemeral port by binding
+// 127.0.0.1:0, so it can land outside r; if that bind fails, the remaining
+// preferences and the range are tried as usual.
+func PickTCPPort(prefer []int, r Range, opts ProbeOptions) (int, error) {
 	for _, p := range prefer {
 		if p == 0 {
 			ephemeral, ok := pickEphemeral()
@@ -43,25 +320,168 @@ func PickTCPPort(prefer []int, r Range) (int, error) {
 		if p < 1 || p > 65535 {
 			continue
 		}
-		if ok := probeTCP(p); ok {
+		ok, err := probeTCP(p, opts.Timeout)
+		if errors.Is(err, ErrProbeTimeout) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if ok {
 			return p, nil
 		}
 	}
 	for p := r.Start; p <= r.End; p++ {
-		if ok := probeTCP(p); ok {
+		ok, err := probeTCP(p, opts.Timeout)
+		if errors.Is(err, ErrProbeTimeout) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if ok {
 			return p, nil
 		}
 	}
 	return 0, fmt.Errorf("no free TCP port found in %d-%d", r.Start, r.End)
 }
 
-func probeTCP(port int) bool {
-	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
-	if err != nil {
-		return false
+// PickTCPPorts returns n distinct free ports in a fixed order: the free
+// preferred ports in prefer order, then the lowest free ports in r
+// ascending. Callers that map ports to roles by position can rely on it.
+// A preferred 0 takes an OS-assigned ephemeral port.
+func PickTCPPorts(prefer []int, r Range, n int, opts ProbeOptions) ([]int, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid port count %d", n)
+	}
+	var picked []int
+	taken := make(map[int]bool)
+	add := func(p int) bool {
+		taken[p] = true
+		picked = append(picked, p)
+		return len(picked) == n
 	}
-	_ = ln.Close()
-	return true
+	// free probes p, skipping ports already taken or whose probe timed out.
+	free := func(p int) (bool, error) {
+		if taken[p] {
+			return false, nil
+		}
+		ok, err := probeTCP(p, opts.Timeout)
+		if errors.Is(err, ErrProbeTimeout) {
+			return false, nil
+		}
+		return ok, err
+	}
+
+	for _, p := range prefer {
+		if p == 0 {
+			if ephemeral, ok := pickEphemeral(); ok && !taken[ephemeral] && add(ephemeral) {
+				return picked, nil
+			}
+			continue
+		}
+		if p < 1 || p > 65535 {
+			continue
+		}
+		ok, err := free(p)
+		if err != nil {
+			return nil, err
+		}
+		if ok && add(p) {
+			return picked, nil
+		}
+	}
+	for p := r.Start; p <= r.End; p++ {
+		ok, err := free(p)
+		if err != nil {
+			return nil, err
+		}
+		if ok && add(p) {
+			return picked, nil
+		}
+	}
+	return nil, fmt.Errorf("only %d of %d free TCP ports found (preferred, then %d-%d)", len(picked), n, r.Start, r.End)
+}
+
+// listenTCP is swapped out in tests to simulate bind failures.
+var listenTCP = net.Listen
+
+// probeRetries and probeBackoff bound how long a probe waits for file
+// descriptors to become available before giving up.
+var (
+	probeRetries = 5
+	probeBackoff = 10 * time.Millisecond
+)
+
+// ProbeTCP reports whether port can currently be bound on 127.0.0.1, with
+// the default probe timeout.
+func ProbeTCP(port int) (bool, error) {
+	return probeTCP(port, DefaultProbeOptions().Timeout)
+}
+
+// ErrProbeTimeout means a bind probe exceeded its timeout, leaving the
+// port's state unknown. Range scans and picks skip such ports.
+var ErrProbeTimeout = errors.New("probe timed out")
+
+// probeTCP reports whether port can be bound on loopback. Running out of
+// file descriptors says nothing about the port itself, so EMFILE/ENFILE are
+// retried with backoff and surfaced as an error instead of "in use".
+func probeTCP(port int, timeout time.Duration) (bool, error) {
+	backoff := probeBackoff
+	for attempt := 0; ; attempt++ {
+		ln, err := listenWithTimeout(fmt.Sprintf("127.0.0.1:%d", port), timeout)
+		if errors.Is(err, ErrProbeTimeout) {
+			return false, fmt.Errorf("probe port %d: %w", port, err)
+		}
+		if err == nil {
+			_ = ln.Close()
+			return true, nil
+		}
+		if !isFDExhausted(err) {
+			return false, nil
+		}
+		if attempt >= probeRetries {
+			return false, fmt.Errorf("probe port %d: %w", port, err)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// listenWithTimeout runs listenTCP in its own goroutine and gives up after
+// timeout, unless that is zero or less. A listener that arrives after the
+// caller has moved on is closed so the port isn't left held.
+func listenWithTimeout(address string, timeout time.Duration) (net.Listener, error) {
+	if timeout <= 0 {
+		return listenTCP("tcp", address)
+	}
+	type result struct {
+		ln  net.Listener
+		err error
+	}
+	done := make(chan result, 1)
+	listen := listenTCP // read now: the goroutine may outlive this call
+	go func() {
+		ln, err := listen("tcp", address)
+		done <- result{ln, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.ln, r.err
+	case <-timer.C:
+		go func() {
+			if r := <-done; r.ln != nil {
+				_ = r.ln.Close()
+			}
+		}()
+		return nil, ErrProbeTimeout
+	}
+}
+
+func isFDExhausted(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
 }
 
 func pickEphemeral() (int, bool) {
@@ -1,6 +1,16 @@
 package ports
 
-import "testing"
+import (
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
 
 func TestPickEphemeral(t *testing.T) {
 	port, ok := pickEphemeral()
@@ -12,3 +22,346 @@ func TestPickEphemeral(t *testing.T) {
 	}
 }
 
+func TestPickTCPPortPreferZeroIsEphemeral(t *testing.T) {
+	// Occupy the whole range: a preferred 0 must not fall through to it.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	busy := ln.Addr().(*net.TCPAddr).Port
+
+	port, err := PickTCPPort([]int{0}, Range{Start: busy, End: busy}, DefaultProbeOptions())
+	if err != nil {
+		t.Fatalf("PickTCPPort: %v", err)
+	}
+	if port == busy || port < 1 || port > 65535 {
+		t.Fatalf("expected a kernel-assigned port, got %d", port)
+	}
+}
+
+func TestParseCandidates(t *testing.T) {
+	got, err := ParseCandidates("3000-3002,4000, 3001\n5000")
+	if err != nil {
+		t.Fatalf("ParseCandidates: %v", err)
+	}
+	if want := []int{3000, 3001, 3002, 4000, 5000}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestParseCandidatesRejectsInvalid(t *testing.T) {
+	for _, in := range []string{"", " , ", "abc", "0", "70000", "5-3", "3000-"} {
+		if _, err := ParseCandidates(in); err == nil {
+			t.Errorf("expected %q to be rejected", in)
+		}
+	}
+}
+
+func TestPickFromCandidatesSkipsBusy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	busy := ln.Addr().(*net.TCPAddr).Port
+
+	free, ok := pickEphemeral()
+	if !ok {
+		t.Fatalf("ephemeral pick failed")
+	}
+	got, err := PickFromCandidates([]int{busy, free}, DefaultProbeOptions())
+	if err != nil {
+		t.Fatalf("PickFromCandidates: %v", err)
+	}
+	if got != free {
+		t.Fatalf("expected %d, got %d", free, got)
+	}
+}
+
+func TestPickFromSkipsOccupied(t *testing.T) {
+	orig := listenTCP
+	defer func() { listenTCP = orig }()
+	listenTCP = func(network, address string) (net.Listener, error) {
+		if address == "127.0.0.1:8080" {
+			return nil, &net.OpError{Op: "listen", Net: network, Err: os.NewSyscallError("bind", syscall.EADDRINUSE)}
+		}
+		return net.Listen(network, "127.0.0.1:0")
+	}
+
+	got, err := PickFrom(8080, DefaultProbeOptions())
+	if err != nil {
+		t.Fatalf("PickFrom: %v", err)
+	}
+	if got != 8081 {
+		t.Fatalf("expected 8081, got %d", got)
+	}
+}
+
+func TestPickFromErrorsWhenExhausted(t *testing.T) {
+	orig := listenTCP
+	defer func() { listenTCP = orig }()
+	listenTCP = func(network, address string) (net.Listener, error) {
+		return nil, &net.OpError{Op: "listen", Net: network, Err: os.NewSyscallError("bind", syscall.EADDRINUSE)}
+	}
+
+	if _, err := PickFrom(65530, DefaultProbeOptions()); err == nil {
+		t.Fatalf("expected error when nothing is free up to 65535")
+	}
+}
+
+func TestProbeStatus(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	status, err := ProbeStatus(port)
+	if err != nil || status != StatusListening {
+		t.Fatalf("expected %q, got %q (err=%v)", StatusListening, status, err)
+	}
+
+	if err := ln.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	status, err = ProbeStatus(port)
+	if err != nil || status != StatusFree {
+		t.Fatalf("expected %q, got %q (err=%v)", StatusFree, status, err)
+	}
+}
+
+func TestProbeStatusUnbindable(t *testing.T) {
+	orig := listenTCP
+	defer func() { listenTCP = orig }()
+	listenTCP = func(network, address string) (net.Listener, error) {
+		return nil, &net.OpError{Op: "listen", Net: network, Err: os.NewSyscallError("bind", syscall.EACCES)}
+	}
+
+	// Nothing accepts on this port, so the connect fails too.
+	port, ok := pickEphemeral()
+	if !ok {
+		t.Fatalf("ephemeral pick failed")
+	}
+	status, err := ProbeStatus(port)
+	if err != nil || status != StatusUnbindable {
+		t.Fatalf("expected %q, got %q (err=%v)", StatusUnbindable, status, err)
+	}
+}
+
+func TestProbeTCPBacksOffOnEMFILE(t *testing.T) {
+	restore := stubListen(t, 2)
+	defer restore()
+
+	ok, err := probeTCP(40000, time.Second)
+	if err != nil {
+		t.Fatalf("expected probe to recover after EMFILE, got %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected port to be reported free after backoff")
+	}
+}
+
+func TestProbeTCPReportsExhaustionAsError(t *testing.T) {
+	restore := stubListen(t, 100)
+	defer restore()
+
+	ok, err := probeTCP(40000, time.Second)
+	if err == nil {
+		t.Fatalf("expected error when file descriptors stay exhausted")
+	}
+	if ok {
+		t.Fatalf("expected ok=false alongside error")
+	}
+	if _, err := PickTCPPort(nil, Range{Start: 40000, End: 40010}, DefaultProbeOptions()); err == nil {
+		t.Fatalf("expected PickTCPPort to surface exhaustion instead of skipping ports")
+	}
+}
+
+// stubListen makes the first failures calls to listenTCP fail with EMFILE and
+// hands out a fake listener afterwards.
+func stubListen(t *testing.T, failures int) func() {
+	t.Helper()
+	origListen, origBackoff := listenTCP, probeBackoff
+	probeBackoff = time.Millisecond
+	var calls atomic.Int64
+	listenTCP = func(network, address string) (net.Listener, error) {
+		if calls.Add(1) <= int64(failures) {
+			return nil, &net.OpError{Op: "listen", Net: network, Err: os.NewSyscallError("socket", syscall.EMFILE)}
+		}
+		return net.Listen(network, "127.0.0.1:0")
+	}
+	return func() {
+		listenTCP, probeBackoff = origListen, origBackoff
+	}
+}
+
+func TestConnectTCPKeepsIPv6Zone(t *testing.T) {
+	orig := dialTCP
+	defer func() { dialTCP = orig }()
+	var dialed string
+	dialTCP = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		dialed = address
+		return nil, syscall.ECONNREFUSED
+	}
+
+	for _, host := range []string{"fe80::1%eth0", "[fe80::1%eth0]"} {
+		inUse, err := ConnectTCP(host, 8080)
+		if err != nil || inUse {
+			t.Fatalf("%s: expected refused to mean free, got inUse=%v err=%v", host, inUse, err)
+		}
+		if dialed != "[fe80::1%eth0]:8080" {
+			t.Fatalf("%s: expected zone preserved in dial address, got %q", host, dialed)
+		}
+	}
+
+	if _, err := ConnectTarget("fe80::1", 8080); err == nil || !strings.Contains(err.Error(), "needs a zone") {
+		t.Fatalf("expected a zone error for bare link-local, got %v", err)
+	}
+	for host, want := range map[string]string{"::1": "[::1]:80", "127.0.0.1": "127.0.0.1:80", "localhost": "localhost:80"} {
+		if got, err := ConnectTarget(host, 80); err != nil || got != want {
+			t.Fatalf("ConnectTarget(%q) = %q, %v; want %q", host, got, err, want)
+		}
+	}
+}
+
+func TestConnectTCPAcceptingListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	if inUse, err := ConnectTCP("127.0.0.1", port); err != nil || !inUse {
+		t.Fatalf("expected in use, got %v (err=%v)", inUse, err)
+	}
+	ln.Close()
+	if inUse, err := ConnectTCP("127.0.0.1", port); err != nil || inUse {
+		t.Fatalf("expected free after close, got %v (err=%v)", inUse, err)
+	}
+}
+
+func TestClassifyBoundaries(t *testing.T) {
+	cases := map[int]string{
+		0:     "",
+		1:     ClassPrivileged,
+		1023:  ClassPrivileged,
+		1024:  ClassRegistered,
+		49151: ClassRegistered,
+		49152: ClassDynamic,
+		65535: ClassDynamic,
+		65536: "",
+		-1:    "",
+	}
+	for port, want := range cases {
+		if got := Classify(port); got != want {
+			t.Errorf("Classify(%d) = %q, want %q", port, got, want)
+		}
+	}
+}
+
+func TestSlowProbeIsSkipped(t *testing.T) {
+	orig := listenTCP
+	defer func() { listenTCP = orig }()
+
+	release := make(chan struct{})
+	defer close(release)
+	opts := DefaultProbeOptions()
+	opts.Timeout = 20 * time.Millisecond
+	listenTCP = func(network, address string) (net.Listener, error) {
+		if address == "127.0.0.1:8080" {
+			<-release
+		}
+		return net.Listen(network, "127.0.0.1:0")
+	}
+
+	start := time.Now()
+	got, err := PickFrom(8080, opts)
+	if err != nil {
+		t.Fatalf("PickFrom: %v", err)
+	}
+	if got != 8081 {
+		t.Fatalf("expected the slow port to be skipped for 8081, got %d", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("probe didn't move on promptly: %s", elapsed)
+	}
+
+	busy, indeterminate, err := ScanRange(Range{Start: 8080, End: 8081}, opts)
+	if err != nil {
+		t.Fatalf("ScanRange: %v", err)
+	}
+	if len(busy) != 0 || !slices.Equal(indeterminate, []int{8080}) {
+		t.Fatalf("expected 8080 indeterminate and nothing busy, got busy=%v indeterminate=%v", busy, indeterminate)
+	}
+}
+
+func TestScanRangeCapsConcurrentProbes(t *testing.T) {
+	orig := listenTCP
+	defer func() { listenTCP = orig }()
+
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	opts := ProbeOptions{Timeout: time.Second, Concurrency: 3}
+	listenTCP = func(network, address string) (net.Listener, error) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		if strings.HasSuffix(address, "0") { // 40000, 40010, 40020 are taken
+			return nil, syscall.EADDRINUSE
+		}
+		return net.Listen(network, "127.0.0.1:0")
+	}
+
+	busy, indeterminate, err := ScanRange(Range{Start: 40000, End: 40029}, opts)
+	if err != nil {
+		t.Fatalf("ScanRange: %v", err)
+	}
+	if !slices.Equal(busy, []int{40000, 40010, 40020}) || len(indeterminate) != 0 {
+		t.Fatalf("expected busy ports in order, got busy=%v indeterminate=%v", busy, indeterminate)
+	}
+	if peak > 3 {
+		t.Fatalf("expected at most 3 probes at once, saw %d", peak)
+	}
+	if peak < 2 {
+		t.Fatalf("expected probes to overlap under a cap of 3, peak was %d", peak)
+	}
+
+	// Exhausted descriptors are an error, not a range of busy ports.
+	restore := stubListen(t, 1<<30)
+	defer restore()
+	if busy, _, err := ScanRange(Range{Start: 40000, End: 40029}, opts); err == nil {
+		t.Fatalf("expected EMFILE to surface, got busy=%v", busy)
+	}
+}
+
+func TestPickTCPPortsKeepsPreferOrder(t *testing.T) {
+	orig := listenTCP
+	defer func() { listenTCP = orig }()
+	busy := map[string]bool{"127.0.0.1:8081": true, "127.0.0.1:9001": true}
+	listenTCP = func(network, address string) (net.Listener, error) {
+		if busy[address] {
+			return nil, &net.OpError{Op: "listen", Net: network, Err: os.NewSyscallError("bind", syscall.EADDRINUSE)}
+		}
+		return net.Listen(network, "127.0.0.1:0")
+	}
+
+	// 8082 and 8080 are preferred (8081 is busy) and come first in prefer
+	// order; the range fills the rest ascending, skipping busy 9001 and the
+	// already-taken 9002.
+	got, err := PickTCPPorts([]int{8082, 8081, 8080, 9002}, Range{Start: 9000, End: 9010}, 5, DefaultProbeOptions())
+	if err != nil {
+		t.Fatalf("PickTCPPorts: %v", err)
+	}
+	if want := []int{8082, 8080, 9002, 9000, 9003}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if _, err := PickTCPPorts(nil, Range{Start: 9000, End: 9001}, 3, DefaultProbeOptions()); err == nil {
+		t.Fatalf("expected an error when the range can't supply enough ports")
+	}
+}
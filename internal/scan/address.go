@@ -0,0 +1,61 @@
+package scan
+
+import (
+	"net"
+	"net/netip"
+)
+
+// Address families reported in Listener.Family.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// parsedAddress is a listener's local address as reported by lsof or ss.
+type parsedAddress struct {
+	Addr   string // normalized "host:port"
+	Raw    string // the tool's form, set only when it differs from Addr
+	Family string // FamilyIPv4, FamilyIPv6, or "" for wildcards and names
+}
+
+// parseAddress classifies a "host:port" token. IPv4-mapped IPv6 addresses
+// ("[::ffff:127.0.0.1]:8080") are IPv4 sockets in practice, so they're
+// rewritten to the dotted quad with the original kept in Raw.
+func parseAddress(addr string) parsedAddress {
+	p := parsedAddress{Addr: addr}
+	host := addressHost(addr)
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return p
+	}
+	if ip.Is4In6() {
+		_, port, _ := net.SplitHostPort(addr)
+		p.Raw = addr
+		p.Addr = net.JoinHostPort(ip.Unmap().String(), port)
+		ip = ip.Unmap()
+	}
+	switch {
+	case ip.Is4():
+		p.Family = FamilyIPv4
+	case ip.Is6():
+		p.Family = FamilyIPv6
+	}
+	return p
+}
+
+// IsLoopback reports whether the listener is bound to a loopback address
+// only. Wildcard binds ("*", 0.0.0.0, ::) are reachable externally.
+func (l Listener) IsLoopback() bool {
+	host := addressHost(l.Address)
+	if host == "localhost" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.Unmap().IsLoopback()
+}
+
+// setAddress fills the address fields of l from a raw tool token.
+func (l *Listener) setAddress(token string) {
+	p := parseAddress(token)
+	l.Address, l.RawAddress, l.Family = p.Addr, p.Raw, p.Family
+}
@@ -0,0 +1,39 @@
+package scan
+
+import "testing"
+
+func TestParseAddressNormalizesIPv4Mapped(t *testing.T) {
+	cases := []struct {
+		in, addr, raw, family string
+		loopback              bool
+	}{
+		{"[::ffff:127.0.0.1]:8080", "127.0.0.1:8080", "[::ffff:127.0.0.1]:8080", FamilyIPv4, true},
+		{"[::ffff:192.168.1.20]:8080", "192.168.1.20:8080", "[::ffff:192.168.1.20]:8080", FamilyIPv4, false},
+		{"127.0.0.1:3000", "127.0.0.1:3000", "", FamilyIPv4, true},
+		{"[::1]:3000", "[::1]:3000", "", FamilyIPv6, true},
+		{"[::]:3000", "[::]:3000", "", FamilyIPv6, false},
+		{"*:3000", "*:3000", "", "", false},
+		{"127.0.0.53%lo:53", "127.0.0.53%lo:53", "", FamilyIPv4, true},
+	}
+	for _, tc := range cases {
+		var l Listener
+		l.setAddress(tc.in)
+		if l.Address != tc.addr || l.RawAddress != tc.raw || l.Family != tc.family {
+			t.Errorf("%s: got addr=%q raw=%q family=%q, want %q %q %q", tc.in, l.Address, l.RawAddress, l.Family, tc.addr, tc.raw, tc.family)
+		}
+		if l.IsLoopback() != tc.loopback {
+			t.Errorf("%s: IsLoopback = %v, want %v", tc.in, l.IsLoopback(), tc.loopback)
+		}
+	}
+}
+
+func TestParsersNormalizeIPv4Mapped(t *testing.T) {
+	ss, ok := parseSSLine(`LISTEN 0 4096 [::ffff:127.0.0.1]:8080 *:* users:(("java",pid=42,fd=9))`)
+	if !ok || ss.Address != "127.0.0.1:8080" || ss.Family != FamilyIPv4 || !ss.IsLoopback() {
+		t.Fatalf("ss: got %+v", ss)
+	}
+	lsof, ok := parseLsofLine("java 42 dev 9u IPv6 0x1 0t0 TCP [::ffff:10.0.0.5]:8080 (LISTEN)")
+	if !ok || lsof.Address != "10.0.0.5:8080" || lsof.RawAddress != "[::ffff:10.0.0.5]:8080" || lsof.IsLoopback() {
+		t.Fatalf("lsof: got %+v", lsof)
+	}
+}
@@ -0,0 +1,55 @@
+package scan
+
+import (
+	"context"
+)
+
+// Backlog is a TCP listener's accept queue as reported by ss: Queued
+// connections have completed the handshake but the server hasn't called
+// accept() on them yet, and Max is the queue's limit (the listen backlog,
+// capped by net.core.somaxconn). lsof has no equivalent.
+type Backlog struct {
+	Queued int `json:"queued"`
+	Max    int `json:"max"`
+}
+
+// Percent is how full the queue is. A zero limit reports 0.
+func (b Backlog) Percent() float64 {
+	if b.Max <= 0 {
+		return 0
+	}
+	return float64(b.Queued) * 100 / float64(b.Max)
+}
+
+// AddBacklog fills Backlog on TCP listeners that lack it (those found by
+// lsof) from one `ss -ltnH` run, matching by address. It does nothing when
+// ss isn't installed.
+func AddBacklog(ctx context.Context, listeners []Listener) error {
+	missing := false
+	for _, l := range listeners {
+		if l.Backlog == nil && (l.Proto == "" || l.Proto == "tcp") {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return nil
+	}
+	if _, err := lookPath("ss"); err != nil {
+		return nil
+	}
+	sockets, err := runBackend(ctx, []string{"ss", "-ltnH"}, parseSSOutput)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]*Backlog, len(sockets))
+	for _, s := range sockets {
+		byKey[s.Key()] = s.Backlog
+	}
+	for i := range listeners {
+		if listeners[i].Backlog == nil {
+			listeners[i].Backlog = byKey[listeners[i].Key()]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Connection is an established TCP connection whose local end is a
+// listening port, i.e. one client of the server on that port.
+type Connection struct {
+	Local   string `json:"local"`
+	Remote  string `json:"remote"`
+	PID     int    `json:"pid,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// ListConnections returns the established connections to local port. The
+// client side of a loopback connection to port is not included.
+func ListConnections(ctx context.Context, port int) ([]Connection, error) {
+	available := availableBackends()
+	if len(available) == 0 {
+		return nil, errNoBackend
+	}
+	switch available[0].Name {
+	case "ss":
+		return connectionsViaSS(ctx, port)
+	default:
+		return connectionsViaLsof(ctx, port)
+	}
+}
+
+func connectionsViaSS(ctx context.Context, port int) ([]Connection, error) {
+	// Example, with no State column since the filter fixes it:
+	// 0 0 127.0.0.1:8080 127.0.0.1:53412 users:(("node",pid=12345,fd=23))
+	argv := []string{"ss", "-tnpH", "state", "established", "sport", "=", ":" + strconv.Itoa(port)}
+	return runBackend(ctx, argv, func(_ context.Context, r io.Reader) ([]Connection, error) {
+		return parseConnections(r, port, parseSSConnLine)
+	})
+}
+
+func connectionsViaLsof(ctx context.Context, port int) ([]Connection, error) {
+	// Example NAME column: 127.0.0.1:8080->127.0.0.1:53412 (ESTABLISHED)
+	argv := []string{"lsof", "-nP", "-iTCP:" + strconv.Itoa(port), "-sTCP:ESTABLISHED"}
+	return runLsof(ctx, argv, func(_ context.Context, r io.Reader) ([]Connection, error) {
+		return parseConnections(r, port, parseLsofConnLine)
+	})
+}
+
+// parseConnections keeps the lines parse accepts whose local port is port.
+func parseConnections(r io.Reader, port int, parse func(string) (Connection, bool)) ([]Connection, error) {
+	var conns []Connection
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		conn, ok := parse(scanner.Text())
+		if !ok {
+			continue
+		}
+		if p, ok := parsePortFromAddress(conn.Local); !ok || p != port {
+			continue
+		}
+		conns = append(conns, conn)
+	}
+	return conns, scanner.Err()
+}
+
+func parseSSConnLine(line string) (Connection, bool) {
+	var addrs []string
+	for _, f := range strings.Fields(line) {
+		if strings.Contains(f, ":") && !strings.HasPrefix(f, "users:") {
+			addrs = append(addrs, f)
+		}
+	}
+	if len(addrs) < 2 {
+		return Connection{}, false
+	}
+	conn := Connection{Local: addrs[0], Remote: addrs[1]}
+	if pm := ssPid.FindStringSubmatch(line); len(pm) == 2 {
+		conn.PID, _ = strconv.Atoi(pm[1])
+	}
+	if cm := ssProc.FindStringSubmatch(line); len(cm) == 2 {
+		conn.Command = cm[1]
+	}
+	return conn, true
+}
+
+func parseLsofConnLine(line string) (Connection, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] == "COMMAND" {
+		return Connection{}, false
+	}
+	for _, f := range fields {
+		local, remote, ok := strings.Cut(f, "->")
+		if !ok {
+			continue
+		}
+		pid, _ := strconv.Atoi(fields[1])
+		return Connection{Local: local, Remote: remote, PID: pid, Command: fields[0]}, true
+	}
+	return Connection{}, false
+}
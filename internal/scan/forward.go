@@ -0,0 +1,135 @@
+package scan
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DetectForwarding recognizes port-forwarding tools by their command line and
+// returns the target that local port is forwarded to, e.g. "pod/foo:80" for
+// `kubectl port-forward pod/foo 8080:80`. Unrecognized commands yield "".
+func DetectForwarding(commandLine string, port int) string {
+	args := strings.Fields(commandLine)
+	if len(args) == 0 {
+		return ""
+	}
+	switch filepath.Base(args[0]) {
+	case "kubectl":
+		return kubectlForward(args[1:], port)
+	case "docker-proxy":
+		return dockerProxyForward(args[1:])
+	case "docker", "docker-compose", "podman":
+		return dockerPublishForward(args[1:], port)
+	}
+	return ""
+}
+
+func kubectlForward(args []string, port int) string {
+	var namespace, resource string
+	var mappings []string
+	seenVerb := false
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "-n" || a == "--namespace":
+			if i+1 < len(args) {
+				namespace = args[i+1]
+				i++
+			}
+		case strings.HasPrefix(a, "--namespace="):
+			namespace = strings.TrimPrefix(a, "--namespace=")
+		case a == "port-forward":
+			seenVerb = true
+		case strings.HasPrefix(a, "-"):
+			// Other flags (e.g. --address) are irrelevant; skip their value
+			// when given as a separate argument.
+			if !strings.Contains(a, "=") && i+1 < len(args) && a != "-v" {
+				i++
+			}
+		case !seenVerb:
+		case resource == "":
+			resource = a
+		default:
+			mappings = append(mappings, a)
+		}
+	}
+	if !seenVerb || resource == "" || len(mappings) == 0 {
+		return ""
+	}
+	if !strings.Contains(resource, "/") {
+		resource = "pod/" + resource
+	}
+	if namespace != "" {
+		resource = namespace + "/" + resource
+	}
+
+	for _, m := range mappings {
+		local, remote, ok := strings.Cut(m, ":")
+		if !ok {
+			local, remote = m, m
+		}
+		if local == "" && len(mappings) == 1 {
+			return resource + ":" + remote
+		}
+		if p, err := strconv.Atoi(local); err == nil && p == port {
+			return resource + ":" + remote
+		}
+	}
+	return ""
+}
+
+func dockerProxyForward(args []string) string {
+	var ip, port string
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-container-ip":
+			ip = args[i+1]
+		case "-container-port":
+			port = args[i+1]
+		}
+	}
+	if ip == "" || port == "" {
+		return ""
+	}
+	return "container " + ip + ":" + port
+}
+
+// dockerPublishForward handles `docker run -p [ip:]host:container[/proto]`
+// and `docker compose run --publish ...`.
+func dockerPublishForward(args []string, port int) string {
+	service := ""
+	for i := 0; i < len(args); i++ {
+		var spec string
+		switch a := args[i]; {
+		case a == "-p" || a == "--publish":
+			if i+1 < len(args) {
+				spec = args[i+1]
+				i++
+			}
+		case strings.HasPrefix(a, "--publish="):
+			spec = strings.TrimPrefix(a, "--publish=")
+		case strings.HasPrefix(a, "-p") && len(a) > 2:
+			spec = a[2:]
+		}
+		if spec == "" {
+			continue
+		}
+		spec, _, _ = strings.Cut(spec, "/")
+		parts := strings.Split(spec, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		host, container := parts[len(parts)-2], parts[len(parts)-1]
+		if p, err := strconv.Atoi(host); err == nil && p == port {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				service = args[i+1]
+			}
+			if service != "" {
+				return "container " + service + ":" + container
+			}
+			return "container port " + container
+		}
+	}
+	return ""
+}
@@ -0,0 +1,30 @@
+package scan
+
+import "testing"
+
+func TestDetectForwarding(t *testing.T) {
+	cases := []struct {
+		cmdline string
+		port    int
+		want    string
+	}{
+		{"kubectl port-forward pod/foo 8080:80", 8080, "pod/foo:80"},
+		{"/usr/local/bin/kubectl -n dev port-forward svc/web 9000:80 9443:443", 9443, "dev/svc/web:443"},
+		{"kubectl port-forward --address 0.0.0.0 deployment/api 5000", 5000, "deployment/api:5000"},
+		{"kubectl port-forward mypod :5432 --namespace=db", 41234, "db/pod/mypod:5432"},
+		{"kubectl port-forward pod/foo 8080:80", 9999, ""},
+		{"kubectl get pods", 8080, ""},
+		{"/usr/bin/docker-proxy -proto tcp -host-ip 0.0.0.0 -host-port 8080 -container-ip 172.17.0.2 -container-port 80", 8080, "container 172.17.0.2:80"},
+		{"docker run --rm -p 127.0.0.1:8080:80/tcp nginx", 8080, "container nginx:80"},
+		{"docker compose run --publish=3000:3000 web", 3000, "container web:3000"},
+		{"docker run -p 8080:80 -p 9090:90 img", 8080, "container port 80"},
+		{"docker-compose run -p 5433:5432 db", 5433, "container db:5432"},
+		{"node server.js", 3000, ""},
+		{"", 3000, ""},
+	}
+	for _, tc := range cases {
+		if got := DetectForwarding(tc.cmdline, tc.port); got != tc.want {
+			t.Errorf("DetectForwarding(%q, %d) = %q, want %q", tc.cmdline, tc.port, got, tc.want)
+		}
+	}
+}
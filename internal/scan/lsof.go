@@ -3,35 +3,74 @@ package scan
 import (
 	"bufio"
 	"context"
+	"errors"
 	"io"
-	"os/exec"
+	"net"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode"
+)
+
+// Commands for the full lsof listings.
+var (
+	lsofTCPCommand = []string{"lsof", "-nP", "-iTCP", "-sTCP:LISTEN"}
+	lsofUDPCommand = []string{"lsof", "-nP", "-iUDP"}
 )
 
 func listTCPListenersViaLsof(ctx context.Context) ([]Listener, error) {
-	c := exec.CommandContext(ctx, "lsof", "-nP", "-iTCP", "-sTCP:LISTEN")
-	out, err := c.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-	if err := c.Start(); err != nil {
-		return nil, err
+	return runLsof(ctx, lsofTCPCommand, parseLsofOutput)
+}
+
+// listPortViaLsof restricts lsof to one port.
+func listPortViaLsof(ctx context.Context, port int) ([]Listener, error) {
+	return runLsof(ctx, []string{"lsof", "-nP", "-iTCP:" + strconv.Itoa(port), "-sTCP:LISTEN"}, parseLsofOutput)
+}
+
+// runLsof is runBackend for lsof, which exits 1 when no socket matches:
+// an empty result, not a failure. Only warnings may accompany it on stderr.
+func runLsof[T any](ctx context.Context, argv []string, parse func(context.Context, io.Reader) ([]T, error)) ([]T, error) {
+	listeners, err := runBackend(ctx, argv, parse)
+	var exitErr *backendExitError
+	if errors.As(err, &exitErr) && exitErr.Code == 1 && onlyLsofWarnings(exitErr.Stderr) {
+		return nil, nil
 	}
-	defer c.Wait()
+	return listeners, err
+}
 
-	listeners, err := parseLsofOutput(out)
-	if err != nil {
-		return nil, err
+// onlyLsofWarnings reports whether every line of stderr is an lsof warning,
+// such as one about a filesystem it couldn't stat.
+func onlyLsofWarnings(stderr string) bool {
+	for line := range strings.Lines(stderr) {
+		if !strings.HasPrefix(line, "lsof: WARNING:") {
+			return false
+		}
 	}
-	return listeners, nil
+	return true
+}
+
+// listUDPListenersViaLsof lists bound UDP sockets. UDP has no LISTEN state,
+// so connected sockets (NAME local->remote) are dropped instead.
+func listUDPListenersViaLsof(ctx context.Context) ([]Listener, error) {
+	return runLsof(ctx, lsofUDPCommand, func(ctx context.Context, r io.Reader) ([]Listener, error) {
+		return parseLsofProtoOutput(ctx, r, "udp")
+	})
+}
+
+// parseLsofOutput stops early if ctx is done, returning the listeners parsed
+// so far along with the context error so callers can choose to use them.
+func parseLsofOutput(ctx context.Context, r io.Reader) ([]Listener, error) {
+	return parseLsofProtoOutput(ctx, r, "tcp")
 }
 
-func parseLsofOutput(r io.Reader) ([]Listener, error) {
+func parseLsofProtoOutput(ctx context.Context, r io.Reader, proto string) ([]Listener, error) {
 	var listeners []Listener
 	scanner := bufio.NewScanner(r)
 	first := true
 	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return listeners, err
+		}
 		line := scanner.Text()
 		if first {
 			first = false
@@ -40,12 +79,20 @@ func parseLsofOutput(r io.Reader) ([]Listener, error) {
 			}
 		}
 
+		if proto == "udp" && strings.Contains(line, "->") {
+			continue
+		}
 		listener, ok := parseLsofLine(line)
 		if !ok {
 			continue
 		}
+		listener.Proto = proto
 		listeners = append(listeners, listener)
 	}
+	if err := ctx.Err(); err != nil {
+		// The tool was killed mid-stream; the read error is just fallout.
+		return listeners, err
+	}
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
@@ -62,22 +109,41 @@ func parseLsofLine(line string) (Listener, bool) {
 	pid, _ := strconv.Atoi(fields[1])
 	user := fields[2]
 
-	addr, port := parseLsofAddressAndPort(fields)
+	addr, raw, port := parseLsofAddressAndPort(fields)
 	if port == 0 {
 		return Listener{}, false
 	}
 
-	return Listener{
+	l := Listener{
 		Port:    port,
 		PID:     pid,
 		User:    user,
 		Command: command,
 		Proto:   "tcp",
-		Address: addr,
-	}, true
+	}
+	l.setAddress(addr)
+	if raw != addr && l.RawAddress == "" {
+		l.RawAddress = raw
+	}
+	return l, true
+}
+
+// lookupServicePort resolves a service name such as "http" through
+// /etc/services (or Go's built-in table of common services).
+var lookupServicePort = func(network, name string) (int, bool) {
+	p, err := net.LookupPort(network, name)
+	return p, err == nil && p > 0
 }
 
-func parseLsofAddressAndPort(fields []string) (addr string, port int) {
+// parseLsofAddressAndPort finds the local address among an lsof line's
+// fields. Without -P, lsof prints service names (*:http); those are
+// resolved to numbers, and addr is rewritten to use the number with the
+// original kept in raw. Unknown names are skipped.
+func parseLsofAddressAndPort(fields []string) (addr, raw string, port int) {
+	network := "tcp"
+	if slices.Contains(fields, "UDP") {
+		network = "udp"
+	}
 	for i := len(fields) - 1; i >= 0; i-- {
 		token := fields[i]
 		if token == "(LISTEN)" {
@@ -93,11 +159,31 @@ func parseLsofAddressAndPort(fields []string) (addr string, port int) {
 		if lastColon < 0 || lastColon == len(token)-1 {
 			continue
 		}
-		p, err := strconv.Atoi(token[lastColon+1:])
+		name := token[lastColon+1:]
+		p, err := strconv.Atoi(name)
+		if err != nil && isServiceName(name) {
+			if sp, ok := lookupServicePort(network, name); ok {
+				return token[:lastColon+1] + strconv.Itoa(sp), token, sp
+			}
+		}
 		if err != nil || p < 1 || p > 65535 {
 			continue
 		}
-		return token, p
+		return token, token, p
+	}
+	return "", "", 0
+}
+
+// isServiceName reports whether s looks like an /etc/services name: letters,
+// digits, '-' and '_', starting with a letter.
+func isServiceName(s string) bool {
+	if s == "" || !unicode.IsLetter(rune(s[0])) {
+		return false
+	}
+	for _, r := range s {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_') {
+			return false
+		}
 	}
-	return "", 0
+	return true
 }
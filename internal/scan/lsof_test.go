@@ -1,6 +1,7 @@
 package scan
 
 import (
+	"context"
 	"strings"
 	"testing"
 )
@@ -15,25 +16,52 @@ redis    888  bob    10u  IPv6 0x000000003  0t0    TCP [::1]:6379 (LISTEN)
 nginx    999  root   11u  IPv4 0x000000004  0t0    TCP *:http (LISTEN)
 `)
 
-	listeners, err := parseLsofOutput(strings.NewReader(input))
+	listeners, err := parseLsofOutput(context.Background(), strings.NewReader(input))
 	if err != nil {
 		t.Fatalf("parseLsofOutput error: %v", err)
 	}
 
-	if len(listeners) != 4 {
-		t.Fatalf("expected 4 listeners, got %d", len(listeners))
+	if len(listeners) != 5 {
+		t.Fatalf("expected 5 listeners, got %d", len(listeners))
 	}
 
 	assertListener(t, listeners[0], 3000, 1234, "alice", "node", "*:3000")
 	assertListener(t, listeners[1], 3000, 1235, "alice", "node", "[::1]:3000")
 	assertListener(t, listeners[2], 8000, 777, "bob", "python", "127.0.0.1:8000")
 	assertListener(t, listeners[3], 6379, 888, "bob", "redis", "[::1]:6379")
+	assertListener(t, listeners[4], 80, 999, "root", "nginx", "*:80") // service name, via /etc/services
 }
 
-func TestParseLsofLineSkipsNonNumericPorts(t *testing.T) {
-	line := "nginx 999 root 11u IPv4 0x000000004 0t0 TCP *:http (LISTEN)"
-	if _, ok := parseLsofLine(line); ok {
-		t.Fatalf("expected non-numeric port to be skipped")
+func TestParseLsofLineResolvesServiceNames(t *testing.T) {
+	orig := lookupServicePort
+	defer func() { lookupServicePort = orig }()
+	lookupServicePort = func(network, name string) (int, bool) {
+		ports := map[string]int{"tcp/http": 80, "udp/domain": 53}
+		p, ok := ports[network+"/"+name]
+		return p, ok
+	}
+
+	l, ok := parseLsofLine("nginx 999 root 11u IPv4 0x000000004 0t0 TCP *:http (LISTEN)")
+	if !ok {
+		t.Fatalf("expected service name to resolve")
+	}
+	assertListener(t, l, 80, 999, "root", "nginx", "*:80")
+	if l.RawAddress != "*:http" {
+		t.Fatalf("expected raw address *:http, got %q", l.RawAddress)
+	}
+
+	l, ok = parseLsofLine("dnsmasq 77 root 4u IPv4 0x000000005 0t0 UDP 127.0.0.1:domain")
+	if !ok || l.Port != 53 || l.Address != "127.0.0.1:53" {
+		t.Fatalf("expected UDP service lookup, got %+v, %v", l, ok)
+	}
+
+	for _, line := range []string{
+		"nginx 999 root 11u IPv4 0x000000004 0t0 TCP *:nosuchservice (LISTEN)",
+		"nginx 999 root 11u IPv4 0x000000004 0t0 TCP *:8o80 (LISTEN)",
+	} {
+		if _, ok := parseLsofLine(line); ok {
+			t.Fatalf("expected unknown port name to be skipped: %q", line)
+		}
 	}
 }
 
@@ -59,3 +87,30 @@ func assertListener(t *testing.T, got Listener, port int, pid int, user, command
 	}
 }
 
+
+func TestParseLsofUDPOutputSkipsConnectedSockets(t *testing.T) {
+	input := strings.Join([]string{
+		"COMMAND     PID USER   FD   TYPE DEVICE SIZE/OFF NODE NAME",
+		"dnsmasq     410 nobody  4u  IPv4  12345      0t0  UDP 127.0.0.1:53",
+		"mdns        512 dev     7u  IPv6  12346      0t0  UDP *:5353",
+		"chrome      900 dev    31u  IPv4  12347      0t0  UDP 192.168.1.5:51000->8.8.8.8:443",
+	}, "\n")
+
+	listeners, err := parseLsofProtoOutput(context.Background(), strings.NewReader(input), "udp")
+	if err != nil {
+		t.Fatalf("parseLsofProtoOutput: %v", err)
+	}
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 bound sockets, got %d: %+v", len(listeners), listeners)
+	}
+	want := []Listener{
+		{Port: 53, PID: 410, User: "nobody", Command: "dnsmasq", Address: "127.0.0.1:53"},
+		{Port: 5353, PID: 512, User: "dev", Command: "mdns", Address: "*:5353"},
+	}
+	for i, l := range listeners {
+		w := want[i]
+		if l.Port != w.Port || l.PID != w.PID || l.User != w.User || l.Command != w.Command || l.Address != w.Address || l.Proto != "udp" {
+			t.Fatalf("socket %d: got %+v, want %+v over udp", i, l, w)
+		}
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"context"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 func EnrichListenersWithProcessInfo(ctx context.Context, listeners []Listener) {
@@ -28,6 +31,12 @@ func EnrichListenersWithProcessInfo(ctx context.Context, listeners []Listener) {
 
 	fillFromPS(ctx, byPID)
 	fillProcPaths(ctx, byPID)
+
+	for i := range listeners {
+		if listeners[i].CommandLine != "" {
+			listeners[i].Forwarding = DetectForwarding(listeners[i].CommandLine, listeners[i].Port)
+		}
+	}
 }
 
 func fillFromPS(ctx context.Context, byPID map[int]*Listener) {
@@ -39,7 +48,9 @@ func fillFromPS(ctx context.Context, byPID map[int]*Listener) {
 	for pid := range byPID {
 		pids = append(pids, strconv.Itoa(pid))
 	}
-	cmd := exec.CommandContext(ctx, "ps", "-p", strings.Join(pids, ","), "-o", "pid=", "-o", "ppid=", "-o", "command=")
+	cmd := exec.CommandContext(ctx, "ps", "-p", strings.Join(pids, ","), "-o", "pid=", "-o", "ppid=", "-o", "lstart=", "-o", "command=")
+	// lstart is only parseable in the C locale.
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
 	out, err := cmd.StdoutPipe()
 	if err != nil {
 		return
@@ -51,19 +62,10 @@ func fillFromPS(ctx context.Context, byPID map[int]*Listener) {
 
 	scanner := bufio.NewScanner(out)
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-		fields, rest := splitFieldsWithRemainder(line, 2)
-		if len(fields) < 2 {
+		pid, ppid, started, command, ok := parsePSLine(scanner.Text())
+		if !ok {
 			continue
 		}
-		pid, err := strconv.Atoi(fields[0])
-		if err != nil {
-			continue
-		}
-		ppid, _ := strconv.Atoi(fields[1])
 		listener := byPID[pid]
 		if listener == nil {
 			continue
@@ -71,12 +73,49 @@ func fillFromPS(ctx context.Context, byPID map[int]*Listener) {
 		if ppid > 0 {
 			listener.PPID = ppid
 		}
-		if rest != "" {
-			listener.CommandLine = strings.TrimSpace(rest)
+		if !started.IsZero() {
+			listener.Started = started
+		}
+		if command != "" {
+			listener.CommandLine = command
 		}
 	}
 }
 
+// psStartLayout is ps's lstart format, e.g. "Fri Oct 16 09:30:00 2026",
+// with the day's padding collapsed. It is in local time.
+const psStartLayout = "Mon Jan 2 15:04:05 2006"
+
+// parsePSLine parses one line of `ps -o pid= -o ppid= -o lstart= -o command=`.
+// An unparseable start time is left zero and its fields are kept as part of
+// the command rather than dropping the line.
+func parsePSLine(line string) (pid, ppid int, started time.Time, command string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return 0, 0, time.Time{}, "", false
+	}
+	fields, rest := splitFieldsWithRemainder(line, 7)
+	if len(fields) < 2 {
+		return 0, 0, time.Time{}, "", false
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, time.Time{}, "", false
+	}
+	ppid, _ = strconv.Atoi(fields[1])
+	command = strings.TrimSpace(rest)
+	if len(fields) == 7 {
+		started, err = time.ParseInLocation(psStartLayout, strings.Join(fields[2:], " "), time.Local)
+		if err != nil {
+			started = time.Time{}
+		}
+	}
+	if started.IsZero() && len(fields) > 2 {
+		command = strings.TrimSpace(strings.Join(fields[2:], " ") + " " + command)
+	}
+	return pid, ppid, started, command, true
+}
+
 func fillProcPaths(ctx context.Context, byPID map[int]*Listener) {
 	if runtime.GOOS == "linux" {
 		for pid, listener := range byPID {
@@ -86,7 +125,11 @@ func fillProcPaths(ctx context.Context, byPID map[int]*Listener) {
 			}
 			exe, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "exe"))
 			if err == nil && exe != "" {
-				listener.Executable = exe
+				listener.Executable, listener.ExecutableDeleted = splitDeletedExe(exe)
+			}
+			// ss doesn't report the owner; /proc/<pid> is owned by the process's uid.
+			if listener.User == "" {
+				listener.User = procOwner(pid)
 			}
 		}
 		return
@@ -106,6 +149,50 @@ func fillProcPaths(ctx context.Context, byPID map[int]*Listener) {
 	}
 }
 
+// deletedSuffix is what Linux appends to /proc/<pid>/exe once the binary
+// has been unlinked, typically by an in-place upgrade.
+const deletedSuffix = " (deleted)"
+
+// splitDeletedExe separates a /proc/<pid>/exe target from the deleted
+// marker, so Executable stays a plain path.
+func splitDeletedExe(target string) (string, bool) {
+	if path, ok := strings.CutSuffix(target, deletedSuffix); ok && path != "" {
+		return path, true
+	}
+	return target, false
+}
+
+// ResolveExecutables replaces each Executable with its canonical path,
+// following symlinks. Deleted executables and paths that can't be resolved
+// are left as they are.
+func ResolveExecutables(listeners []Listener) {
+	for i := range listeners {
+		l := &listeners[i]
+		if l.Executable == "" || l.ExecutableDeleted {
+			continue
+		}
+		if path, err := filepath.EvalSymlinks(l.Executable); err == nil {
+			l.Executable = path
+		}
+	}
+}
+
+func procOwner(pid int) string {
+	info, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid)))
+	if err != nil {
+		return ""
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	uid := strconv.FormatUint(uint64(st.Uid), 10)
+	if u, err := user.LookupId(uid); err == nil {
+		return u.Username
+	}
+	return uid
+}
+
 func lsofProcPaths(ctx context.Context, pid int) (string, string) {
 	cmd := exec.CommandContext(ctx, "lsof", "-p", strconv.Itoa(pid), "-a", "-d", "cwd,txt", "-Fn")
 	out, err := cmd.StdoutPipe()
@@ -164,3 +251,43 @@ func splitFieldsWithRemainder(line string, n int) ([]string, string) {
 	}
 	return fields, line[i:]
 }
+
+// ProcessParents maps every process's PID to its parent PID, from
+// `ps -A -o pid= -o ppid=`.
+func ProcessParents(ctx context.Context) (map[int]int, error) {
+	out, err := exec.CommandContext(ctx, "ps", "-A", "-o", "pid=", "-o", "ppid=").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseParents(string(out)), nil
+}
+
+func parseParents(out string) map[int]int {
+	parents := make(map[int]int)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			parents[pid] = ppid
+		}
+	}
+	return parents
+}
+
+// IsDescendant reports whether ancestor appears in pid's chain of parents.
+// A process is not its own descendant, and the walk stops at a PID with no
+// known parent or one it has already visited.
+func IsDescendant(pid, ancestor int, parents map[int]int) bool {
+	seen := make(map[int]bool)
+	for p := parents[pid]; p > 0 && !seen[p]; p = parents[p] {
+		if p == ancestor {
+			return true
+		}
+		seen[p] = true
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package scan
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParsePSLine(t *testing.T) {
+	pid, ppid, started, command, ok := parsePSLine("  4242     1 Fri Oct  2 09:30:05 2026 node server.js --port 3000")
+	if !ok || pid != 4242 || ppid != 1 || command != "node server.js --port 3000" {
+		t.Fatalf("got pid=%d ppid=%d command=%q ok=%v", pid, ppid, command, ok)
+	}
+	want := time.Date(2026, time.October, 2, 9, 30, 5, 0, time.Local)
+	if !started.Equal(want) {
+		t.Fatalf("started = %v, want %v", started, want)
+	}
+}
+
+func TestParsePSLineKeepsCommandWithoutStartTime(t *testing.T) {
+	_, _, started, command, ok := parsePSLine("4242 1 node server.js --port 3000 --verbose")
+	if !ok || !started.IsZero() || command != "node server.js --port 3000 --verbose" {
+		t.Fatalf("got started=%v command=%q ok=%v", started, command, ok)
+	}
+}
+
+func TestProcOwnerIsCurrentUser(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("procOwner reads /proc")
+	}
+	me, err := user.Current()
+	if err != nil {
+		t.Skip("current user unknown")
+	}
+	if got := procOwner(os.Getpid()); got != me.Username {
+		t.Fatalf("expected owner %q, got %q", me.Username, got)
+	}
+}
+
+func TestSplitDeletedExe(t *testing.T) {
+	cases := []struct {
+		in      string
+		path    string
+		deleted bool
+	}{
+		{"/usr/bin/node", "/usr/bin/node", false},
+		{"/usr/bin/node (deleted)", "/usr/bin/node", true},
+		{"/opt/app (deleted) v2/server", "/opt/app (deleted) v2/server", false},
+		{" (deleted)", " (deleted)", false},
+	}
+	for _, tc := range cases {
+		if path, deleted := splitDeletedExe(tc.in); path != tc.path || deleted != tc.deleted {
+			t.Fatalf("splitDeletedExe(%q) = %q, %v; want %q, %v", tc.in, path, deleted, tc.path, tc.deleted)
+		}
+	}
+}
+
+func TestEnrichFlagsDeletedExecutable(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("deleted executables are detected through /proc")
+	}
+	src, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+	bin := filepath.Join(t.TempDir(), "stale-server")
+	copyExecutable(t, src, bin)
+
+	c := exec.Command(bin, "30")
+	if err := c.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Process.Kill(); _ = c.Wait() })
+	if err := os.Remove(bin); err != nil {
+		t.Fatal(err)
+	}
+
+	listeners := []Listener{{Port: 3000, PID: c.Process.Pid}}
+	EnrichListenersWithProcessInfo(context.Background(), listeners)
+	if !listeners[0].ExecutableDeleted || listeners[0].Executable != bin {
+		t.Fatalf("expected %s flagged as deleted, got %q deleted=%v", bin, listeners[0].Executable, listeners[0].ExecutableDeleted)
+	}
+}
+
+func TestResolveExecutablesFollowsSymlinks(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "node-22")
+	if err := os.WriteFile(target, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "node")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	listeners := []Listener{
+		{Executable: link},
+		{Executable: link, ExecutableDeleted: true},
+		{Executable: filepath.Join(dir, "missing")},
+	}
+	ResolveExecutables(listeners)
+	if listeners[0].Executable != target {
+		t.Fatalf("expected %s, got %s", target, listeners[0].Executable)
+	}
+	if listeners[1].Executable != link || listeners[2].Executable != filepath.Join(dir, "missing") {
+		t.Fatalf("deleted or unresolvable paths should be left alone, got %+v", listeners[1:])
+	}
+}
+
+func copyExecutable(t *testing.T, src, dst string) {
+	t.Helper()
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsDescendant(t *testing.T) {
+	// 1 -> 500 (shell) -> 510 (npm) -> 520 (node); 1 -> 600 (unrelated)
+	parents := parseParents("  1  0\n500  1\n510 500\n520 510\n600  1\n700 700\n")
+
+	cases := []struct {
+		pid, ancestor int
+		want          bool
+	}{
+		{520, 500, true},
+		{510, 500, true},
+		{500, 500, false},
+		{600, 500, false},
+		{520, 1, true},
+		{700, 500, false}, // self-parented: stops instead of looping
+		{999, 500, false}, // unknown pid
+	}
+	for _, c := range cases {
+		if got := IsDescendant(c.pid, c.ancestor, parents); got != c.want {
+			t.Errorf("IsDescendant(%d, %d) = %v, want %v", c.pid, c.ancestor, got, c.want)
+		}
+	}
+}
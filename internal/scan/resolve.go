@@ -0,0 +1,106 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver is the subset of *net.Resolver used for reverse lookups.
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// ResolveHostnames fills Listener.Hostname from the host part of each
+// address. Loopback and wildcard binds are labeled without a lookup; other
+// hosts are looked up once each, concurrently, and the whole pass is bounded
+// by timeout so slow DNS can't hang the command.
+func ResolveHostnames(ctx context.Context, r Resolver, listeners []Listener, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	names := make(map[string]string)
+	var pending []string
+	for _, l := range listeners {
+		host := addressHost(l.Address)
+		if host == "" {
+			continue
+		}
+		if _, ok := names[host]; ok {
+			continue
+		}
+		if label, ok := staticHostLabel(host); ok {
+			names[host] = label
+			continue
+		}
+		names[host] = ""
+		pending = append(pending, host)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, host := range pending {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			found, err := r.LookupAddr(ctx, host)
+			if err != nil || len(found) == 0 {
+				return
+			}
+			mu.Lock()
+			names[host] = strings.TrimSuffix(found[0], ".")
+			mu.Unlock()
+		}(host)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Resolvers that ignore ctx may still be running; use what we have.
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range listeners {
+		if name := names[addressHost(listeners[i].Address)]; name != "" {
+			listeners[i].Hostname = name
+		}
+	}
+}
+
+// addressHost returns the host part of "host:port", "[v6]:port" or "*:port".
+func addressHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	return host
+}
+
+func staticHostLabel(host string) (string, bool) {
+	if host == "*" {
+		return "(any)", true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		// Already a name (lsof without -n); nothing to resolve.
+		return host, true
+	}
+	if ip.IsUnspecified() {
+		return "(any)", true
+	}
+	if ip.IsLoopback() {
+		return "localhost", true
+	}
+	return "", false
+}
@@ -0,0 +1,65 @@
+package scan
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeResolver struct {
+	names map[string]string
+	delay time.Duration
+	calls int32
+}
+
+func (f *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	atomic.AddInt32(&f.calls, 1)
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if name, ok := f.names[addr]; ok {
+		return []string{name + "."}, nil
+	}
+	return nil, nil
+}
+
+func TestResolveHostnames(t *testing.T) {
+	r := &fakeResolver{names: map[string]string{"192.168.1.10": "devbox.lan"}}
+	listeners := []Listener{
+		{Port: 3000, Address: "127.0.0.1:3000"},
+		{Port: 3001, Address: "*:3001"},
+		{Port: 3002, Address: "[::]:3002"},
+		{Port: 3003, Address: "192.168.1.10:3003"},
+		{Port: 3004, Address: "192.168.1.10:3004"},
+		{Port: 3005, Address: "10.0.0.9:3005"},
+	}
+
+	ResolveHostnames(context.Background(), r, listeners, time.Second)
+
+	want := []string{"localhost", "(any)", "(any)", "devbox.lan", "devbox.lan", ""}
+	for i, w := range want {
+		if listeners[i].Hostname != w {
+			t.Errorf("%s: expected hostname %q, got %q", listeners[i].Address, w, listeners[i].Hostname)
+		}
+	}
+	if r.calls != 2 {
+		t.Fatalf("expected one lookup per unique non-local host (2), got %d", r.calls)
+	}
+}
+
+func TestResolveHostnamesBoundsSlowDNS(t *testing.T) {
+	r := &fakeResolver{names: map[string]string{"192.168.1.10": "devbox.lan"}, delay: time.Minute}
+	listeners := []Listener{{Port: 3000, Address: "192.168.1.10:3000"}}
+
+	start := time.Now()
+	ResolveHostnames(context.Background(), r, listeners, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected resolution to be bounded, took %s", elapsed)
+	}
+	if listeners[0].Hostname != "" {
+		t.Fatalf("expected no hostname after timeout, got %q", listeners[0].Hostname)
+	}
+}
@@ -1,54 +1,397 @@
 package scan
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os/exec"
+	"slices"
+	"strings"
+	"time"
 )
 
 type Listener struct {
-	Port        int    `json:"port"`
-	PID         int    `json:"pid"`
-	PPID        int    `json:"ppid,omitempty"`
-	User        string `json:"user,omitempty"`
-	Command     string `json:"command,omitempty"`
-	CommandLine string `json:"command_line,omitempty"`
-	Executable  string `json:"executable,omitempty"`
-	CWD         string `json:"cwd,omitempty"`
-	Proto       string `json:"proto,omitempty"`
-	Address     string `json:"address,omitempty"`
+	Port        int        `json:"port"`
+	PID         int        `json:"pid"`
+	PPID        int        `json:"ppid,omitempty"`
+	User        string     `json:"user,omitempty"`
+	Command     string     `json:"command,omitempty"`
+	CommandLine string     `json:"command_line,omitempty"`
+	Executable  string     `json:"executable,omitempty"`
+	CWD         string     `json:"cwd,omitempty"`
+	Proto       string     `json:"proto,omitempty"`
+	Address     string     `json:"address,omitempty"`
+	RawAddress  string     `json:"raw_address,omitempty"`
+	Family      string     `json:"family,omitempty"`
+	Hostname    string     `json:"hostname,omitempty"`
+	Forwarding  string     `json:"forwarding,omitempty"`
+	Started     time.Time  `json:"started,omitzero"`
+	Mem         *SocketMem `json:"mem,omitempty"`
+	Backlog     *Backlog   `json:"backlog,omitempty"`
+	ShellJob    bool       `json:"shell_job,omitempty"`
+
+	// ConnectionCount is the number of established connections to the
+	// listener, set only when asked for since it takes another scan.
+	ConnectionCount *int `json:"connection_count,omitempty"`
+
+	// ExecutableDeleted is set when the binary has been deleted or replaced
+	// since the process started (Linux only), so it runs stale code.
+	ExecutableDeleted bool `json:"executable_deleted,omitempty"`
+}
+
+// Key identifies a listening socket independent of the process holding it,
+// e.g. "tcp 127.0.0.1:3000". It is used to compare scans over time.
+func (l Listener) Key() string {
+	proto := l.Proto
+	if proto == "" {
+		proto = "tcp"
+	}
+	addr := l.Address
+	if addr == "" {
+		addr = fmt.Sprintf("*:%d", l.Port)
+	}
+	return proto + " " + addr
+}
+
+// backend is an external tool that can enumerate TCP listeners. ListPort,
+// if set, asks the tool about a single port so it can skip the rest;
+// ListUDP lists bound UDP sockets. Command and UDPCommand are the argv List
+// and ListUDP run, for ExplainScan.
+type backend struct {
+	Name       string
+	Command    []string
+	UDPCommand []string
+	List       func(context.Context) ([]Listener, error)
+	ListPort   func(context.Context, int) ([]Listener, error)
+	ListUDP    func(context.Context) ([]Listener, error)
+}
+
+// backends are tried in order of preference.
+var backends = []backend{
+	{Name: "lsof", Command: lsofTCPCommand, UDPCommand: lsofUDPCommand, List: listTCPListenersViaLsof, ListPort: listPortViaLsof, ListUDP: listUDPListenersViaLsof},
+	{Name: "ss", Command: ssTCPCommand, UDPCommand: ssUDPCommand, List: listTCPListenersViaSS, ListPort: listPortViaSS, ListUDP: listUDPListenersViaSS},
+}
+
+var (
+	lookPath       = exec.LookPath
+	commandContext = exec.CommandContext
+)
+
+// runBackend runs argv and parses its stdout as it streams in, into
+// listeners or, for the connection scan, connections. A command that exits
+// non-zero without anything parsed fails with a *backendExitError; with
+// results, the exit status is ignored, since tools like lsof report
+// unreadable entries that way alongside good output.
+func runBackend[T any](ctx context.Context, argv []string, parse func(context.Context, io.Reader) ([]T, error)) ([]T, error) {
+	c := commandContext(ctx, argv[0], argv[1:]...)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	out, err := c.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+
+	listeners, err := parse(ctx, rawTee(strings.Join(argv, " "), out))
+	waitErr := c.Wait()
+	if err != nil || len(listeners) > 0 {
+		return listeners, err
+	}
+	var exitErr *exec.ExitError
+	if errors.As(waitErr, &exitErr) {
+		return nil, &backendExitError{Command: argv[0], Code: exitErr.ExitCode(), Stderr: strings.TrimSpace(stderr.String())}
+	}
+	return nil, waitErr
+}
+
+// backendExitError is a backend command that exited non-zero with no
+// listeners to show for it.
+type backendExitError struct {
+	Command string
+	Code    int
+	Stderr  string
+}
+
+func (e *backendExitError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("%s exited with status %d", e.Command, e.Code)
+	}
+	return fmt.Sprintf("%s exited with status %d: %s", e.Command, e.Code, e.Stderr)
+}
+
+// ScanPlan is a command a listing scan would run.
+type ScanPlan struct {
+	Backend string   `json:"backend"`
+	Command []string `json:"command"`
+}
+
+// ExplainScan returns the commands ListTCPListeners, or ListUDPListeners
+// with udp set, would try, without running them: the first is run, the
+// others only if it finds nothing or fails. ListTCPListenersAll runs them all.
+func ExplainScan(udp bool) ([]ScanPlan, error) {
+	available := availableBackends()
+	if len(available) == 0 {
+		return nil, errNoBackend
+	}
+	plans := make([]ScanPlan, 0, len(available))
+	for _, b := range available {
+		argv := b.Command
+		if udp {
+			argv = b.UDPCommand
+		}
+		plans = append(plans, ScanPlan{Backend: b.Name, Command: slices.Clone(argv)})
+	}
+	return plans, nil
+}
+
+// RawOutput, when set, receives each backend command's unparsed stdout as
+// it is read. It's a debugging aid for parser mismatches across tool
+// versions and is nil (off) by default.
+var RawOutput io.Writer
+
+// rawTee copies r to RawOutput under a header naming the command.
+func rawTee(command string, r io.Reader) io.Reader {
+	if RawOutput == nil {
+		return r
+	}
+	fmt.Fprintf(RawOutput, "--- raw output: %s ---\n", command)
+	return io.TeeReader(r, RawOutput)
+}
+
+var errNoBackend = errors.New("no supported port lister found (need `lsof` or `ss` in PATH)")
+
+func availableBackends() []backend {
+	var out []backend
+	for _, b := range backends {
+		if _, err := lookPath(b.Name); err == nil {
+			out = append(out, b)
+		}
+	}
+	return out
 }
 
 func ListTCPListeners(ctx context.Context) ([]Listener, error) {
-	if _, err := exec.LookPath("lsof"); err == nil {
-		return listTCPListenersViaLsof(ctx)
+	return listVia(ctx, func(b backend) func(context.Context) ([]Listener, error) { return b.List })
+}
+
+// ListUDPListeners lists bound, unconnected UDP sockets, the UDP analogue of
+// listening, with Proto "udp".
+func ListUDPListeners(ctx context.Context) ([]Listener, error) {
+	return listVia(ctx, func(b backend) func(context.Context) ([]Listener, error) { return b.ListUDP })
+}
+
+// listVia runs the primary backend's lister, falling back to the others
+// when it reports nothing or its command fails.
+func listVia(ctx context.Context, lister func(backend) func(context.Context) ([]Listener, error)) ([]Listener, error) {
+	available := availableBackends()
+	if len(available) == 0 {
+		return nil, errNoBackend
+	}
+	primary := available[0]
+	listeners, err := lister(primary)(ctx)
+	var exitErr *backendExitError
+	if (err != nil && !errors.As(err, &exitErr)) || len(listeners) > 0 {
+		return listeners, err
+	}
+
+	// Zero listeners with a clean exit usually means the tool printed
+	// something we couldn't parse (locale, column layout), so ask the others.
+	for _, b := range available[1:] {
+		fallback, ferr := lister(b)(ctx)
+		if ferr != nil || len(fallback) == 0 {
+			continue
+		}
+		if err != nil {
+			warnf("%v; using %s instead", err, b.Name)
+		} else {
+			warnf("%s returned no listeners; using %s instead", primary.Name, b.Name)
+		}
+		return fallback, nil
 	}
-	if _, err := exec.LookPath("ss"); err == nil {
-		return listTCPListenersViaSS(ctx)
+	return listeners, err
+}
+
+// Warn, when set, receives non-fatal scan warnings such as a backend
+// fallback.
+var Warn func(msg string)
+
+func warnf(format string, args ...any) {
+	if Warn != nil {
+		Warn(fmt.Sprintf(format, args...))
 	}
-	return nil, errors.New("no supported port lister found (need `lsof` or `ss` in PATH)")
 }
 
-func HasTCPListenerOnPort(ctx context.Context, port int) (bool, error) {
-	listeners, err := ListTCPListeners(ctx)
+// BackendResult records how one backend fared in ListTCPListenersAll.
+type BackendResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Count int    `json:"count"`
+	Error string `json:"error,omitempty"`
+	// Partial is set when the backend was cut off mid-stream; its Count
+	// listeners are included in the merge but the set may be incomplete.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// ListTCPListenersAll runs every available backend and merges their results,
+// so one failing tool doesn't hide what the others can see. Listeners are
+// deduplicated by port and PID; it only fails if every backend fails. A
+// backend cut off by ctx still contributes what it parsed, marked Partial.
+func ListTCPListenersAll(ctx context.Context) ([]Listener, []BackendResult, error) {
+	available := availableBackends()
+	if len(available) == 0 {
+		return nil, nil, errNoBackend
+	}
+
+	var merged []Listener
+	var results []BackendResult
+	var errs []error
+	// One process may hold a port on several sockets (0.0.0.0 and [::], or
+	// two bind addresses), so a listener is its socket plus its PID.
+	type socketPID struct {
+		key string
+		pid int
+	}
+	seen := make(map[socketPID]bool)
+	seenPort := make(map[int]bool)
+	for _, b := range available {
+		listeners, err := b.List(ctx)
+		partial := err != nil && len(listeners) > 0 && ctx.Err() != nil
+		switch {
+		case partial:
+			results = append(results, BackendResult{Name: b.Name, Count: len(listeners), Error: err.Error(), Partial: true})
+		case err != nil:
+			results = append(results, BackendResult{Name: b.Name, Error: err.Error()})
+			errs = append(errs, fmt.Errorf("%s: %w", b.Name, err))
+			continue
+		default:
+			results = append(results, BackendResult{Name: b.Name, OK: true, Count: len(listeners)})
+		}
+		for _, l := range listeners {
+			// A PID-less entry (ss without root) adds nothing once another
+			// backend has reported the port.
+			key := socketPID{l.Key(), l.PID}
+			if seen[key] || (l.PID == 0 && seenPort[l.Port]) {
+				continue
+			}
+			seen[key] = true
+			seenPort[l.Port] = true
+			merged = append(merged, l)
+		}
+	}
+	if len(errs) == len(available) {
+		return nil, results, errors.Join(errs...)
+	}
+	return merged, results, nil
+}
+
+// QueryTCPPort asks the primary backend about port alone (lsof -iTCP:<port>,
+// ss sport = :<port>) instead of listing everything. It never falls back to
+// a full scan, so an error from the scoped query is returned as is.
+func QueryTCPPort(ctx context.Context, port int) ([]Listener, error) {
+	available := availableBackends()
+	if len(available) == 0 {
+		return nil, errNoBackend
+	}
+	return queryPortVia(ctx, available[0], port)
+}
+
+func queryPortVia(ctx context.Context, b backend, port int) ([]Listener, error) {
+	var listeners []Listener
+	var err error
+	if b.ListPort != nil {
+		listeners, err = b.ListPort(ctx, port)
+	} else {
+		listeners, err = b.List(ctx)
+	}
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	return onPort(listeners, port), nil
+}
+
+// ListTCPListenersOnPort is QueryTCPPort, falling back to a full scan if the
+// scoped query fails (e.g. a tool too old for the port filter). An empty
+// answer is checked with the other backends, as listVia does: lsof without
+// root doesn't see other users' sockets, which ss still reports.
+func ListTCPListenersOnPort(ctx context.Context, port int) ([]Listener, error) {
+	listeners, err := QueryTCPPort(ctx, port)
+	if err == nil && len(listeners) == 0 {
+		available := availableBackends()
+		for _, b := range available[1:] {
+			fallback, ferr := queryPortVia(ctx, b, port)
+			if ferr != nil || len(fallback) == 0 {
+				continue
+			}
+			warnf("%s found nothing on port %d; using %s instead", available[0].Name, port, b.Name)
+			return fallback, nil
+		}
+	}
+	if err == nil || errors.Is(err, errNoBackend) || ctx.Err() != nil {
+		return listeners, err
 	}
+	warnf("port query failed (%v); scanning all listeners", err)
+	all, err := ListTCPListeners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return onPort(all, port), nil
+}
+
+func onPort(listeners []Listener, port int) []Listener {
+	var out []Listener
 	for _, l := range listeners {
 		if l.Port == port {
-			return true, nil
+			out = append(out, l)
 		}
 	}
-	return false, nil
+	return out
+}
+
+func HasTCPListenerOnPort(ctx context.Context, port int) (bool, error) {
+	listeners, err := ListTCPListenersOnPort(ctx, port)
+	if err != nil {
+		return false, err
+	}
+	return len(listeners) > 0, nil
 }
 
 func WriteJSON(w io.Writer, v any) error {
-	enc := json.NewEncoder(w)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(v); err != nil {
+	return WriteJSONOpts(w, v, JSONOptions{Indent: "  ", TrailingNewline: true})
+}
+
+// JSONOptions controls WriteJSONOpts formatting.
+type JSONOptions struct {
+	Indent          string // empty for compact single-line output
+	TrailingNewline bool
+}
+
+func WriteJSONOpts(w io.Writer, v any, opts JSONOptions) error {
+	var data []byte
+	var err error
+	if opts.Indent != "" {
+		data, err = json.MarshalIndent(v, "", opts.Indent)
+	} else {
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+	if opts.TrailingNewline {
+		data = append(data, '\n')
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteJSONLine writes v as a single compact JSON object followed by a
+// newline (NDJSON), for log pipelines and streaming consumers.
+func WriteJSONLine(w io.Writer, v any) error {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
 		return fmt.Errorf("encode json: %w", err)
 	}
 	return nil
@@ -0,0 +1,417 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestListTCPListenersAllFallsThroughFailingPrimary(t *testing.T) {
+	stubBackends(t,
+		backend{Name: "lsof", List: func(context.Context) ([]Listener, error) {
+			return nil, errors.New("lsof exploded")
+		}},
+		backend{Name: "ss", List: func(context.Context) ([]Listener, error) {
+			return []Listener{{Port: 3000, PID: 10, Command: "node", Proto: "tcp"}}, nil
+		}},
+	)
+
+	listeners, results, err := ListTCPListenersAll(context.Background())
+	if err != nil {
+		t.Fatalf("expected success with a working secondary, got %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].Port != 3000 {
+		t.Fatalf("expected secondary listeners, got %+v", listeners)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 backend results, got %+v", results)
+	}
+	if results[0].OK || results[0].Error == "" {
+		t.Fatalf("expected lsof failure recorded, got %+v", results[0])
+	}
+	if !results[1].OK || results[1].Count != 1 {
+		t.Fatalf("expected ss success recorded, got %+v", results[1])
+	}
+}
+
+func TestListTCPListenersAllMergesAndDedupes(t *testing.T) {
+	stubBackends(t,
+		backend{Name: "lsof", List: func(context.Context) ([]Listener, error) {
+			return []Listener{{Port: 3000, PID: 10}, {Port: 5432, PID: 20}}, nil
+		}},
+		backend{Name: "ss", List: func(context.Context) ([]Listener, error) {
+			return []Listener{{Port: 3000, PID: 10}, {Port: 5432, PID: 0}, {Port: 8080, PID: 0}}, nil
+		}},
+	)
+
+	listeners, _, err := ListTCPListenersAll(context.Background())
+	if err != nil {
+		t.Fatalf("ListTCPListenersAll: %v", err)
+	}
+	if len(listeners) != 3 {
+		t.Fatalf("expected 3 merged listeners, got %+v", listeners)
+	}
+}
+
+func TestListTCPListenersAllKeepsEverySocketOfAProcess(t *testing.T) {
+	dual := []Listener{
+		{Port: 3000, PID: 10, Address: "0.0.0.0:3000"},
+		{Port: 3000, PID: 10, Address: "[::]:3000"},
+		{Port: 3000, PID: 10, Address: "127.0.0.2:3000"},
+	}
+	stubBackends(t,
+		backend{Name: "lsof", List: func(context.Context) ([]Listener, error) { return dual, nil }},
+		backend{Name: "ss", List: func(context.Context) ([]Listener, error) { return dual, nil }},
+	)
+
+	listeners, _, err := ListTCPListenersAll(context.Background())
+	if err != nil {
+		t.Fatalf("ListTCPListenersAll: %v", err)
+	}
+	if len(listeners) != 3 {
+		t.Fatalf("expected each socket once, got %+v", listeners)
+	}
+}
+
+func TestListTCPListenersAllFailsWhenEveryBackendFails(t *testing.T) {
+	fail := func(context.Context) ([]Listener, error) { return nil, errors.New("boom") }
+	stubBackends(t, backend{Name: "lsof", List: fail}, backend{Name: "ss", List: fail})
+
+	if _, results, err := ListTCPListenersAll(context.Background()); err == nil {
+		t.Fatalf("expected error when all backends fail")
+	} else if len(results) != 2 {
+		t.Fatalf("expected results for both backends, got %+v", results)
+	}
+}
+
+func TestListTCPListenersFallsBackWhenPrimaryIsEmpty(t *testing.T) {
+	stubBackends(t,
+		backend{Name: "ss", List: func(context.Context) ([]Listener, error) {
+			return nil, nil
+		}},
+		backend{Name: "lsof", List: func(context.Context) ([]Listener, error) {
+			return []Listener{{Port: 3000, PID: 10, Command: "node", Proto: "tcp"}}, nil
+		}},
+	)
+	var warnings []string
+	Warn = func(msg string) { warnings = append(warnings, msg) }
+	t.Cleanup(func() { Warn = nil })
+
+	listeners, err := ListTCPListeners(context.Background())
+	if err != nil {
+		t.Fatalf("ListTCPListeners: %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].Port != 3000 {
+		t.Fatalf("expected lsof results, got %+v", listeners)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "ss returned no listeners") {
+		t.Fatalf("expected a fallback warning, got %v", warnings)
+	}
+}
+
+func TestListTCPListenersEmptyEverywhereIsNotAnError(t *testing.T) {
+	empty := func(context.Context) ([]Listener, error) { return nil, nil }
+	stubBackends(t, backend{Name: "ss", List: empty}, backend{Name: "lsof", List: empty})
+
+	listeners, err := ListTCPListeners(context.Background())
+	if err != nil || len(listeners) != 0 {
+		t.Fatalf("expected empty result, got %+v (err=%v)", listeners, err)
+	}
+}
+
+func TestListTCPListenersAllKeepsPartialResults(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stubBackends(t,
+		backend{Name: "lsof", List: func(ctx context.Context) ([]Listener, error) {
+			cancel() // the deadline fires mid-stream
+			return []Listener{{Port: 3000, PID: 10, Proto: "tcp"}}, ctx.Err()
+		}},
+		backend{Name: "ss", List: func(ctx context.Context) ([]Listener, error) {
+			return nil, ctx.Err()
+		}},
+	)
+
+	listeners, results, err := ListTCPListenersAll(ctx)
+	if err != nil {
+		t.Fatalf("expected partial success, got %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].Port != 3000 {
+		t.Fatalf("expected the partial listener, got %+v", listeners)
+	}
+	if !results[0].Partial || results[0].OK || results[0].Count != 1 {
+		t.Fatalf("expected lsof marked partial, got %+v", results[0])
+	}
+	if results[1].Partial || results[1].Error == "" {
+		t.Fatalf("expected ss to fail outright, got %+v", results[1])
+	}
+}
+
+func TestRawTeeCapturesBackendOutput(t *testing.T) {
+	var raw bytes.Buffer
+	RawOutput = &raw
+	t.Cleanup(func() { RawOutput = nil })
+
+	input := "LISTEN 0 4096 127.0.0.1:3000 0.0.0.0:* users:((\"node\",pid=12345,fd=22))\ngarbage the parser skips\n"
+	listeners, err := parseSSOutput(context.Background(), rawTee("ss -ltnpH", strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("parseSSOutput: %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].Port != 3000 {
+		t.Fatalf("unexpected parse: %+v", listeners)
+	}
+	want := "--- raw output: ss -ltnpH ---\n" + input
+	if raw.String() != want {
+		t.Fatalf("raw output = %q, want %q", raw.String(), want)
+	}
+}
+
+func TestQueryTCPPortUsesScopedQueryOnly(t *testing.T) {
+	fullScans := 0
+	stubBackends(t, backend{
+		Name: "lsof",
+		List: func(context.Context) ([]Listener, error) {
+			fullScans++
+			return []Listener{{Port: 8080, PID: 1}}, nil
+		},
+		ListPort: func(_ context.Context, port int) ([]Listener, error) {
+			if port != 8080 {
+				t.Fatalf("scoped query for port %d, want 8080", port)
+			}
+			return []Listener{{Port: 8080, PID: 7}, {Port: 18080, PID: 9}}, nil
+		},
+	})
+
+	listeners, err := QueryTCPPort(context.Background(), 8080)
+	if err != nil {
+		t.Fatalf("QueryTCPPort: %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].PID != 7 || fullScans != 0 {
+		t.Fatalf("expected only the scoped match, got %+v after %d full scans", listeners, fullScans)
+	}
+}
+
+func TestListTCPListenersOnPortFallsBackToFullScan(t *testing.T) {
+	stubBackends(t, backend{
+		Name: "ss",
+		List: func(context.Context) ([]Listener, error) {
+			return []Listener{{Port: 3000, PID: 1}, {Port: 8080, PID: 2}}, nil
+		},
+		ListPort: func(context.Context, int) ([]Listener, error) {
+			return nil, errors.New("filter not supported")
+		},
+	})
+
+	if _, err := QueryTCPPort(context.Background(), 8080); err == nil {
+		t.Fatalf("expected QueryTCPPort to surface the scoped error")
+	}
+	listeners, err := ListTCPListenersOnPort(context.Background(), 8080)
+	if err != nil {
+		t.Fatalf("ListTCPListenersOnPort: %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].PID != 2 {
+		t.Fatalf("expected the full scan's match, got %+v", listeners)
+	}
+}
+
+// BenchmarkSinglePortCheck compares the scoped query behind check/who --fast
+// with filtering a full scan, using whichever real backend is installed.
+func BenchmarkSinglePortCheck(b *testing.B) {
+	if len(availableBackends()) == 0 {
+		b.Skip(errNoBackend)
+	}
+	ctx := context.Background()
+	const port = 8080
+	b.Run("fast", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := QueryTCPPort(ctx, port); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("full", func(b *testing.B) {
+		for b.Loop() {
+			listeners, err := ListTCPListeners(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_ = onPort(listeners, port)
+		}
+	})
+}
+
+func TestExplainScanMatchesExecutedCommands(t *testing.T) {
+	// The real backends, all "installed", each printing nothing so the scan
+	// tries every one in turn.
+	stubBackends(t, backends...)
+	var ran [][]string
+	origCommand := commandContext
+	commandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		ran = append(ran, append([]string{name}, args...))
+		return exec.CommandContext(ctx, "true")
+	}
+	t.Cleanup(func() { commandContext = origCommand })
+
+	for _, udp := range []bool{false, true} {
+		ran = nil
+		plans, err := ExplainScan(udp)
+		if err != nil {
+			t.Fatalf("ExplainScan(%v): %v", udp, err)
+		}
+		list := ListTCPListeners
+		if udp {
+			list = ListUDPListeners
+		}
+		if _, err := list(context.Background()); err != nil {
+			t.Fatalf("list (udp=%v): %v", udp, err)
+		}
+		if len(ran) != len(plans) {
+			t.Fatalf("udp=%v: explained %d commands, ran %v", udp, len(plans), ran)
+		}
+		for i, p := range plans {
+			if !slices.Equal(p.Command, ran[i]) || p.Command[0] != p.Backend {
+				t.Fatalf("udp=%v: explained %s %v, ran %v", udp, p.Backend, p.Command, ran[i])
+			}
+		}
+	}
+}
+
+// stubCommand makes every backend command run script under sh instead.
+func stubCommand(t *testing.T, script string) {
+	t.Helper()
+	orig := commandContext
+	commandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "/bin/sh", "-c", script)
+	}
+	t.Cleanup(func() { commandContext = orig })
+}
+
+func TestRunBackendReportsFailedExit(t *testing.T) {
+	stubCommand(t, "echo 'Cannot open netlink socket: Permission denied' >&2; exit 2")
+	listeners, err := listTCPListenersViaSS(context.Background())
+	var exitErr *backendExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 2 || len(listeners) != 0 {
+		t.Fatalf("expected a failed exit, got %+v (err=%v)", listeners, err)
+	}
+	if !strings.Contains(err.Error(), "ss exited with status 2: Cannot open netlink socket") {
+		t.Fatalf("expected the exit status and stderr in %q", err)
+	}
+
+	// Listeners that did come out are kept despite the status.
+	stubCommand(t, "echo 'LISTEN 0 4096 127.0.0.1:3000 0.0.0.0:* users:((\"node\",pid=12345,fd=22))'; exit 1")
+	if listeners, err := listTCPListenersViaSS(context.Background()); err != nil || len(listeners) != 1 {
+		t.Fatalf("expected the parsed listener, got %+v (err=%v)", listeners, err)
+	}
+}
+
+func TestRunLsofTreatsNoMatchAsEmpty(t *testing.T) {
+	// lsof exits 1 when nothing matches, possibly with warnings.
+	stubCommand(t, "echo 'lsof: WARNING: can not stat() fuse file system /run/user/1000/doc' >&2; exit 1")
+	if listeners, err := listPortViaLsof(context.Background(), 3000); err != nil || len(listeners) != 0 {
+		t.Fatalf("expected an empty result, got %+v (err=%v)", listeners, err)
+	}
+	stubCommand(t, "exit 1")
+	if listeners, err := listTCPListenersViaLsof(context.Background()); err != nil || len(listeners) != 0 {
+		t.Fatalf("expected an empty result, got %+v (err=%v)", listeners, err)
+	}
+
+	stubCommand(t, "echo 'lsof: unsupported option: -sTCP:LISTEN' >&2; exit 1")
+	if _, err := listPortViaLsof(context.Background(), 3000); err == nil || !strings.Contains(err.Error(), "unsupported option") {
+		t.Fatalf("expected an lsof error, got %v", err)
+	}
+}
+
+func TestSideScansReportFailedExit(t *testing.T) {
+	if _, err := lookPath("ss"); err != nil {
+		t.Skip("ss not installed")
+	}
+	stubCommand(t, "echo 'Cannot open netlink socket: Permission denied' >&2; exit 2")
+	if _, err := connectionsViaSS(context.Background(), 3000); err == nil || !strings.Contains(err.Error(), "netlink") {
+		t.Fatalf("connections: expected the ss failure, got %v", err)
+	}
+	if err := AddSocketMem(context.Background(), 3000, []Listener{{Port: 3000}}); err == nil {
+		t.Fatal("socket memory: expected the ss failure")
+	}
+	if err := AddBacklog(context.Background(), []Listener{{Port: 3000}}); err == nil {
+		t.Fatal("backlog: expected the ss failure")
+	}
+
+	// No established connection is lsof's exit 1, not an error.
+	stubCommand(t, "exit 1")
+	if conns, err := connectionsViaLsof(context.Background(), 3000); err != nil || len(conns) != 0 {
+		t.Fatalf("expected no connections, got %+v (err=%v)", conns, err)
+	}
+}
+
+func TestListTCPListenersOnPortAsksOtherBackendsWhenEmpty(t *testing.T) {
+	// lsof without root misses another user's socket that ss reports
+	// without a PID.
+	stubBackends(t,
+		backend{Name: "lsof", ListPort: func(context.Context, int) ([]Listener, error) { return nil, nil }},
+		backend{Name: "ss", ListPort: func(_ context.Context, port int) ([]Listener, error) {
+			return []Listener{{Port: port}}, nil
+		}},
+	)
+	if busy, err := HasTCPListenerOnPort(context.Background(), 5432); err != nil || !busy {
+		t.Fatalf("expected ss's PID-less row to count as busy, got %v (err=%v)", busy, err)
+	}
+
+	backends[1].ListPort = func(context.Context, int) ([]Listener, error) { return nil, nil }
+	if busy, err := HasTCPListenerOnPort(context.Background(), 5432); err != nil || busy {
+		t.Fatalf("expected a free port when no backend sees it, got %v (err=%v)", busy, err)
+	}
+}
+
+func TestListViaFallsBackFromFailedPrimary(t *testing.T) {
+	failed := &backendExitError{Command: "lsof", Code: 2, Stderr: "boom"}
+	stubBackends(t,
+		backend{Name: "lsof", List: func(context.Context) ([]Listener, error) { return nil, failed }},
+		backend{Name: "ss", List: func(context.Context) ([]Listener, error) {
+			return []Listener{{Port: 3000, PID: 10}}, nil
+		}},
+	)
+	if listeners, err := ListTCPListeners(context.Background()); err != nil || len(listeners) != 1 {
+		t.Fatalf("expected ss's listeners, got %+v (err=%v)", listeners, err)
+	}
+
+	backends[1].List = func(context.Context) ([]Listener, error) { return nil, nil }
+	if _, err := ListTCPListeners(context.Background()); !errors.Is(err, failed) {
+		t.Fatalf("expected the primary's failure when no backend does better, got %v", err)
+	}
+}
+
+func stubBackends(t *testing.T, bs ...backend) {
+	t.Helper()
+	origBackends, origLookPath := backends, lookPath
+	backends = bs
+	lookPath = func(name string) (string, error) { return "/usr/bin/" + name, nil }
+	t.Cleanup(func() {
+		backends, lookPath = origBackends, origLookPath
+	})
+}
+
+func TestParseConnections(t *testing.T) {
+	ssOut := "0      0      127.0.0.1:18080 127.0.0.1:32784 users:((\"python3\",pid=18483,fd=5))\n" +
+		"0      0      [::1]:18080 [::1]:40000 users:((\"node\",pid=7,fd=9))\n"
+	conns, err := parseConnections(strings.NewReader(ssOut), 18080, parseSSConnLine)
+	if err != nil || len(conns) != 2 {
+		t.Fatalf("ss: got %+v (err=%v)", conns, err)
+	}
+	if conns[0] != (Connection{Local: "127.0.0.1:18080", Remote: "127.0.0.1:32784", PID: 18483, Command: "python3"}) || conns[1].Remote != "[::1]:40000" {
+		t.Fatalf("ss: unexpected connections %+v", conns)
+	}
+
+	lsofOut := "COMMAND   PID USER   FD   TYPE DEVICE SIZE/OFF NODE NAME\n" +
+		"python3 18483 root    4u  IPv4 275987      0t0  TCP 127.0.0.1:32784->127.0.0.1:18080 (ESTABLISHED)\n" +
+		"python3 18483 root    5u  IPv4 275988      0t0  TCP 127.0.0.1:18080->127.0.0.1:32784 (ESTABLISHED)\n"
+	conns, err = parseConnections(strings.NewReader(lsofOut), 18080, parseLsofConnLine)
+	if err != nil || len(conns) != 1 {
+		t.Fatalf("lsof: expected only the server side, got %+v (err=%v)", conns, err)
+	}
+	if conns[0] != (Connection{Local: "127.0.0.1:18080", Remote: "127.0.0.1:32784", PID: 18483, Command: "python3"}) {
+		t.Fatalf("lsof: unexpected connection %+v", conns[0])
+	}
+}
@@ -0,0 +1,78 @@
+package scan
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SocketMem is a socket's memory accounting as reported by `ss -m`, in bytes
+// except Drops. Only ss provides it; lsof-only systems leave Listener.Mem nil.
+type SocketMem struct {
+	RmemAlloc  int64 `json:"rmem_alloc"`  // r: receive queue memory in use
+	RcvBuf     int64 `json:"rcvbuf"`      // rb: receive buffer size (SO_RCVBUF)
+	WmemAlloc  int64 `json:"wmem_alloc"`  // t: send queue memory in use
+	SndBuf     int64 `json:"sndbuf"`      // tb: send buffer size (SO_SNDBUF)
+	FwdAlloc   int64 `json:"fwd_alloc"`   // f: memory reserved but not yet used
+	WmemQueued int64 `json:"wmem_queued"` // w: memory queued for sending
+	OptMem     int64 `json:"optmem"`      // o: socket option memory
+	Backlog    int64 `json:"backlog"`     // bl: backlog queue memory
+	Drops      int64 `json:"drops"`       // d: packets dropped before reaching the socket
+}
+
+var ssSkmem = regexp.MustCompile(`skmem:\(([^)]*)\)`)
+
+// parseSkmem extracts the skmem:(...) block from an ss -m line. Unknown keys
+// are ignored, so newer ss versions that add fields still parse.
+func parseSkmem(line string) (*SocketMem, bool) {
+	m := ssSkmem.FindStringSubmatch(line)
+	if len(m) != 2 {
+		return nil, false
+	}
+	mem := &SocketMem{}
+	fields := map[string]*int64{
+		"r": &mem.RmemAlloc, "rb": &mem.RcvBuf,
+		"t": &mem.WmemAlloc, "tb": &mem.SndBuf,
+		"f": &mem.FwdAlloc, "w": &mem.WmemQueued,
+		"o": &mem.OptMem, "bl": &mem.Backlog, "d": &mem.Drops,
+	}
+	for _, kv := range strings.Split(m[1], ",") {
+		i := strings.IndexFunc(kv, func(r rune) bool { return r >= '0' && r <= '9' })
+		if i <= 0 {
+			continue
+		}
+		dst, ok := fields[kv[:i]]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(kv[i:], 10, 64)
+		if err != nil {
+			continue
+		}
+		*dst = n
+	}
+	return mem, true
+}
+
+// AddSocketMem fills Mem on each listener on port from `ss -m`, matching by
+// address and PID. It does nothing when ss isn't installed, since lsof has
+// no equivalent.
+func AddSocketMem(ctx context.Context, port int, listeners []Listener) error {
+	if _, err := lookPath("ss"); err != nil {
+		return nil
+	}
+	withMem, err := runBackend(ctx, []string{"ss", "-ltnpmH", "sport", "=", ":" + strconv.Itoa(port)}, parseSSOutput)
+	if err != nil {
+		return err
+	}
+	for i := range listeners {
+		for _, m := range withMem {
+			if m.Mem != nil && m.Key() == listeners[i].Key() && (m.PID == 0 || m.PID == listeners[i].PID) {
+				listeners[i].Mem = m.Mem
+				break
+			}
+		}
+	}
+	return nil
+}
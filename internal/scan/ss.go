@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"context"
 	"io"
-	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
@@ -13,37 +12,65 @@ import (
 var ssPid = regexp.MustCompile(`pid=(\d+)`)
 var ssProc = regexp.MustCompile(`\"([^\"]+)\"`)
 
+// Commands for the full ss listings.
+var (
+	ssTCPCommand = []string{"ss", "-ltnpH"}
+	ssUDPCommand = []string{"ss", "-lunpH"}
+)
+
 func listTCPListenersViaSS(ctx context.Context) ([]Listener, error) {
 	// Example:
 	// LISTEN 0 4096 127.0.0.1:3000 0.0.0.0:* users:(("node",pid=12345,fd=22))
-	c := exec.CommandContext(ctx, "ss", "-ltnpH")
-	out, err := c.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-	if err := c.Start(); err != nil {
-		return nil, err
-	}
-	defer c.Wait()
+	return runBackend(ctx, ssTCPCommand, parseSSOutput)
+}
 
-	listeners, err := parseSSOutput(out)
-	if err != nil {
-		return nil, err
+// listUDPListenersViaSS lists unconnected (bound) UDP sockets, ss's
+// equivalent of listening for UDP.
+func listUDPListenersViaSS(ctx context.Context) ([]Listener, error) {
+	listeners, err := runBackend(ctx, ssUDPCommand, parseSSOutput)
+	for i := range listeners {
+		// UDP's queues hold datagram bytes, not pending connections.
+		listeners[i].Proto = "udp"
+		listeners[i].Backlog = nil
 	}
-	return listeners, nil
+	return listeners, err
 }
 
-func parseSSOutput(r io.Reader) ([]Listener, error) {
+// listPortViaSS uses an ss filter expression so only sockets on port are
+// reported.
+func listPortViaSS(ctx context.Context, port int) ([]Listener, error) {
+	return runBackend(ctx, []string{"ss", "-ltnpH", "sport", "=", ":" + strconv.Itoa(port)}, parseSSOutput)
+}
+
+// parseSSOutput stops early if ctx is done, returning the listeners parsed so far
+// along with the context error so callers can choose to use them.
+func parseSSOutput(ctx context.Context, r io.Reader) ([]Listener, error) {
 	var listeners []Listener
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return listeners, err
+		}
 		line := scanner.Text()
+		// With -m, ss prints skmem:(...) on an indented continuation line
+		// belonging to the socket above it.
+		if mem, ok := parseSkmem(line); ok && strings.TrimLeft(line, " \t") != line {
+			if len(listeners) > 0 {
+				listeners[len(listeners)-1].Mem = mem
+			}
+			continue
+		}
 		listener, ok := parseSSLine(line)
 		if !ok {
 			continue
 		}
+		listener.Mem, _ = parseSkmem(line)
 		listeners = append(listeners, listener)
 	}
+	if err := ctx.Err(); err != nil {
+		// The tool was killed mid-stream; the read error is just fallout.
+		return listeners, err
+	}
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
@@ -56,10 +83,11 @@ func parseSSLine(line string) (Listener, bool) {
 		return Listener{}, false
 	}
 
-	local, ok := extractSSLocal(fields)
+	at, ok := ssLocalIndex(fields)
 	if !ok {
 		return Listener{}, false
 	}
+	local := fields[at]
 
 	p, ok := parsePortFromAddress(local)
 	if !ok {
@@ -76,27 +104,38 @@ func parseSSLine(line string) (Listener, bool) {
 		cmdName = cm[1]
 	}
 
-	return Listener{
+	l := Listener{
 		Port:    p,
 		PID:     pid,
 		Command: cmdName,
 		Proto:   "tcp",
-		Address: local,
-	}, true
+	}
+	l.setAddress(local)
+	// For a listening socket, Recv-Q is the accept queue and Send-Q its
+	// limit. ssLocalIndex has checked that both are counts.
+	queued, _ := strconv.Atoi(fields[at-2])
+	limit, _ := strconv.Atoi(fields[at-1])
+	l.Backlog = &Backlog{Queued: queued, Max: limit}
+	return l, true
 }
 
-func extractSSLocal(fields []string) (string, bool) {
-	// ss output usually: State Recv-Q Send-Q Local Address:Port Peer Address:Port
-	// After splitting, Local is often fields[3] but may appear later when fields vary.
-	if len(fields) > 3 && strings.Contains(fields[3], ":") {
-		return fields[3], true
-	}
-	for i := 0; i < len(fields); i++ {
-		if strings.Contains(fields[i], ":") && !strings.Contains(fields[i], "users:") {
-			return fields[i], true
+// ssLocalIndex finds the local address by its position after the Recv-Q
+// and Send-Q counts rather than by a fixed column. That holds whether or not
+// ss prints the Netid and State columns (-A, state filters) and whatever
+// trails the peer address (-e, -i, -o, -O), and it rejects the indented
+// detail lines -i and -m print, which carry no queue counts.
+func ssLocalIndex(fields []string) (int, bool) {
+	for i := 0; i+2 < len(fields); i++ {
+		if isCount(fields[i]) && isCount(fields[i+1]) && strings.Contains(fields[i+2], ":") {
+			return i + 2, true
 		}
 	}
-	return "", false
+	return 0, false
+}
+
+func isCount(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
 }
 
 func parsePortFromAddress(addr string) (int, bool) {
@@ -1,6 +1,9 @@
 package scan
 
 import (
+	"context"
+	"errors"
+	"io"
 	"strings"
 	"testing"
 )
@@ -13,7 +16,7 @@ LISTEN 0 4096 0.0.0.0:22 0.0.0.0:* users:(("sshd",pid=1,fd=3))
 LISTEN 0 128 [::]:443 [::]:* users:(("nginx",pid=2000,fd=9))
 `)
 
-	listeners, err := parseSSOutput(strings.NewReader(input))
+	listeners, err := parseSSOutput(context.Background(), strings.NewReader(input))
 	if err != nil {
 		t.Fatalf("parseSSOutput error: %v", err)
 	}
@@ -45,3 +48,139 @@ func TestParseSSLineWithoutProcessInfo(t *testing.T) {
 	}
 }
 
+// lineReader hands out one line per Read and runs hook before the nth.
+type lineReader struct {
+	lines []string
+	n     int
+	hook  func()
+}
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	if len(r.lines) == 0 {
+		return 0, io.EOF
+	}
+	r.n--
+	if r.n == 0 {
+		r.hook()
+	}
+	line := r.lines[0]
+	r.lines = r.lines[1:]
+	return copy(p, line+"\n"), nil
+}
+
+func TestParseSSOutputReturnsPartialOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &lineReader{
+		lines: []string{
+			`LISTEN 0 4096 127.0.0.1:3000 0.0.0.0:* users:(("node",pid=1,fd=22))`,
+			`LISTEN 0 4096 127.0.0.1:3001 0.0.0.0:* users:(("node",pid=2,fd=22))`,
+			`LISTEN 0 4096 127.0.0.1:3002 0.0.0.0:* users:(("node",pid=3,fd=22))`,
+		},
+		n:    3,
+		hook: cancel,
+	}
+
+	listeners, err := parseSSOutput(ctx, r)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(listeners) != 2 || listeners[1].Port != 3001 {
+		t.Fatalf("expected the 2 listeners parsed before cancel, got %+v", listeners)
+	}
+}
+
+func TestParseSSOutputWithSkmem(t *testing.T) {
+	input := "LISTEN 0 4096 127.0.0.1:3000 0.0.0.0:* users:((\"node\",pid=12345,fd=22))\n" +
+		"\t skmem:(r0,rb131072,t0,tb16384,f0,w0,o0,bl0,d3)\n" +
+		"LISTEN 0 128 [::1]:6379 [::]:* users:((\"redis-server\",pid=555,fd=7))\n"
+
+	listeners, err := parseSSOutput(context.Background(), strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseSSOutput error: %v", err)
+	}
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(listeners))
+	}
+	assertListener(t, listeners[0], 3000, 12345, "", "node", "127.0.0.1:3000")
+
+	want := SocketMem{RcvBuf: 131072, SndBuf: 16384, Drops: 3}
+	if listeners[0].Mem == nil || *listeners[0].Mem != want {
+		t.Fatalf("expected skmem %+v, got %+v", want, listeners[0].Mem)
+	}
+	if listeners[1].Mem != nil {
+		t.Fatalf("expected no skmem for the second socket, got %+v", listeners[1].Mem)
+	}
+}
+
+func TestParseSSOutputBacklog(t *testing.T) {
+	input := "LISTEN 5 4 127.0.0.1:3000 0.0.0.0:* users:((\"node\",pid=12345,fd=22))\n" +
+		"tcp LISTEN 0 0 [::1]:6379 [::]:*\n"
+
+	listeners, err := parseSSOutput(context.Background(), strings.NewReader(input))
+	if err != nil || len(listeners) != 2 {
+		t.Fatalf("parseSSOutput = %+v, %v", listeners, err)
+	}
+	if b := listeners[0].Backlog; b == nil || *b != (Backlog{Queued: 5, Max: 4}) || b.Percent() != 125 {
+		t.Fatalf("expected backlog 5/4, got %+v", b)
+	}
+	if b := listeners[1].Backlog; b == nil || b.Percent() != 0 {
+		t.Fatalf("expected an empty queue with no limit to report 0%%, got %+v", b)
+	}
+}
+
+func TestParseSkmemIgnoresUnknownKeys(t *testing.T) {
+	mem, ok := parseSkmem("skmem:(r4096,rb369280,t0,tb87040,f0,w0,o0,bl0,d0,zz7)")
+	if !ok {
+		t.Fatalf("expected skmem block to parse")
+	}
+	if mem.RmemAlloc != 4096 || mem.RcvBuf != 369280 || mem.SndBuf != 87040 {
+		t.Fatalf("unexpected skmem %+v", mem)
+	}
+	if _, ok := parseSkmem("LISTEN 0 4096 127.0.0.1:3000 0.0.0.0:*"); ok {
+		t.Fatalf("expected no skmem in a plain line")
+	}
+}
+
+func TestParseSSOutputWithExtraColumns(t *testing.T) {
+	// ss -ltnpeiH: -e appends uid/ino/sk/cgroup, -i adds an indented detail
+	// line per socket, and a state filter drops the State column.
+	input := strings.Join([]string{
+		`LISTEN 0      1024   127.0.0.1:48271 0.0.0.0:* users:(("node",pid=129,fd=9)) uid:1000 ino:943 sk:2 cgroup:/user.slice <->`,
+		"\t bbr wscale:7,7 rto:204 rtt:0.05/0.025 mss:65483 cwnd:10 lastsnd:1234 lastrcv:1234",
+		`LISTEN 0      128    [::]:8443 [::]:* users:(("caddy",pid=77,fd=3)) ino:662 sk:1 cgroup:/ v6only:1 <-> bbr cwnd:10`,
+		`tcp   LISTEN 0      4096   0.0.0.0:5432 0.0.0.0:* users:(("postgres",pid=55,fd=5))`,
+		`0      511    127.0.0.1:6379 0.0.0.0:* users:(("redis-server",pid=66,fd=6)) timer:(keepalive,9.5sec,0)`,
+	}, "\n")
+
+	listeners, err := parseSSOutput(context.Background(), strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseSSOutput error: %v", err)
+	}
+	if len(listeners) != 4 {
+		t.Fatalf("expected 4 listeners (detail lines skipped), got %d: %+v", len(listeners), listeners)
+	}
+	assertListener(t, listeners[0], 48271, 129, "", "node", "127.0.0.1:48271")
+	assertListener(t, listeners[1], 8443, 77, "", "caddy", "[::]:8443")
+	assertListener(t, listeners[2], 5432, 55, "", "postgres", "0.0.0.0:5432")
+	assertListener(t, listeners[3], 6379, 66, "", "redis-server", "127.0.0.1:6379")
+}
+
+func TestParseSSUDPLines(t *testing.T) {
+	input := strings.Join([]string{
+		`UNCONN 0      0      127.0.0.53%lo:53 0.0.0.0:* users:(("systemd-resolve",pid=321,fd=13))`,
+		`UNCONN 0      0      [::]:5353 [::]:* users:(("avahi-daemon",pid=400,fd=12))`,
+	}, "\n")
+
+	listeners, err := parseSSOutput(context.Background(), strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseSSOutput error: %v", err)
+	}
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 sockets, got %d: %+v", len(listeners), listeners)
+	}
+	if listeners[0].Port != 53 || listeners[0].PID != 321 || listeners[0].Command != "systemd-resolve" {
+		t.Fatalf("unexpected first socket %+v", listeners[0])
+	}
+	assertListener(t, listeners[1], 5353, 400, "", "avahi-daemon", "[::]:5353")
+}
@@ -1,7 +1,9 @@
 package ui
 
 import (
+	"io"
 	"os"
+	"unicode/utf8"
 
 	"github.com/muesli/termenv"
 )
@@ -10,31 +12,88 @@ var (
 	stdout  *termenv.Output
 	stderr  *termenv.Output
 	profile termenv.Profile
+	plain   bool
 )
 
-func Configure(noColor bool) {
+// Glyphs are the non-alphanumeric symbols used in human output.
+type Glyphs struct {
+	Arrow    string
+	BarFull  string
+	BarEmpty string
+}
+
+var (
+	unicodeGlyphs = Glyphs{Arrow: "→", BarFull: "█", BarEmpty: "░"}
+	asciiGlyphs   = Glyphs{Arrow: "->", BarFull: "#", BarEmpty: "-"}
+)
+
+// Configure sets up stdout/stderr. Plain mode (also triggered by TERM=dumb)
+// implies no color and additionally guarantees pure-ASCII human output: the
+// ASCII glyph set is used and any other non-ASCII rune is replaced by '?'.
+func Configure(noColor, plainMode bool) {
+	plain = plainMode || os.Getenv("TERM") == "dumb"
 	profile = termenv.EnvColorProfile()
-	if noColor {
+	if noColor || plain {
 		profile = termenv.Ascii
 	}
-	stdout = termenv.NewOutput(os.Stdout, termenv.WithProfile(profile), termenv.WithColorCache(true))
-	stderr = termenv.NewOutput(os.Stderr, termenv.WithProfile(profile), termenv.WithColorCache(true))
+	var outW, errW io.Writer = os.Stdout, os.Stderr
+	if plain {
+		outW, errW = asciiWriter{os.Stdout}, asciiWriter{os.Stderr}
+	}
+	stdout = termenv.NewOutput(outW, termenv.WithProfile(profile), termenv.WithColorCache(true))
+	stderr = termenv.NewOutput(errW, termenv.WithProfile(profile), termenv.WithColorCache(true))
 }
 
 func Stdout() *termenv.Output {
 	if stdout == nil {
-		Configure(false)
+		Configure(false, false)
 	}
 	return stdout
 }
 
 func Stderr() *termenv.Output {
 	if stderr == nil {
-		Configure(false)
+		Configure(false, false)
 	}
 	return stderr
 }
 
+// Plain reports whether plain (ASCII-only, no escape sequences) output is on.
+func Plain() bool {
+	return plain
+}
+
+// Symbols returns the glyph set for the current mode.
+func Symbols() Glyphs {
+	if plain {
+		return asciiGlyphs
+	}
+	return unicodeGlyphs
+}
+
+// asciiWriter replaces every non-ASCII rune with '?'.
+type asciiWriter struct {
+	w io.Writer
+}
+
+func (a asciiWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, 0, len(p))
+	for i := 0; i < len(p); {
+		if p[i] < utf8.RuneSelf {
+			buf = append(buf, p[i])
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRune(p[i:])
+		buf = append(buf, '?')
+		i += size
+	}
+	if _, err := a.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
 func Header(out *termenv.Output, text string) string {
 	return style(out, text, "6", true)
 }
@@ -63,6 +122,19 @@ func Emphasis(out *termenv.Output, text string) string {
 	return style(out, text, "6", true)
 }
 
+// PortClass colors a port by its ports.Classify class: privileged ports
+// stand out, dynamic (ephemeral) ones are flagged, registered ones look
+// like other emphasized text.
+func PortClass(out *termenv.Output, text, class string) string {
+	switch class {
+	case "privileged":
+		return style(out, text, "5", true)
+	case "dynamic":
+		return style(out, text, "3", true)
+	}
+	return Emphasis(out, text)
+}
+
 func Muted(out *termenv.Output, text string) string {
 	s := out.Profile.String(text)
 	return s.Faint().String()
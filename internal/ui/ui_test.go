@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestASCIIWriterReplacesNonASCII(t *testing.T) {
+	var buf bytes.Buffer
+	w := asciiWriter{&buf}
+	in := "nöde → 3000\n"
+	n, err := w.Write([]byte(in))
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if n != len(in) {
+		t.Fatalf("expected to report %d bytes written, got %d", len(in), n)
+	}
+	if got, want := buf.String(), "n?de ? 3000\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestPlainUsesASCIIGlyphs(t *testing.T) {
+	t.Setenv("TERM", "xterm")
+	Configure(false, true)
+	defer Configure(false, false)
+
+	if !Plain() {
+		t.Fatalf("expected plain mode")
+	}
+	for _, g := range []string{Symbols().Arrow, Symbols().BarFull, Symbols().BarEmpty} {
+		for _, r := range g {
+			if r > 127 {
+				t.Fatalf("expected ASCII glyph, got %q", g)
+			}
+		}
+	}
+}
+
+func TestDumbTerminalImpliesPlain(t *testing.T) {
+	t.Setenv("TERM", "dumb")
+	Configure(false, false)
+	defer func() {
+		t.Setenv("TERM", "xterm")
+		Configure(false, false)
+	}()
+	if !Plain() {
+		t.Fatalf("expected TERM=dumb to enable plain mode")
+	}
+}
+
+func TestPortClassRespectsNoColor(t *testing.T) {
+	Configure(true, false)
+	defer Configure(false, false)
+	for _, class := range []string{"privileged", "registered", "dynamic", ""} {
+		if got := PortClass(Stdout(), "8080", class); got != "8080" {
+			t.Fatalf("expected uncolored port for class %q with --no-color, got %q", class, got)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 package main
 
-import "fp/cmd"
+import "github.com/mtreilly/freeport/cmd"
 
 func main() {
 	cmd.Execute()
@@ -0,0 +1,60 @@
+package freeport_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/mtreilly/freeport/pkg/freeport"
+)
+
+func ExampleListTCPListeners() {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	listeners, err := freeport.ListTCPListeners(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	freeport.EnrichListenersWithProcessInfo(ctx, listeners)
+	for _, l := range listeners {
+		if l.Port == port && l.PID == os.Getpid() {
+			fmt.Println("found our own listener")
+		}
+	}
+	// Output: found our own listener
+}
+
+func ExampleHasTCPListenerOnPort() {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	busy, err := freeport.HasTCPListenerOnPort(context.Background(), port)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("busy while listening:", busy)
+
+	ln.Close()
+	busy, err = freeport.HasTCPListenerOnPort(context.Background(), port)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("busy after closing:", busy)
+	// Output:
+	// busy while listening: true
+	// busy after closing: false
+}
@@ -0,0 +1,110 @@
+// Package freeport finds what is listening on local ports, the same way the
+// fp command does: by asking lsof or ss, whichever is installed, and reading
+// /proc or ps for process details.
+//
+// Scans are best-effort. A tool that can't see other users' processes
+// still reports their sockets, but with PID 0 and no command.
+package freeport
+
+import (
+	"context"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+// Listener is one listening socket and the process holding it. Fields the
+// backend or platform can't provide are left zero.
+type Listener struct {
+	Port        int       `json:"port"`
+	PID         int       `json:"pid"`
+	PPID        int       `json:"ppid,omitempty"`
+	User        string    `json:"user,omitempty"`
+	Command     string    `json:"command,omitempty"`
+	CommandLine string    `json:"command_line,omitempty"`
+	Executable  string    `json:"executable,omitempty"`
+	CWD         string    `json:"cwd,omitempty"`
+	Proto       string    `json:"proto,omitempty"` // "tcp" or "udp"
+	Address     string    `json:"address,omitempty"`
+	Family      string    `json:"family,omitempty"` // "ipv4" or "ipv6"
+	Started     time.Time `json:"started,omitzero"`
+}
+
+func fromScan(in []scan.Listener) []Listener {
+	if in == nil {
+		return nil
+	}
+	out := make([]Listener, len(in))
+	for i, l := range in {
+		out[i] = Listener{
+			Port:        l.Port,
+			PID:         l.PID,
+			PPID:        l.PPID,
+			User:        l.User,
+			Command:     l.Command,
+			CommandLine: l.CommandLine,
+			Executable:  l.Executable,
+			CWD:         l.CWD,
+			Proto:       l.Proto,
+			Address:     l.Address,
+			Family:      l.Family,
+			Started:     l.Started,
+		}
+	}
+	return out
+}
+
+func toScan(in []Listener) []scan.Listener {
+	out := make([]scan.Listener, len(in))
+	for i, l := range in {
+		out[i] = scan.Listener{
+			Port:        l.Port,
+			PID:         l.PID,
+			PPID:        l.PPID,
+			User:        l.User,
+			Command:     l.Command,
+			CommandLine: l.CommandLine,
+			Executable:  l.Executable,
+			CWD:         l.CWD,
+			Proto:       l.Proto,
+			Address:     l.Address,
+			Family:      l.Family,
+			Started:     l.Started,
+		}
+	}
+	return out
+}
+
+// ListTCPListeners returns every listening TCP socket, using the first
+// backend that works.
+func ListTCPListeners(ctx context.Context) ([]Listener, error) {
+	listeners, err := scan.ListTCPListeners(ctx)
+	return fromScan(listeners), err
+}
+
+// ListUDPListeners returns bound, unconnected UDP sockets.
+func ListUDPListeners(ctx context.Context) ([]Listener, error) {
+	listeners, err := scan.ListUDPListeners(ctx)
+	return fromScan(listeners), err
+}
+
+// ListTCPListenersOnPort returns the TCP listeners on port, querying just
+// that port where the backend allows it.
+func ListTCPListenersOnPort(ctx context.Context, port int) ([]Listener, error) {
+	listeners, err := scan.ListTCPListenersOnPort(ctx, port)
+	return fromScan(listeners), err
+}
+
+// HasTCPListenerOnPort reports whether anything listens on port over TCP.
+func HasTCPListenerOnPort(ctx context.Context, port int) (bool, error) {
+	return scan.HasTCPListenerOnPort(ctx, port)
+}
+
+// EnrichListenersWithProcessInfo fills in the parent PID, command line,
+// executable, working directory, owner and start time of each listener's
+// process, where the platform exposes them. It modifies listeners in place.
+func EnrichListenersWithProcessInfo(ctx context.Context, listeners []Listener) {
+	enriched := toScan(listeners)
+	scan.EnrichListenersWithProcessInfo(ctx, enriched)
+	copy(listeners, fromScan(enriched))
+}
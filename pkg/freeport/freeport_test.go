@@ -0,0 +1,61 @@
+package freeport
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mtreilly/freeport/internal/scan"
+)
+
+func TestFromScanCopiesPublicFields(t *testing.T) {
+	started := time.Unix(1700000000, 0)
+	got := fromScan([]scan.Listener{{
+		Port: 3000, PID: 42, PPID: 1, User: "dev", Command: "node",
+		CommandLine: "node server.js", Executable: "/usr/bin/node", CWD: "/src",
+		Proto: "tcp", Address: "127.0.0.1", Family: "ipv4", Started: started,
+		Mem: &scan.SocketMem{Drops: 3}, ShellJob: true,
+	}})
+	want := Listener{
+		Port: 3000, PID: 42, PPID: 1, User: "dev", Command: "node",
+		CommandLine: "node server.js", Executable: "/usr/bin/node", CWD: "/src",
+		Proto: "tcp", Address: "127.0.0.1", Family: "ipv4", Started: started,
+	}
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("fromScan = %+v, want %+v", got, want)
+	}
+	if back := fromScan(toScan(got)); back[0] != want {
+		t.Fatalf("round trip = %+v, want %+v", back[0], want)
+	}
+}
+
+func TestListTCPListenersOnPortFindsOwnListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	listeners, err := ListTCPListenersOnPort(ctx, port)
+	if err != nil {
+		t.Skipf("no scan backend: %v", err)
+	}
+	EnrichListenersWithProcessInfo(ctx, listeners)
+	for _, l := range listeners {
+		if l.PID == os.Getpid() {
+			if l.Port != port || l.Proto != "tcp" {
+				t.Fatalf("listener = %+v, want tcp port %d", l, port)
+			}
+			if l.CommandLine == "" {
+				t.Fatalf("enrich left the command line empty: %+v", l)
+			}
+			return
+		}
+	}
+	t.Fatalf("no listener for our pid on port %d in %+v", port, listeners)
+}